@@ -13,6 +13,8 @@ USE neli_webservices;
 CREATE TABLE IF NOT EXISTS `settings` (
   `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
   `max_duration` varchar(255) DEFAULT NULL,
+  `quota_minutes` int(10) unsigned NOT NULL DEFAULT '0',
+  `quota_videos` int(10) unsigned NOT NULL DEFAULT '0',
   PRIMARY KEY (`id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
@@ -47,12 +49,15 @@ CREATE TABLE IF NOT EXISTS `user` (
   `email` varchar(255) NOT NULL,
   `views` int(11) unsigned DEFAULT '0',
   `role` varchar(255) NOT NULL,
+  `admin_id` bigint(20) unsigned DEFAULT NULL,
   `password` text,
   `password_reset_token` text,
   `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
   `updated_at` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00' ON UPDATE CURRENT_TIMESTAMP,
   PRIMARY KEY (`id`),
-  UNIQUE KEY `email` (`email`)
+  UNIQUE KEY `email` (`email`),
+  KEY `fk_user_user_admin_id` (`admin_id`),
+  CONSTRAINT `fk_user_user_admin_id` FOREIGN KEY (`admin_id`) REFERENCES `user` (`id`) ON DELETE SET NULL
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
 CREATE TABLE IF NOT EXISTS `video_content` (
@@ -60,12 +65,18 @@ CREATE TABLE IF NOT EXISTS `video_content` (
   `name` varchar(255) DEFAULT NULL,
   `description` text,
   `path` text,
+  `poster` varchar(64) DEFAULT NULL,
   `duration` int(10) unsigned DEFAULT NULL,
+  `status` varchar(16) NOT NULL DEFAULT 'created',
+  `failure_reason` text,
+  `reprocess_at` timestamp NULL DEFAULT NULL,
   `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
   `leader_id` bigint(20) unsigned NOT NULL,
+  `previous_leader_id` bigint(20) unsigned DEFAULT NULL,
   `update_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
   PRIMARY KEY (`id`),
   KEY `fk_user_video_content_id` (`leader_id`),
+  KEY `video_content_leader_id_status` (`leader_id`, `status`),
   CONSTRAINT `fk_user_video_content_id` FOREIGN KEY (`leader_id`) REFERENCES `user` (`id`) ON DELETE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
@@ -76,6 +87,158 @@ CREATE TABLE IF NOT EXISTS `cleaning` (
   PRIMARY KEY (`id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
+CREATE TABLE IF NOT EXISTS `audit_log` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `actor_id` bigint(20) unsigned NOT NULL,
+  `role` varchar(255) NOT NULL,
+  `action` varchar(255) NOT NULL,
+  `target_type` varchar(255) NOT NULL,
+  `target_id` bigint(20) unsigned DEFAULT NULL,
+  `diff` text,
+  `ip` varchar(45) DEFAULT NULL,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `audit_log_actor_id` (`actor_id`),
+  KEY `audit_log_action` (`action`),
+  KEY `audit_log_target` (`target_type`, `target_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `capture_session` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `video_content_id` bigint(20) unsigned NOT NULL,
+  `leader_id` bigint(20) unsigned NOT NULL,
+  `device_id` bigint(20) unsigned DEFAULT NULL,
+  `max_duration` int(11) NOT NULL DEFAULT '0',
+  `started_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `stopped_at` timestamp NULL DEFAULT NULL,
+  `duration` int(11) DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `capture_session_video_content_id` (`video_content_id`),
+  KEY `capture_session_leader_id` (`leader_id`, `stopped_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `device` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `name` varchar(255) NOT NULL,
+  `address` varchar(255) NOT NULL,
+  `transport` varchar(10) NOT NULL DEFAULT 'udp',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `last_seen_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `device_leader` (
+  `device_id` bigint(20) unsigned NOT NULL,
+  `leader_id` bigint(20) unsigned NOT NULL,
+  PRIMARY KEY (`device_id`, `leader_id`),
+  KEY `device_leader_leader_id` (`leader_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `upload` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `video_content_id` bigint(20) unsigned NOT NULL,
+  `path` varchar(64) NOT NULL,
+  `length` bigint(20) unsigned NOT NULL,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `expires_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `upload_video_content_id` (`video_content_id`),
+  KEY `upload_expires_at` (`expires_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `rendition` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `video_content_id` bigint(20) unsigned NOT NULL,
+  `name` varchar(64) NOT NULL,
+  `type` varchar(16) NOT NULL,
+  `file` varchar(255) NOT NULL,
+  `width` int(10) unsigned NOT NULL DEFAULT 0,
+  `height` int(10) unsigned NOT NULL DEFAULT 0,
+  `bandwidth` int(10) unsigned NOT NULL DEFAULT 0,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `rendition_video_content_id_name` (`video_content_id`, `name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `marker` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `video_content_id` bigint(20) unsigned NOT NULL,
+  `time_offset` int(10) unsigned NOT NULL,
+  `title` varchar(255) NOT NULL,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `marker_video_content_id` (`video_content_id`, `time_offset`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `comment` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `video_content_id` bigint(20) unsigned NOT NULL,
+  `user_id` bigint(20) unsigned NOT NULL,
+  `time_offset` int(10) unsigned NOT NULL,
+  `body` text NOT NULL,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `comment_video_content_id` (`video_content_id`, `time_offset`),
+  CONSTRAINT `fk_user_comment_id` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `tag` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `leader_id` bigint(20) unsigned NOT NULL,
+  `name` varchar(64) NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `tag_leader_id_name` (`leader_id`, `name`),
+  CONSTRAINT `fk_user_tag_leader_id` FOREIGN KEY (`leader_id`) REFERENCES `user` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `video_content_tag` (
+  `video_content_id` bigint(20) unsigned NOT NULL,
+  `tag_id` bigint(20) unsigned NOT NULL,
+  PRIMARY KEY (`video_content_id`, `tag_id`),
+  KEY `video_content_tag_tag_id` (`tag_id`),
+  CONSTRAINT `fk_tag_video_content_tag_id` FOREIGN KEY (`tag_id`) REFERENCES `tag` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `playlist` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `leader_id` bigint(20) unsigned NOT NULL,
+  `name` varchar(255) NOT NULL,
+  `description` text NOT NULL,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  CONSTRAINT `fk_user_playlist_leader_id` FOREIGN KEY (`leader_id`) REFERENCES `user` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `playlist_content` (
+  `playlist_id` bigint(20) unsigned NOT NULL,
+  `video_content_id` bigint(20) unsigned NOT NULL,
+  `position` int(10) unsigned NOT NULL,
+  PRIMARY KEY (`playlist_id`, `video_content_id`),
+  KEY `playlist_content_video_content_id` (`video_content_id`),
+  CONSTRAINT `fk_playlist_playlist_content_id` FOREIGN KEY (`playlist_id`) REFERENCES `playlist` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `playlist_share` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `playlist_id` bigint(20) unsigned NOT NULL,
+  `user_id` bigint(20) unsigned NOT NULL,
+  `token` varchar(255) NOT NULL,
+  `message` text NOT NULL,
+  `expiration_date` datetime NOT NULL,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `playlist_share_user_id` (`user_id`, `expiration_date`),
+  CONSTRAINT `fk_playlist_playlist_share_id` FOREIGN KEY (`playlist_id`) REFERENCES `playlist` (`id`) ON DELETE CASCADE,
+  CONSTRAINT `fk_user_playlist_share_id` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `quota` (
+  `user_id` bigint(20) unsigned NOT NULL,
+  `minutes` int(10) unsigned DEFAULT NULL,
+  `videos` int(10) unsigned DEFAULT NULL,
+  PRIMARY KEY (`user_id`),
+  CONSTRAINT `fk_user_quota_user_id` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
 /*!40111 SET SQL_NOTES=@OLD_SQL_NOTES */;
 /*!40101 SET SQL_MODE=@OLD_SQL_MODE */;
 /*!40014 SET FOREIGN_KEY_CHECKS=@OLD_FOREIGN_KEY_CHECKS */;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE video_content ADD `previous_leader_id` BIGINT UNSIGNED NULL AFTER `leader_id`;
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE video_content DROP COLUMN `previous_leader_id`;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE `audit_log` (
+  `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `actor_id` BIGINT UNSIGNED NOT NULL,
+  `role` VARCHAR(255) NOT NULL,
+  `action` VARCHAR(255) NOT NULL,
+  `target_type` VARCHAR(255) NOT NULL,
+  `target_id` BIGINT UNSIGNED NULL,
+  `diff` TEXT,
+  `ip` VARCHAR(45),
+  `created_at` TIMESTAMP DEFAULT NOW(),
+  PRIMARY KEY (`id`),
+  KEY `audit_log_actor_id` (`actor_id`),
+  KEY `audit_log_action` (`action`),
+  KEY `audit_log_target` (`target_type`, `target_id`)
+) ENGINE=InnoDB;
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS `audit_log`;
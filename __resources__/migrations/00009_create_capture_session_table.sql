@@ -0,0 +1,17 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE `capture_session` (
+  `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `video_content_id` BIGINT UNSIGNED NOT NULL,
+  `leader_id` BIGINT UNSIGNED NOT NULL,
+  `max_duration` INT NOT NULL DEFAULT 0,
+  `started_at` TIMESTAMP DEFAULT NOW(),
+  `stopped_at` TIMESTAMP NULL,
+  `duration` INT NULL,
+  PRIMARY KEY (`id`),
+  KEY `capture_session_video_content_id` (`video_content_id`),
+  KEY `capture_session_leader_id` (`leader_id`, `stopped_at`)
+) ENGINE=InnoDB;
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS `capture_session`;
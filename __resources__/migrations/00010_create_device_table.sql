@@ -0,0 +1,22 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE `device` (
+  `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `name` VARCHAR(255) NOT NULL,
+  `address` VARCHAR(255) NOT NULL,
+  `transport` VARCHAR(10) NOT NULL DEFAULT 'udp',
+  `created_at` TIMESTAMP DEFAULT NOW(),
+  `last_seen_at` TIMESTAMP NULL,
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB;
+
+CREATE TABLE `device_leader` (
+  `device_id` BIGINT UNSIGNED NOT NULL,
+  `leader_id` BIGINT UNSIGNED NOT NULL,
+  PRIMARY KEY (`device_id`, `leader_id`),
+  KEY `device_leader_leader_id` (`leader_id`)
+) ENGINE=InnoDB;
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS `device_leader`;
+DROP TABLE IF EXISTS `device`;
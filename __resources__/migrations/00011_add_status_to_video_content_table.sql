@@ -0,0 +1,29 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE `video_content`
+  ADD COLUMN `status` VARCHAR(16) NOT NULL DEFAULT 'created' AFTER `duration`,
+  ADD KEY `video_content_leader_id_status` (`leader_id`, `status`);
+
+UPDATE `video_content`
+SET `status` = 'recording'
+WHERE IFNULL(`path`, '') = '' AND `id` IN (
+  SELECT `video_content_id` FROM `capture_session`
+  WHERE `stopped_at` IS NULL AND (`max_duration` = 0 OR `started_at` + INTERVAL `max_duration` SECOND > NOW())
+);
+
+UPDATE `video_content`
+SET `status` = 'processing'
+WHERE IFNULL(`path`, '') = '' AND `status` = 'created' AND `id` IN (SELECT `video_content_id` FROM `capture_session`);
+
+UPDATE `video_content`
+SET `status` = 'ready'
+WHERE IFNULL(`path`, '') != '';
+
+UPDATE `video_content`
+SET `status` = 'deleted'
+WHERE `previous_leader_id` IS NOT NULL;
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE `video_content`
+  DROP KEY `video_content_leader_id_status`,
+  DROP COLUMN `status`;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE `video_content`
+  ADD COLUMN `failure_reason` TEXT NULL AFTER `status`,
+  ADD COLUMN `reprocess_at` TIMESTAMP NULL AFTER `failure_reason`;
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE `video_content`
+  DROP COLUMN `reprocess_at`,
+  DROP COLUMN `failure_reason`;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE `upload` (
+  `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `video_content_id` BIGINT UNSIGNED NOT NULL,
+  `path` VARCHAR(64) NOT NULL,
+  `length` BIGINT UNSIGNED NOT NULL,
+  `created_at` TIMESTAMP DEFAULT NOW(),
+  PRIMARY KEY (`id`),
+  KEY `upload_video_content_id` (`video_content_id`)
+) ENGINE=InnoDB;
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS `upload`;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE `rendition` (
+  `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `video_content_id` BIGINT UNSIGNED NOT NULL,
+  `name` VARCHAR(64) NOT NULL,
+  `type` VARCHAR(16) NOT NULL,
+  `file` VARCHAR(255) NOT NULL,
+  `width` INT UNSIGNED NOT NULL DEFAULT 0,
+  `height` INT UNSIGNED NOT NULL DEFAULT 0,
+  `bandwidth` INT UNSIGNED NOT NULL DEFAULT 0,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `rendition_video_content_id_name` (`video_content_id`, `name`)
+) ENGINE=InnoDB;
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS `rendition`;
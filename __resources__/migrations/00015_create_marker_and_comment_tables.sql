@@ -0,0 +1,27 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE `marker` (
+  `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `video_content_id` BIGINT UNSIGNED NOT NULL,
+  `time_offset` INT UNSIGNED NOT NULL,
+  `title` VARCHAR(255) NOT NULL,
+  `created_at` TIMESTAMP DEFAULT NOW(),
+  PRIMARY KEY (`id`),
+  KEY `marker_video_content_id` (`video_content_id`, `time_offset`)
+) ENGINE=InnoDB;
+
+CREATE TABLE `comment` (
+  `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `video_content_id` BIGINT UNSIGNED NOT NULL,
+  `user_id` BIGINT UNSIGNED NOT NULL,
+  `time_offset` INT UNSIGNED NOT NULL,
+  `body` TEXT NOT NULL,
+  `created_at` TIMESTAMP DEFAULT NOW(),
+  PRIMARY KEY (`id`),
+  KEY `comment_video_content_id` (`video_content_id`, `time_offset`),
+  CONSTRAINT `fk_user_comment_id` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB;
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS `comment`;
+DROP TABLE IF EXISTS `marker`;
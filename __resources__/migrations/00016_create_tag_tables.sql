@@ -0,0 +1,22 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE `tag` (
+  `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `leader_id` BIGINT UNSIGNED NOT NULL,
+  `name` VARCHAR(64) NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `tag_leader_id_name` (`leader_id`, `name`),
+  CONSTRAINT `fk_user_tag_leader_id` FOREIGN KEY (`leader_id`) REFERENCES `user` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+CREATE TABLE `video_content_tag` (
+  `video_content_id` BIGINT UNSIGNED NOT NULL,
+  `tag_id` BIGINT UNSIGNED NOT NULL,
+  PRIMARY KEY (`video_content_id`, `tag_id`),
+  KEY `video_content_tag_tag_id` (`tag_id`),
+  CONSTRAINT `fk_tag_video_content_tag_id` FOREIGN KEY (`tag_id`) REFERENCES `tag` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB;
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS `video_content_tag`;
+DROP TABLE IF EXISTS `tag`;
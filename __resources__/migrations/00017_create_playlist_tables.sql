@@ -0,0 +1,39 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE `playlist` (
+  `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `leader_id` BIGINT UNSIGNED NOT NULL,
+  `name` VARCHAR(255) NOT NULL,
+  `description` TEXT NOT NULL,
+  `created_at` TIMESTAMP DEFAULT NOW(),
+  PRIMARY KEY (`id`),
+  CONSTRAINT `fk_user_playlist_leader_id` FOREIGN KEY (`leader_id`) REFERENCES `user` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+CREATE TABLE `playlist_content` (
+  `playlist_id` BIGINT UNSIGNED NOT NULL,
+  `video_content_id` BIGINT UNSIGNED NOT NULL,
+  `position` INT UNSIGNED NOT NULL,
+  PRIMARY KEY (`playlist_id`, `video_content_id`),
+  KEY `playlist_content_video_content_id` (`video_content_id`),
+  CONSTRAINT `fk_playlist_playlist_content_id` FOREIGN KEY (`playlist_id`) REFERENCES `playlist` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+CREATE TABLE `playlist_share` (
+  `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `playlist_id` BIGINT UNSIGNED NOT NULL,
+  `user_id` BIGINT UNSIGNED NOT NULL,
+  `token` VARCHAR(255) NOT NULL,
+  `message` TEXT NOT NULL,
+  `expiration_date` DATETIME NOT NULL,
+  `created_at` TIMESTAMP DEFAULT NOW(),
+  PRIMARY KEY (`id`),
+  KEY `playlist_share_user_id` (`user_id`, `expiration_date`),
+  CONSTRAINT `fk_playlist_playlist_share_id` FOREIGN KEY (`playlist_id`) REFERENCES `playlist` (`id`) ON DELETE CASCADE,
+  CONSTRAINT `fk_user_playlist_share_id` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB;
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS `playlist_share`;
+DROP TABLE IF EXISTS `playlist_content`;
+DROP TABLE IF EXISTS `playlist`;
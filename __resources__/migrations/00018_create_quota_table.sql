@@ -0,0 +1,36 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE `settings`
+  ADD COLUMN `quota_minutes` INT UNSIGNED NOT NULL DEFAULT 0 AFTER `max_duration`,
+  ADD COLUMN `quota_videos` INT UNSIGNED NOT NULL DEFAULT 0 AFTER `quota_minutes`;
+
+ALTER TABLE `user`
+  ADD COLUMN `admin_id` BIGINT UNSIGNED NULL AFTER `role`,
+  ADD CONSTRAINT `fk_user_user_admin_id` FOREIGN KEY (`admin_id`) REFERENCES `user` (`id`) ON DELETE SET NULL;
+
+-- Leaders created before are managed by the admin who created them, as audited
+UPDATE `user`
+JOIN `audit_log` ON `audit_log`.`action` = 'leader.create'
+  AND `audit_log`.`target_type` = 'user' AND `audit_log`.`target_id` = `user`.`id`
+JOIN `user` `admin` ON `admin`.`id` = `audit_log`.`actor_id` AND `admin`.`role` = 'administrator'
+SET `user`.`admin_id` = `admin`.`id`
+WHERE `user`.`role` = 'leader';
+
+CREATE TABLE `quota` (
+  `user_id` BIGINT UNSIGNED NOT NULL,
+  `minutes` INT UNSIGNED NULL,
+  `videos` INT UNSIGNED NULL,
+  PRIMARY KEY (`user_id`),
+  CONSTRAINT `fk_user_quota_user_id` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB;
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS `quota`;
+
+ALTER TABLE `user`
+  DROP FOREIGN KEY `fk_user_user_admin_id`,
+  DROP COLUMN `admin_id`;
+
+ALTER TABLE `settings`
+  DROP COLUMN `quota_videos`,
+  DROP COLUMN `quota_minutes`;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE `video_content`
+  ADD COLUMN `poster` VARCHAR(64) NULL AFTER `path`;
+
+-- Posters were only produced by the hub next to the processed videos
+UPDATE `video_content`
+SET `poster` = CONCAT(`id`, '.jpg')
+WHERE IFNULL(`path`, '') != '';
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE `video_content`
+  DROP COLUMN `poster`;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE `capture_session`
+  ADD COLUMN `device_id` BIGINT(20) UNSIGNED NULL AFTER `leader_id`;
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE `capture_session`
+  DROP COLUMN `device_id`;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE `upload`
+  ADD COLUMN `expires_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP AFTER `created_at`,
+  ADD KEY `upload_expires_at` (`expires_at`);
+
+-- Unfinished uploads were kept forever, they expire a day after their creation
+UPDATE `upload`
+SET `expires_at` = `created_at` + INTERVAL 1 DAY;
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE `upload`
+  DROP KEY `upload_expires_at`,
+  DROP COLUMN `expires_at`;
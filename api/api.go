@@ -59,3 +59,12 @@ func UserIdFromContext(r *http.Request) int64 {
 
 	return 0
 }
+
+// RoleFromContext extracts role from JWT token.
+func RoleFromContext(r *http.Request) string {
+	_, c, _ := jwtauth.FromContext(r.Context())
+
+	role, _ := c["role"].(string)
+
+	return role
+}
@@ -0,0 +1,244 @@
+package asset
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.com/arnaud-web/neli-webservices/api"
+	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/config"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
+	"gitlab.com/arnaud-web/neli-webservices/storage"
+)
+
+// Prefix is the url prefix of the assets served from the local storage
+const Prefix = storage.LocalPrefix
+
+// URL is a signed url of an asset
+type URL struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Sign returns the url of the asset given by the storage,
+// valid for asset_url_life seconds and never after max when it is not zero
+func Sign(key string, max time.Time) (URL, error) {
+	e := expiry(max)
+	u, err := storage.Default.SignedURL(key, e)
+
+	return URL{URL: u, ExpiresAt: e}, err
+}
+
+// expiry returns the expiry of an url signed now, never after max when it is not zero
+func expiry(max time.Time) time.Time {
+	e := time.Now().Add(time.Duration(*config.AssetURLLife) * time.Second)
+
+	if !max.IsZero() && max.Before(e) {
+		e = max
+	}
+
+	return e.Truncate(time.Second)
+}
+
+// Rendition is a rendition of a video with its signed url
+type Rendition struct {
+	models.Rendition
+	URL string `json:"url"`
+}
+
+// Playback is the signed urls of the renditions of a video.
+// Its url is the one of the first mp4 rendition, for single file players.
+type Playback struct {
+	URL
+	Renditions []Rendition `json:"renditions"`
+}
+
+// Video returns the urls of the renditions of the video stored under path.
+// HLS playlists are served by the service so their entries are signed too.
+func Video(p string, l []models.Rendition, max time.Time) (Playback, error) {
+	pb := Playback{Renditions: make([]Rendition, len(l))}
+	mp4 := false
+
+	for i, r := range l {
+		var u URL
+		var err error
+
+		if r.Type == models.RenditionHLS {
+			e := expiry(max)
+			u = URL{URL: storage.ServedURL(r.Key(p), e), ExpiresAt: e}
+		} else if u, err = Sign(r.Key(p), max); err != nil {
+			return pb, err
+		}
+
+		pb.Renditions[i] = Rendition{r, u.URL}
+
+		if i == 0 || (!mp4 && r.Type == models.RenditionMP4) {
+			pb.URL = u
+			mp4 = r.Type == models.RenditionMP4
+		}
+	}
+
+	return pb, nil
+}
+
+// Serve sends the asset of a signed url of the local storage.
+// Range requests are supported so videos can be sought.
+// Directories are never listed.
+func Serve(w http.ResponseWriter, r *http.Request) {
+	key := path.Clean("/" + strings.TrimPrefix(r.URL.Path, Prefix))
+	e, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+
+	if err != nil || !storage.Verify(key, e, r.URL.Query().Get("signature")) {
+		api.SendError(w, http.StatusForbidden, messages.InvalidSignature)
+		return
+	}
+
+	o, i, err := storage.Default.Get(r.Context(), key)
+
+	if err == storage.ErrNotExist {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	defer o.Close()
+
+	if path.Ext(key) == playlistExt {
+		servePlaylist(w, r, key, time.Unix(e, 0), o)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "private, no-transform")
+	http.ServeContent(w, r, path.Base(key), i.ModTime, o)
+}
+
+// playlistExt is the extension of the HLS playlists
+const playlistExt = ".m3u8"
+
+// maxPlaylistSize is the max size of a served HLS playlist
+const maxPlaylistSize = 1 << 20
+
+// servePlaylist sends the HLS playlist with its entries replaced by signed urls,
+// relative entries can't be fetched without the signature of the playlist.
+// Playlists are served again by the service, the other entries by the storage.
+// Segments stay valid for the duration of the playlist past its expiry
+// so a playback started in time can end.
+func servePlaylist(w http.ResponseWriter, r *http.Request, key string, e time.Time, o io.Reader) {
+	b, err := ioutil.ReadAll(io.LimitReader(o, maxPlaylistSize))
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	lines := strings.Split(string(b), "\n")
+	d := 0.0
+
+	for _, l := range lines {
+		if strings.HasPrefix(l, "#EXTINF:") {
+			f, _ := strconv.ParseFloat(strings.SplitN(strings.TrimPrefix(l, "#EXTINF:"), ",", 2)[0], 64)
+			d += f
+		}
+	}
+
+	segments := e.Add(time.Duration(d * float64(time.Second))).Truncate(time.Second)
+
+	sign := func(uri string) (string, error) {
+		k, ok := entryKey(key, uri)
+
+		if !ok {
+			return uri, nil
+		}
+
+		if path.Ext(k) == playlistExt {
+			return storage.ServedURL(k, e), nil
+		}
+
+		return storage.Default.SignedURL(k, segments)
+	}
+
+	for i, l := range lines {
+		l = strings.TrimRight(l, "\r")
+
+		if m := entry(l); m != nil {
+			var u string
+			u, err = sign(l[m[0]:m[1]])
+			lines[i] = l[:m[0]] + u + l[m[1]:]
+		}
+
+		if err != nil {
+			logging.FromContext(r.Context()).Error(err)
+			api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method != http.MethodHead {
+		io.WriteString(w, strings.Join(lines, "\n"))
+	}
+}
+
+// uriAttribute matches the URI attribute of a playlist tag, like in EXT-X-MEDIA
+var uriAttribute = regexp.MustCompile(`URI="([^"]*)"`)
+
+// entry returns the bounds of the uri of the playlist line, nil when there is none
+func entry(l string) []int {
+	if l == "" {
+		return nil
+	}
+
+	if !strings.HasPrefix(l, "#") {
+		return []int{0, len(l)}
+	}
+
+	if m := uriAttribute.FindStringSubmatchIndex(l); m != nil {
+		return m[2:4]
+	}
+
+	return nil
+}
+
+// entryKey returns the storage key of a relative entry of the playlist of the key
+func entryKey(key, uri string) (string, bool) {
+	if u, err := url.Parse(uri); err != nil || u.IsAbs() || strings.HasPrefix(uri, "/") {
+		return "", false
+	}
+
+	return path.Join(path.Dir(key), uri), true
+}
+
+// PlaylistKeys returns the storage keys of the relative entries
+// of the HLS playlist of the key, like its variants and segments
+func PlaylistKeys(key string, b []byte) []string {
+	l := []string{}
+
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimRight(line, "\r")
+
+		if m := entry(line); m != nil {
+			if k, ok := entryKey(key, line[m[0]:m[1]]); ok {
+				l = append(l, k)
+			}
+		}
+	}
+
+	return l
+}
@@ -0,0 +1,162 @@
+package asset
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/storage"
+	"gitlab.com/arnaud-web/neli-webservices/test/assert"
+)
+
+// assets sets a temporary local storage with a video, removed by the returned function
+func assets(t *testing.T) func() {
+	d, err := ioutil.TempDir("", "assets")
+	assert.NoError(t, err)
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(d, "abcdef"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(d, "abcdef", "abcdef.mp4"), []byte("0123456789"), 0644))
+
+	s := storage.Default
+	storage.Default = storage.Local{Dir: d}
+
+	return func() {
+		storage.Default = s
+		os.RemoveAll(d)
+	}
+}
+
+// sign returns the signed url of the key, failing the test on error
+func sign(t *testing.T, key string, max time.Time) string {
+	u, err := Sign(key, max)
+	assert.NoError(t, err)
+	return u.URL
+}
+
+func get(url string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	Serve(rr, httptest.NewRequest("GET", url, nil))
+	return rr
+}
+
+func TestServe(t *testing.T) {
+	defer assets(t)()
+
+	rr := get(sign(t, "abcdef/abcdef.mp4", time.Time{}))
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Equals(t, rr.Body.String(), "0123456789")
+}
+
+func TestServe_Range(t *testing.T) {
+	defer assets(t)()
+
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", sign(t, "abcdef/abcdef.mp4", time.Time{}), nil)
+	r.Header.Set("Range", "bytes=2-4")
+
+	Serve(rr, r)
+
+	assert.Response(t, rr, http.StatusPartialContent, "")
+	assert.Equals(t, rr.Body.String(), "234")
+	assert.Equals(t, rr.Header().Get("Content-Range"), "bytes 2-4/10")
+}
+
+func TestServe_Expired(t *testing.T) {
+	defer assets(t)()
+
+	rr := get(sign(t, "abcdef/abcdef.mp4", time.Now().Add(-time.Minute)))
+
+	assert.ResponseError(t, rr, http.StatusForbidden, messages.InvalidSignature)
+}
+
+func TestServe_Tampered(t *testing.T) {
+	defer assets(t)()
+
+	u := sign(t, "abcdef/abcdef.mp4", time.Time{})
+	rr := get(strings.Replace(u, "abcdef.mp4", "other.mp4", 1))
+
+	assert.ResponseError(t, rr, http.StatusForbidden, messages.InvalidSignature)
+}
+
+func TestServe_Unsigned(t *testing.T) {
+	defer assets(t)()
+
+	rr := get(Prefix + "/abcdef/abcdef.mp4")
+
+	assert.ResponseError(t, rr, http.StatusForbidden, messages.InvalidSignature)
+}
+
+func TestServe_Directory(t *testing.T) {
+	defer assets(t)()
+
+	rr := get(sign(t, "abcdef", time.Time{}))
+
+	assert.Response(t, rr, http.StatusNotFound, "")
+}
+
+func TestSign_Max(t *testing.T) {
+	max := time.Now().Add(time.Minute)
+
+	defer assets(t)()
+
+	u, err := Sign("abcdef/abcdef.mp4", max)
+	assert.NoError(t, err)
+
+	if u.ExpiresAt.After(max) {
+		t.Fatalf("Expected an expiry before %v, got %v", max, u.ExpiresAt)
+	}
+}
+
+// playlists stores an HLS master playlist with a variant
+func playlists(t *testing.T) {
+	ctx := context.Background()
+
+	assert.NoError(t, storage.Default.Put(ctx, "abcdef/hls/master.m3u8", strings.NewReader(
+		"#EXTM3U\n"+
+			"#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"aac\",NAME=\"fr\",URI=\"audio/fr.m3u8\"\n"+
+			"#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360\n"+
+			"360p.m3u8\n")))
+
+	assert.NoError(t, storage.Default.Put(ctx, "abcdef/hls/360p.m3u8", strings.NewReader(
+		"#EXTM3U\n"+
+			"#EXT-X-TARGETDURATION:10\n"+
+			"#EXTINF:10.0,\n"+
+			"360p_0.ts\n"+
+			"#EXTINF:5.0,\n"+
+			"360p_1.ts\n"+
+			"#EXT-X-ENDLIST\n")))
+}
+
+func TestServe_Playlist(t *testing.T) {
+	defer assets(t)()
+	playlists(t)
+
+	e := time.Now().Add(time.Minute)
+	pb, err := Video("abcdef", []models.Rendition{{Name: "hls", Type: models.RenditionHLS, File: "hls/master.m3u8"}}, e)
+	assert.NoError(t, err)
+
+	rr := get(pb.Renditions[0].URL)
+	b := rr.Body.String()
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Equals(t, rr.Header().Get("Content-Type"), "application/vnd.apple.mpegurl")
+	assert.Contains(t, b, `URI="/assets/abcdef/hls/audio/fr.m3u8?expires=`)
+	assert.Contains(t, b, "\n/assets/abcdef/hls/360p.m3u8?expires=")
+
+	rr = get(strings.Split(b[strings.Index(b, "\n/assets/abcdef/hls/360p.m3u8"):], "\n")[1])
+	b = rr.Body.String()
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, b, "#EXTINF:10.0,\n/assets/abcdef/hls/360p_0.ts?expires="+strconv.FormatInt(e.Truncate(time.Second).Unix()+15, 10))
+	assert.Contains(t, b, "#EXT-X-ENDLIST")
+}
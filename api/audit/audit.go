@@ -0,0 +1,208 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx/types"
+	"gitlab.com/arnaud-web/neli-webservices/api"
+	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
+)
+
+// Default and max number of entries returned by List
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// Fields never written in audit log
+var hidden = map[string]bool{
+	"password": true,
+}
+
+type change struct {
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// Record writes a privileged action in audit log.
+// Actor and role are extracted from JWT token, ip from the request.
+// Before and after are the target states, one of them can be nil
+// for a creation or a deletion. Only changed fields are recorded.
+// An audit failure is logged but never cancels the action already done.
+func Record(r *http.Request, action, target string, tid int64, before, after interface{}) {
+	d, err := diff(before, after)
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		return
+	}
+
+	a := models.Audit{
+		ActorID:    api.UserIdFromContext(r),
+		Role:       api.RoleFromContext(r),
+		Action:     action,
+		TargetType: target,
+		TargetID:   tid,
+		Diff:       types.JSONText(d),
+		IP:         ip(r),
+	}
+
+	if err := a.New(); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+	}
+}
+
+// diff compares json representations of before and after.
+// It returns a json object with an entry for each changed field.
+func diff(before, after interface{}) ([]byte, error) {
+	b, err := fields(before)
+
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := fields(after)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d := map[string]change{}
+
+	for k, v := range b {
+		if !reflect.DeepEqual(v, a[k]) {
+			d[k] = change{Before: v, After: a[k]}
+		}
+	}
+
+	for k, v := range a {
+		if _, ok := b[k]; !ok {
+			d[k] = change{After: v}
+		}
+	}
+
+	return json.Marshal(d)
+}
+
+// fields transforms a value to a map of its json fields
+func fields(i interface{}) (map[string]interface{}, error) {
+	m := map[string]interface{}{}
+
+	if i == nil {
+		return m, nil
+	}
+
+	j, err := json.Marshal(i)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(j, &m); err != nil {
+		return nil, err
+	}
+
+	for k := range hidden {
+		delete(m, k)
+	}
+
+	return m, nil
+}
+
+// ip returns the client address without port.
+// RemoteAddr is set from proxy headers by RealIP middleware.
+func ip(r *http.Request) string {
+	h, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return h
+}
+
+// List returns audit log entries.
+// Optional query parameters filter the result:
+// * actorId, targetId have to be numbers
+// * action and targetType are exact values
+// * from and to have to be formatted with RFC3339
+// * limit (max 1000) and offset paginate the result
+func List(w http.ResponseWriter, r *http.Request) {
+	f, err := filter(r)
+
+	if err != nil {
+		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
+		return
+	}
+
+	l, err := models.ListAudit(f)
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	api.Send(w, http.StatusOK, l)
+}
+
+func filter(r *http.Request) (models.AuditFilter, error) {
+	q := r.URL.Query()
+	f := models.AuditFilter{
+		Action:     q.Get("action"),
+		TargetType: q.Get("targetType"),
+		Limit:      defaultLimit,
+	}
+
+	var err error
+
+	if v := q.Get("actorId"); v != "" {
+		if f.ActorID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return f, err
+		}
+	}
+
+	if v := q.Get("targetId"); v != "" {
+		if f.TargetID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return f, err
+		}
+	}
+
+	if v := q.Get("from"); v != "" {
+		if f.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, err
+		}
+	}
+
+	if v := q.Get("to"); v != "" {
+		if f.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, err
+		}
+	}
+
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit <= 0 {
+			return f, errors.New(messages.InvalidParameters)
+		}
+	}
+
+	if f.Limit > maxLimit {
+		f.Limit = maxLimit
+	}
+
+	if v := q.Get("offset"); v != "" {
+		if f.Offset, err = strconv.Atoi(v); err != nil || f.Offset < 0 {
+			return f, errors.New(messages.InvalidParameters)
+		}
+	}
+
+	return f, nil
+}
@@ -0,0 +1,108 @@
+package audit
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/icrowley/fake"
+	"github.com/jmoiron/sqlx/types"
+	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/test/assert"
+	"gitlab.com/arnaud-web/neli-webservices/test/expect"
+	"gitlab.com/arnaud-web/neli-webservices/test/stub"
+)
+
+func TestRecord(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	uid := int64(rand.Uint64())
+	_, r := stub.HttpWithRole(uid, models.SuperAdminRole)
+
+	expect.AuditInsert(models.AuditMaxDuration, mock)
+
+	Record(r, models.AuditMaxDuration, models.SettingsTarget, 0,
+		models.Settings{MaxDuration: 60}, models.Settings{MaxDuration: 120})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDiff(t *testing.T) {
+	before := models.User{ID: 1, Email: fake.EmailAddress(), Firstname: fake.FirstName(), Password: fake.SimplePassword()}
+	after := before
+	after.Email = fake.EmailAddress()
+	after.Password = fake.SimplePassword()
+
+	d, err := diff(before, after)
+
+	assert.NoError(t, err)
+	assert.Equals(t, string(d), fmt.Sprintf(`{"email":{"before":"%s","after":"%s"}}`, before.Email, after.Email))
+}
+
+func TestDiff_Creation(t *testing.T) {
+	d, err := diff(nil, map[string]string{"date": "2018-01-01"})
+
+	assert.NoError(t, err)
+	assert.Equals(t, string(d), `{"date":{"after":"2018-01-01"}}`)
+}
+
+func TestList(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	a := models.Audit{
+		ID:         int64(rand.Uint64()),
+		ActorID:    rand.Int63n(1000) + 1,
+		Role:       models.AdminRole,
+		Action:     models.AuditLeaderCreate,
+		TargetType: models.UserTarget,
+		TargetID:   int64(rand.Uint64()),
+		Diff:       types.JSONText(`{"email":{"after":"leader@neli.com"}}`),
+		IP:         "127.0.0.1",
+		CreatedAt:  time.Now(),
+	}
+	expect.Audit(a, mock).WithArgs(a.ActorID, a.Action, 100, 0)
+
+	rr, r := stub.Http()
+	q := r.URL.Query()
+	q.Set("actorId", fmt.Sprintf("%d", a.ActorID))
+	q.Set("action", a.Action)
+	r.URL.RawQuery = q.Encode()
+
+	List(rr, r)
+
+	b := rr.Body.String()
+
+	assert.Response(t, rr, http.StatusOK, "")
+
+	assert.Contains(t, b, fmt.Sprintf("%d", a.ActorID))
+	assert.Contains(t, b, a.Action)
+	assert.Contains(t, b, string(a.Diff))
+	assert.Contains(t, b, a.IP)
+}
+
+func TestList_InvalidDate(t *testing.T) {
+	rr, r := stub.Http()
+	q := r.URL.Query()
+	q.Set("from", time.Now().Format(time.RFC1123Z))
+	r.URL.RawQuery = q.Encode()
+
+	List(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusBadRequest, messages.InvalidParameters)
+}
+
+func TestList_InvalidLimit(t *testing.T) {
+	rr, r := stub.Http()
+	q := r.URL.Query()
+	q.Set("limit", "-1")
+	r.URL.RawQuery = q.Encode()
+
+	List(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusBadRequest, messages.InvalidParameters)
+}
@@ -1,9 +1,7 @@
 package bearer
 
 import (
-	"log"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/go-chi/jwtauth"
@@ -12,10 +10,9 @@ import (
 	"gitlab.com/arnaud-web/neli-webservices/api/messages"
 	"gitlab.com/arnaud-web/neli-webservices/config"
 	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
 )
 
-var logger = log.New(os.Stdout, "", log.LstdFlags|log.Lshortfile)
-
 // Algorithm used to generated JWT token
 const TokenAlgorithm = "HS256"
 
@@ -74,7 +71,7 @@ func Refresh(w http.ResponseWriter, r *http.Request) {
 	ar, err := Create(u, 0)
 
 	if err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
 		return
 	}
@@ -2,16 +2,16 @@ package credentials
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
-	"os"
 
 	"github.com/pkg/errors"
 	"gitlab.com/arnaud-web/neli-webservices/api"
 	"gitlab.com/arnaud-web/neli-webservices/api/auth/bearer"
 	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/background"
 	"gitlab.com/arnaud-web/neli-webservices/config"
 	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
 	"gitlab.com/arnaud-web/neli-webservices/mail"
 	"gitlab.com/arnaud-web/neli-webservices/random"
 	"golang.org/x/crypto/bcrypt"
@@ -28,8 +28,6 @@ type setPasswordInfo struct {
 	Token    string `json:"setPasswordToken"`
 }
 
-var logger = log.New(os.Stdout, "", log.LstdFlags|log.Lshortfile)
-
 // Login checks credentials then creates access and refresh tokens.
 // Parameters required are 'login' (string) and 'password' (string).
 // Parameter 'ttl' is optional (int).
@@ -40,7 +38,7 @@ func Login(w http.ResponseWriter, r *http.Request) {
 	var l loginInfo
 
 	if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
 		return
 	}
@@ -57,7 +55,7 @@ func Login(w http.ResponseWriter, r *http.Request) {
 	b, err := bearer.Create(u, l.TTL)
 
 	if err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusUnauthorized, messages.TechnicalError)
 		return
 	}
@@ -67,13 +65,13 @@ func Login(w http.ResponseWriter, r *http.Request) {
 
 // Reset generates a reinitialization token and send email to user.
 // The token is a random string.
-// The email function is called as a go routine.
+// The email is sent as background work.
 func Reset(w http.ResponseWriter, r *http.Request) {
 	var u models.User
 	var l loginInfo
 
 	if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
 		return
 	}
@@ -93,12 +91,12 @@ func Reset(w http.ResponseWriter, r *http.Request) {
 	t := random.String(*config.TokenSize)
 
 	if err := u.UpdatePasswordToken(t); err != nil {
-		log.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	go mail.ResetPassword(t, u.Email)
+	background.Go(r.Context(), "mail.reset", func() { mail.ResetPassword(r.Context(), t, u.Email) })
 
 	api.Send(w, http.StatusNoContent, "")
 }
@@ -110,7 +108,7 @@ func Set(w http.ResponseWriter, r *http.Request) {
 	var s setPasswordInfo
 
 	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
 		return
 	}
@@ -118,13 +116,13 @@ func Set(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	if s.Password == "" || s.Token == "" {
-		logger.Println("Password or Token empty")
+		logging.FromContext(r.Context()).Warn("password or token empty")
 		api.Send(w, http.StatusNoContent, "")
 		return
 	}
 
 	if err := u.FindByPasswordToken(s.Token); err != nil {
-		logger.Println("Token not found")
+		logging.FromContext(r.Context()).Warn("password token not found")
 		api.Send(w, http.StatusNoContent, "")
 		return
 	}
@@ -132,18 +130,18 @@ func Set(w http.ResponseWriter, r *http.Request) {
 	h, err := bcrypt.GenerateFromPassword([]byte(s.Password), bcrypt.DefaultCost)
 
 	if err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	if err = u.UpdatePassword(string(h)); err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	logger.Println("Password done")
+	logging.FromContext(r.Context()).Infof("password set for user %d", u.ID)
 	api.Send(w, http.StatusNoContent, "")
 }
 
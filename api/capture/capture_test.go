@@ -1,12 +1,15 @@
 package capture
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"net/http"
 	"testing"
+	"time"
 
 	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
 	"gitlab.com/arnaud-web/neli-webservices/test/assert"
 	"gitlab.com/arnaud-web/neli-webservices/test/expect"
 	"gitlab.com/arnaud-web/neli-webservices/test/stub"
@@ -18,19 +21,76 @@ func TestPlay(t *testing.T) {
 
 	uid := int64(rand.Uint64())
 	cid := expect.Content(uid, mock)
+	expect.Settings(mock)
+	expect.Usage(uid, models.Usage{}, mock)
+	expect.CaptureSessionNone(uid, mock)
+	expect.LeaderDevices(uid, mock)
 
 	p := stub.PlayRecord(3600)
 
 	rr, r := stub.PostHttpWithContext(uid, p)
 	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
 
-	playFunction = func(memberId int64, videoContentId int64, maxDuration int64) int16 {
-		return PLAYSTARTED
+	playFunction = func(ctx context.Context, memberId int64, videoContentId int64, maxDuration int64) error {
+		return nil
 	}
 
+	expect.CaptureSessionInsert(cid, uid, 0, 3600, mock)
+	expect.ContentRecording(cid, mock)
+
+	Play(rr, r)
+
+	assert.Response(t, rr, http.StatusNoContent, "")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPlayClampedByQuota(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	uid := int64(rand.Uint64())
+	cid := expect.Content(uid, mock)
+	expect.Settings(mock)
+	expect.Usage(uid, models.Usage{Seconds: 3000, MaxMinutes: 60}, mock)
+	expect.CaptureSessionNone(uid, mock)
+	expect.LeaderDevices(uid, mock)
+
+	p := stub.PlayRecord(3600)
+
+	rr, r := stub.PostHttpWithContext(uid, p)
+	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
+
+	playFunction = func(ctx context.Context, memberId int64, videoContentId int64, maxDuration int64) error {
+		return nil
+	}
+
+	expect.CaptureSessionInsert(cid, uid, 0, 600, mock)
+	expect.ContentRecording(cid, mock)
+
 	Play(rr, r)
 
 	assert.Response(t, rr, http.StatusNoContent, "")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPlayMinuteQuotaExceeded(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	uid := int64(rand.Uint64())
+	cid := expect.Content(uid, mock)
+	expect.Settings(mock)
+	expect.Usage(uid, models.Usage{Seconds: 3720, MaxMinutes: 60}, mock)
+
+	p := stub.PlayRecord(3600)
+
+	rr, r := stub.PostHttpWithContext(uid, p)
+	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
+
+	Play(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusForbidden, messages.MinuteQuotaExceeded)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestPlayNotFound(t *testing.T) {
@@ -45,8 +105,8 @@ func TestPlayNotFound(t *testing.T) {
 	rr, r := stub.PostHttpWithContext(uid, p)
 	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", 123))
 
-	playFunction = func(memberId int64, videoContentId int64, maxDuration int64) int16 {
-		return PLAYNOVIDEOINPUT
+	playFunction = func(ctx context.Context, memberId int64, videoContentId int64, maxDuration int64) error {
+		return ErrPlayNoVideoInput
 	}
 
 	Play(rr, r)
@@ -60,14 +120,18 @@ func TestPlayNoInputVideo(t *testing.T) {
 
 	uid := int64(rand.Uint64())
 	cid := expect.Content(uid, mock)
+	expect.Settings(mock)
+	expect.Usage(uid, models.Usage{}, mock)
+	expect.CaptureSessionNone(uid, mock)
+	expect.LeaderDevices(uid, mock)
 
 	p := stub.PlayRecord(3600)
 
 	rr, r := stub.PostHttpWithContext(uid, p)
 	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
 
-	playFunction = func(memberId int64, videoContentId int64, maxDuration int64) int16 {
-		return PLAYNOVIDEOINPUT
+	playFunction = func(ctx context.Context, memberId int64, videoContentId int64, maxDuration int64) error {
+		return ErrPlayNoVideoInput
 	}
 
 	Play(rr, r)
@@ -81,14 +145,18 @@ func TestPlayInProgress(t *testing.T) {
 
 	uid := int64(rand.Uint64())
 	cid := expect.Content(uid, mock)
+	expect.Settings(mock)
+	expect.Usage(uid, models.Usage{}, mock)
+	expect.CaptureSessionNone(uid, mock)
+	expect.LeaderDevices(uid, mock)
 
 	p := stub.PlayRecord(3600)
 
 	rr, r := stub.PostHttpWithContext(uid, p)
 	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
 
-	playFunction = func(memberId int64, videoContentId int64, maxDuration int64) int16 {
-		return PLAYALREADYINPROGRESS
+	playFunction = func(ctx context.Context, memberId int64, videoContentId int64, maxDuration int64) error {
+		return ErrPlayAlreadyInProgress
 	}
 
 	Play(rr, r)
@@ -102,14 +170,18 @@ func TestPlayNotBluetoothConnection(t *testing.T) {
 
 	uid := int64(rand.Uint64())
 	cid := expect.Content(uid, mock)
+	expect.Settings(mock)
+	expect.Usage(uid, models.Usage{}, mock)
+	expect.CaptureSessionNone(uid, mock)
+	expect.LeaderDevices(uid, mock)
 
 	p := stub.PlayRecord(3600)
 
 	rr, r := stub.PostHttpWithContext(uid, p)
 	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
 
-	playFunction = func(memberId int64, videoContentId int64, maxDuration int64) int16 {
-		return PLAYNOBTCONNECTION
+	playFunction = func(ctx context.Context, memberId int64, videoContentId int64, maxDuration int64) error {
+		return ErrPlayNoBTConnection
 	}
 
 	Play(rr, r)
@@ -123,14 +195,18 @@ func TestPlayNoServerConnection(t *testing.T) {
 
 	uid := int64(rand.Uint64())
 	cid := expect.Content(uid, mock)
+	expect.Settings(mock)
+	expect.Usage(uid, models.Usage{}, mock)
+	expect.CaptureSessionNone(uid, mock)
+	expect.LeaderDevices(uid, mock)
 
 	p := stub.PlayRecord(3600)
 
 	rr, r := stub.PostHttpWithContext(uid, p)
 	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
 
-	playFunction = func(memberId int64, videoContentId int64, maxDuration int64) int16 {
-		return PLAYNOSERVERCONNECTION
+	playFunction = func(ctx context.Context, memberId int64, videoContentId int64, maxDuration int64) error {
+		return ErrPlayNoServerConnection
 	}
 
 	Play(rr, r)
@@ -144,14 +220,18 @@ func TestPlayInternalError(t *testing.T) {
 
 	uid := int64(rand.Uint64())
 	cid := expect.Content(uid, mock)
+	expect.Settings(mock)
+	expect.Usage(uid, models.Usage{}, mock)
+	expect.CaptureSessionNone(uid, mock)
+	expect.LeaderDevices(uid, mock)
 
 	p := stub.PlayRecord(3600)
 
 	rr, r := stub.PostHttpWithContext(uid, p)
 	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
 
-	playFunction = func(memberId int64, videoContentId int64, maxDuration int64) int16 {
-		return 0
+	playFunction = func(ctx context.Context, memberId int64, videoContentId int64, maxDuration int64) error {
+		return ErrUnknown
 	}
 
 	Play(rr, r)
@@ -159,27 +239,119 @@ func TestPlayInternalError(t *testing.T) {
 	assert.Response(t, rr, http.StatusInternalServerError, "")
 }
 
+func TestPlayClampedDuration(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	uid := int64(rand.Uint64())
+	cid := expect.Content(uid, mock)
+	expect.Settings(mock)
+	expect.Usage(uid, models.Usage{}, mock)
+	expect.CaptureSessionNone(uid, mock)
+	expect.LeaderDevices(uid, mock)
+
+	rr, r := stub.PostHttpWithContext(uid, stub.PlayRecord(7200))
+	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
+
+	var d int64
+	playFunction = func(ctx context.Context, memberId int64, videoContentId int64, maxDuration int64) error {
+		d = maxDuration
+		return nil
+	}
+
+	expect.CaptureSessionInsert(cid, uid, 0, 3600, mock)
+	expect.ContentRecording(cid, mock)
+
+	Play(rr, r)
+
+	assert.Response(t, rr, http.StatusNoContent, "")
+
+	if d != 3600 {
+		t.Fatalf("Expected duration clamped to 3600, got %d", d)
+	}
+}
+
+func TestPlayInvalidDuration(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	uid := int64(rand.Uint64())
+	cid := expect.Content(uid, mock)
+
+	rr, r := stub.PostHttpWithContext(uid, stub.PlayRecord(-1))
+	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
+
+	Play(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusBadRequest, messages.InvalidParameters)
+}
+
+func TestPlayAlreadyRecorded(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	uid := int64(rand.Uint64())
+	cid := expect.ContentRecorded(uid, mock)
+
+	rr, r := stub.PostHttpWithContext(uid, stub.PlayRecord(3600))
+	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
+
+	Play(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusForbidden, messages.ContentAlreadyRecorded)
+}
+
+func TestPlayRunningSession(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	uid := int64(rand.Uint64())
+	cid := expect.Content(uid, mock)
+	expect.Settings(mock)
+	expect.Usage(uid, models.Usage{}, mock)
+	expect.CaptureSessionRunning(models.CaptureSession{LeaderID: uid, StartedAt: time.Now()}, mock)
+
+	rr, r := stub.PostHttpWithContext(uid, stub.PlayRecord(3600))
+	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
+
+	Play(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusForbidden, messages.CaptureAlreadyInProgress)
+}
+
 func TestStop(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
 	uid := int64(rand.Uint64())
+	expect.LeaderDevices(uid, mock)
 
 	rr, r := stub.PostHttpWithContext(uid, map[string]interface{}{})
 
-	stopFunction = func(memberId int64) (int64, int16) {
-		return 9, STOPDONE
+	stopFunction = func(ctx context.Context, memberId int64) (int64, error) {
+		return 9, nil
 	}
 
+	expect.ContentProcessing(uid, mock)
+	expect.CaptureStop(uid, 9, mock)
+
 	Stop(rr, r)
 
 	assert.Response(t, rr, http.StatusNoContent, "")
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestStopAuthenticationFailure(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
 	uid := int64(rand.Uint64())
+	expect.LeaderDevices(uid, mock)
 
 	rr, r := stub.PostHttpWithContext(uid, map[string]interface{}{})
 
-	stopFunction = func(memberId int64) (int64, int16) {
-		return 0, STOPAUTHFAILURE
+	stopFunction = func(ctx context.Context, memberId int64) (int64, error) {
+		return 0, ErrStopAuthFailure
 	}
 
 	Stop(rr, r)
@@ -188,12 +360,16 @@ func TestStopAuthenticationFailure(t *testing.T) {
 }
 
 func TestStopForbidden(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
 	uid := int64(rand.Uint64())
+	expect.LeaderDevices(uid, mock)
 
 	rr, r := stub.PostHttpWithContext(uid, map[string]interface{}{})
 
-	stopFunction = func(memberId int64) (int64, int16) {
-		return 0, STOPNOCAPTUREINPROGRESS
+	stopFunction = func(ctx context.Context, memberId int64) (int64, error) {
+		return 0, ErrStopNoCaptureInProgress
 	}
 
 	Stop(rr, r)
@@ -202,12 +378,16 @@ func TestStopForbidden(t *testing.T) {
 }
 
 func TestStopNoBluetoothConnection(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
 	uid := int64(rand.Uint64())
+	expect.LeaderDevices(uid, mock)
 
 	rr, r := stub.PostHttpWithContext(uid, map[string]interface{}{})
 
-	stopFunction = func(memberId int64) (int64, int16) {
-		return 0, STOPNOBTCONNECTION
+	stopFunction = func(ctx context.Context, memberId int64) (int64, error) {
+		return 0, ErrStopNoBTConnection
 	}
 
 	Stop(rr, r)
@@ -216,12 +396,16 @@ func TestStopNoBluetoothConnection(t *testing.T) {
 }
 
 func TestStopInternalError(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
 	uid := int64(rand.Uint64())
+	expect.LeaderDevices(uid, mock)
 
 	rr, r := stub.PostHttpWithContext(uid, map[string]interface{}{})
 
-	stopFunction = func(memberId int64) (int64, int16) {
-		return 0, -1
+	stopFunction = func(ctx context.Context, memberId int64) (int64, error) {
+		return 0, ErrUnknown
 	}
 
 	Stop(rr, r)
@@ -230,12 +414,16 @@ func TestStopInternalError(t *testing.T) {
 }
 
 func TestStatus(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
 	uid := int64(rand.Uint64())
+	expect.LeaderDevices(uid, mock)
 
 	rr, r := stub.HttpWithContext(uid)
 
-	statusFunction = func(memberId int64, status *recordStatus) int16 {
-		return STATUSCAPTUREINPROGRESS
+	statusFunction = func(ctx context.Context, memberId int64, status *recordStatus) error {
+		return nil
 	}
 
 	Status(rr, r)
@@ -246,12 +434,16 @@ func TestStatus(t *testing.T) {
 }
 
 func TestStatusNoCapture(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
 	uid := int64(rand.Uint64())
+	expect.LeaderDevices(uid, mock)
 
 	rr, r := stub.HttpWithContext(uid)
 
-	statusFunction = func(memberId int64, status *recordStatus) int16 {
-		return STATUSNOCAPTURE
+	statusFunction = func(ctx context.Context, memberId int64, status *recordStatus) error {
+		return ErrStatusNoCapture
 	}
 
 	Status(rr, r)
@@ -260,15 +452,70 @@ func TestStatusNoCapture(t *testing.T) {
 }
 
 func TestStatusInternalError(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
 	uid := int64(rand.Uint64())
+	expect.LeaderDevices(uid, mock)
 
 	rr, r := stub.HttpWithContext(uid)
 
-	statusFunction = func(memberId int64, status *recordStatus) int16 {
-		return 3
+	statusFunction = func(ctx context.Context, memberId int64, status *recordStatus) error {
+		return ErrUnknown
 	}
 
 	Status(rr, r)
 
 	assert.Response(t, rr, http.StatusInternalServerError, "")
 }
+
+func TestStatusNoProxyConnection(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	uid := int64(rand.Uint64())
+	expect.LeaderDevices(uid, mock)
+
+	rr, r := stub.HttpWithContext(uid)
+
+	statusFunction = func(ctx context.Context, memberId int64, status *recordStatus) error {
+		return ErrStatusNoProxyConnection
+	}
+
+	expect.CaptureSessionNone(uid, mock)
+
+	Status(rr, r)
+
+	assert.Response(t, rr, http.StatusGatewayTimeout, messages.NoProxyConnectionStopOrStatus)
+}
+
+func TestStatusFromSession(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	cs := models.CaptureSession{
+		ID:          int64(rand.Uint32()),
+		ContentID:   int64(rand.Uint32()),
+		LeaderID:    int64(rand.Uint32()),
+		MaxDuration: 3600,
+		StartedAt:   time.Now().Add(-time.Minute),
+	}
+
+	rr, r := stub.HttpWithContext(cs.LeaderID)
+	expect.LeaderDevices(cs.LeaderID, mock)
+
+	statusFunction = func(ctx context.Context, memberId int64, status *recordStatus) error {
+		return ErrStatusNoProxyConnection
+	}
+
+	expect.CaptureSessionRunning(cs, mock)
+
+	Status(rr, r)
+
+	b := rr.Body.String()
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, b, fmt.Sprintf(`"videoContentId":%d`, cs.ContentID))
+	assert.Contains(t, b, `"ellapsedTime":60`)
+	assert.Contains(t, b, `"estimated":true`)
+}
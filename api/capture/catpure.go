@@ -1,47 +1,27 @@
 package capture
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
-	"log"
-	"net"
+	"errors"
 	"net/http"
-	"os"
 	"strconv"
-	"time"
 
 	"github.com/go-chi/chi"
 	"gitlab.com/arnaud-web/neli-webservices/api"
 	"gitlab.com/arnaud-web/neli-webservices/api/messages"
 	"gitlab.com/arnaud-web/neli-webservices/config"
 	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
 )
 
-var logger = log.New(os.Stdout, "", log.LstdFlags|log.Lshortfile)
-
-const ERROR = -1
-
-const PLAYSTARTED = 1
-const PLAYNOVIDEOINPUT = 2
-const PLAYNOSERVERCONNECTION = 3
-const PLAYNOBTCONNECTION = 4
-const PLAYALREADYINPROGRESS = 5
-const PLAYNOPROXYCONNECTION = 6
-
-const STOPDONE = 1
-const STOPNOBTCONNECTION = 2
-const STOPNOCAPTUREINPROGRESS = 3
-const STOPAUTHFAILURE = 4
-const STOPNOPROXYCONNECTION = 5
-
-const STATUSNOCAPTURE = 0
-const STATUSCAPTUREINPROGRESS = 1
-const STATUSNOPROXYCONNECTION = 2
-
 type recordStatus struct {
 	VideoContentId int64 `json:"videoContentId"`
 	MaxDuration    int64 `json:"maxDuration"`
 	EllapsedTime   int64 `json:"ellapsedTime"`
+	// Estimated is set when the status is computed from the capture session
+	// because the proxy is unreachable
+	Estimated bool `json:"estimated,omitempty"`
 }
 
 var playFunction = playRecord
@@ -49,6 +29,7 @@ var stopFunction = stopRecord
 var statusFunction = getStatusRecord
 
 type Request struct {
+	ID       string `json:",omitempty"`
 	MemberId int64
 	Order    string
 	Arg1     int64
@@ -56,6 +37,7 @@ type Request struct {
 }
 
 type Response struct {
+	ID     string `json:",omitempty"`
 	Result string
 	Arg1   int64
 	Arg2   int64
@@ -67,55 +49,23 @@ type Response struct {
 //	 Order the N5 device to start a video transmission
 //
 //   INPUTS:
+//	ctx:			Request context carrying the logger
 // 	memberId:		Id of the leader ordering the transmission
 //	videoContentId:   	Id of the video to be created
 //	maxDuration: 		Max duration time of the video
 //
-//   RETURN CODE:
-//   	     One of the PLAY* const value
-
-func playRecord(memberId int64, videoContentId int64, maxDuration int64) int16 {
+//   RETURN VALUE:
+//   	     nil or one of the ErrPlay* errors
 
-	// Data
-	jsonObject := Request{
+func playRecord(ctx context.Context, memberId int64, videoContentId int64, maxDuration int64) error {
+	response := sendRequest(ctx, Request{
 		MemberId: memberId,
-		Order:    "play",
+		Order:    orderPlay,
 		Arg1:     videoContentId,
 		Arg2:     maxDuration,
-	}
-
-	// Request
-	response := sendRequest(jsonObject)
-
-	switch response.Result {
-	// Results
-	case "playstarted":
-		return PLAYSTARTED
-	case "nosource":
-		return PLAYNOVIDEOINPUT
-	case "noserver":
-		return PLAYNOSERVERCONNECTION
-	case "nobt":
-		return PLAYNOBTCONNECTION
-	case "busy":
-		return PLAYALREADYINPROGRESS
-
-		// Errors
-	case "conn_error":
-	case "json_error":
-	case "timeout":
-		return PLAYNOPROXYCONNECTION
-
-	case "unknown":
-		fmt.Println("Unknown code received from N7:", response.Arg1)
-		return ERROR
-
-	default:
-		fmt.Println("Unhandled result received from Proxy:", response.Result)
-		return ERROR
-	}
+	})
 
-	return ERROR
+	return resolve(ctx, orderPlay, response)
 }
 
 //
@@ -123,53 +73,24 @@ func playRecord(memberId int64, videoContentId int64, maxDuration int64) int16 {
 //	Order the N5 device to stop a video transmission
 //
 //   INPUT:
+//	ctx:                   Request context carrying the logger
 //	memberId:              Id of the leader ordering to stop the transmission
 //
 //   RETURN VALUES:
 //   	     The duration of the stopped record.
-//   	     One of the STOP* const value
+//   	     nil or one of the ErrStop* errors
 
-func stopRecord(memberId int64) (int64, int16) {
-
-	// Data
-	jsonObject := Request{
+func stopRecord(ctx context.Context, memberId int64) (int64, error) {
+	response := sendRequest(ctx, Request{
 		MemberId: memberId,
-		Order:    "stop",
-		Arg1:     0,
-		Arg2:     0,
-	}
-
-	// Request
-	response := sendRequest(jsonObject)
+		Order:    orderStop,
+	})
 
-	switch response.Result {
-	// Results
-	case "stopdone":
-		return response.Arg1, STOPDONE
-
-	case "nobt":
-		return 0, STOPNOBTCONNECTION
-	case "idle":
-		return 0, STOPNOCAPTUREINPROGRESS
-	case "authfailure":
-		return 0, STOPAUTHFAILURE
-
-		// Errors
-	case "conn_error":
-	case "json_error":
-	case "timeout":
-		return 0, STOPNOPROXYCONNECTION
-
-	case "unknown":
-		fmt.Println("Unknown code received from N5:", response.Arg1)
-		return 0, ERROR
-
-	default:
-		fmt.Println("Unhandled result received from Proxy:", response.Result)
-		return 0, ERROR
+	if err := resolve(ctx, orderStop, response); err != nil {
+		return 0, err
 	}
 
-	return 0, ERROR
+	return response.Arg1, nil
 }
 
 //
@@ -177,56 +98,39 @@ func stopRecord(memberId int64) (int64, int16) {
 //	Get, from the N5 device, the status of the current video transmission
 //
 //   INPUT:
+//	ctx:                   Request context carrying the logger
 //      memberId:              Id of the leader asking for the transmission status
 //
 //   OUTPUT:
 //	status:	               status of the current transmission
 //
 //   RETURN VALUE:
-//           One of the STATUS* const value
-func getStatusRecord(memberId int64, status *recordStatus) int16 {
-	jsonObject := Request{
+//           nil or one of the ErrStatus* errors
+func getStatusRecord(ctx context.Context, memberId int64, status *recordStatus) error {
+	response := sendRequest(ctx, Request{
 		MemberId: memberId,
-		Order:    "status",
-		Arg1:     0,
-		Arg2:     0,
-	}
-
-	// Request
-	response := sendRequest(jsonObject)
+		Order:    orderStatus,
+	})
 
-	switch response.Result {
-	// Results
-	case "status":
-		status.VideoContentId = response.Arg1
-		status.MaxDuration = response.Arg2
-		status.EllapsedTime = response.Arg3
-		return STATUSCAPTUREINPROGRESS
-
-	case "nostatus":
-		status.VideoContentId = 0
-		status.MaxDuration = 0
-		status.EllapsedTime = 0
-		return STATUSNOCAPTURE
-
-		// Errors
-	case "conn_error":
-	case "json_error":
-	case "timeout":
-		return STATUSNOPROXYCONNECTION
-
-	case "unknown":
-		fmt.Println("Unknown code received from N7:", response.Arg1)
-		return ERROR
-
-	default:
-		fmt.Println("Unhandled result received from Proxy:", response.Result)
-		return ERROR
+	if err := resolve(ctx, orderStatus, response); err != nil {
+		*status = recordStatus{}
+		return err
 	}
 
-	return ERROR
+	status.VideoContentId = response.Arg1
+	status.MaxDuration = response.Arg2
+	status.EllapsedTime = response.Arg3
+
+	return nil
 }
 
+// Play orders the device to capture the content.
+// The content must be created, failed or recording and the leader must not have
+// a running capture. The max duration is clamped to the settings one
+// and to the minutes left by the quota of the leader.
+// A capture session is recorded and the content is recording when the capture starts.
+// The order is sent to the device chosen with deviceId parameter
+// or to the first device assigned to the leader, the session records it.
 func Play(w http.ResponseWriter, r *http.Request) {
 	cid, _ := strconv.ParseInt(chi.URLParam(r, "videoContentId"), 10, 64)
 	lid := api.UserIdFromContext(r)
@@ -236,114 +140,207 @@ func Play(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !c.CanTransition(models.ContentRecording) {
+		api.SendError(w, http.StatusForbidden, messages.ContentAlreadyRecorded)
+		return
+	}
+
 	rs := recordStatus{}
 	if err := json.NewDecoder(r.Body).Decode(&rs); err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
 		return
 	}
 	defer r.Body.Close()
 
-	result := playFunction(lid, cid, rs.MaxDuration)
-	switch result {
-	case PLAYSTARTED:
-		api.Send(w, http.StatusNoContent, "")
-	case PLAYALREADYINPROGRESS:
-		api.SendError(w, http.StatusForbidden, messages.CaptureAlreadyInProgress)
-	case PLAYNOBTCONNECTION:
-		api.SendError(w, http.StatusBadGateway, messages.NoBluetoothConnection)
-	case PLAYNOVIDEOINPUT:
-		api.SendError(w, http.StatusServiceUnavailable, messages.NoVideoInput)
-	case PLAYNOSERVERCONNECTION:
-		api.SendError(w, http.StatusGatewayTimeout, messages.Timeout)
-	case PLAYNOPROXYCONNECTION:
-		api.SendError(w, http.StatusGatewayTimeout, messages.NoProxyConnection)
-	default:
-		api.SendError(w, http.StatusInternalServerError, "")
+	if rs.MaxDuration < 0 {
+		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
+		return
 	}
-}
 
-func Stop(w http.ResponseWriter, r *http.Request) {
-	lid := api.UserIdFromContext(r)
-	_, result := stopFunction(lid)
-	switch result {
-	case STOPDONE:
-		api.Send(w, http.StatusNoContent, nil)
-	case STOPAUTHFAILURE:
-		api.SendError(w, http.StatusUnauthorized, messages.InvalidToken)
-	case STOPNOCAPTUREINPROGRESS:
-		api.SendError(w, http.StatusForbidden, messages.CaptureAlreadyDone)
-	case STOPNOBTCONNECTION:
-		api.SendError(w, http.StatusBadGateway, messages.NoBluetoothConnection)
-	case STOPNOPROXYCONNECTION:
-		api.SendError(w, http.StatusGatewayTimeout, messages.NoProxyConnectionStopOrStatus)
-	default:
-		api.SendError(w, http.StatusInternalServerError, "")
+	s := models.Settings{}
+	if err := s.Load(); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
 	}
-}
 
-func Status(w http.ResponseWriter, r *http.Request) {
-	lid := api.UserIdFromContext(r)
-	rs := recordStatus{}
-	result := statusFunction(lid, &rs)
-	switch result {
-	case STATUSCAPTUREINPROGRESS:
-		api.Send(w, http.StatusOK, rs)
+	// No duration means as long as allowed
+	if rs.MaxDuration == 0 || rs.MaxDuration > int64(s.MaxDuration) {
+		logging.FromContext(r.Context()).Infof("max duration %d clamped to %d", rs.MaxDuration, s.MaxDuration)
+		rs.MaxDuration = int64(s.MaxDuration)
+	}
+
+	l := models.Leader{User: &models.User{ID: lid}}
+	u, err := l.Usage()
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	// The capture can't last longer than the minutes left to the leader
+	switch left := u.SecondsLeft(); {
+	case left == 0:
+		api.SendError(w, http.StatusForbidden, messages.MinuteQuotaExceeded)
 		return
-	case STATUSNOCAPTURE:
-		api.SendError(w, http.StatusNotFound, messages.NoCapture)
+	case left > 0 && rs.MaxDuration > left:
+		logging.FromContext(r.Context()).Infof("max duration %d clamped to quota %d", rs.MaxDuration, left)
+		rs.MaxDuration = left
+	}
+
+	// The proxy answers busy too but only for captures it knows
+	cs := models.CaptureSession{}
+	if err := cs.Running(lid); err == nil {
+		sendError(w, ErrPlayAlreadyInProgress)
+		return
+	}
+
+	ctx, err := device(r, lid)
+
+	if err != nil {
+		sendError(w, err)
 		return
-	case STATUSNOPROXYCONNECTION:
-		api.SendError(w, http.StatusGatewayTimeout, messages.NoProxyConnectionStopOrStatus)
+	}
+
+	if err := playFunction(ctx, lid, cid, rs.MaxDuration); err != nil {
+		sendError(w, err)
 		return
-	default:
-		api.SendError(w, http.StatusInternalServerError, "")
 	}
+
+	cs = models.CaptureSession{ContentID: cid, LeaderID: lid, MaxDuration: rs.MaxDuration}
+
+	if d, ok := deviceFromContext(ctx); ok {
+		cs.DeviceID = d.ID
+	}
+
+	// The capture is started, a session failure doesn't change the response
+	if err := cs.New(); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+	}
+
+	if err := c.Transition(models.ContentRecording); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+	}
+
+	api.Send(w, http.StatusNoContent, "")
 }
 
-func sendRequest(jsonObject Request) Response {
+// Stop orders the device to stop the capture of the leader.
+// Without deviceId parameter, the order goes to the device of the running capture.
+// The duration returned by the device is recorded in the capture session
+// and the content is processing until the hub calls ready.
+func Stop(w http.ResponseWriter, r *http.Request) {
+	lid := api.UserIdFromContext(r)
+	ctx, err := device(r, lid)
 
-	jsonData, err := json.Marshal(jsonObject)
 	if err != nil {
-		fmt.Println("Json Marshal error:", err)
-		return Response{Result: "json_error"}
+		sendError(w, err)
+		return
 	}
 
-	// Request
-	connection, err := net.Dial("udp", *config.ProxyBluetooth)
+	d, err := stopFunction(ctx, lid)
+
 	if err != nil {
-		fmt.Println("Could not resolve udp address or connect to it on", *config.ProxyBluetooth)
-		fmt.Println(err)
-		return Response{Result: "conn_error"}
+		sendError(w, err)
+		return
 	}
 
-	n, err := connection.Write(jsonData)
+	if err := models.ProcessCapture(lid); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+	}
+
+	if err := models.StopCapture(lid, d); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+	}
+
+	api.Send(w, http.StatusNoContent, nil)
+}
+
+// Status returns the running capture of the leader.
+// When the proxy is unreachable, it is estimated from the capture session.
+// The device is chosen as in Stop.
+func Status(w http.ResponseWriter, r *http.Request) {
+	lid := api.UserIdFromContext(r)
+	ctx, err := device(r, lid)
 
 	if err != nil {
-		fmt.Println("Error writing data to server", *config.ProxyBluetooth)
-		fmt.Println(err)
-		return Response{Result: "conn_error"}
+		sendError(w, err)
+		return
+	}
+
+	rs := recordStatus{}
+	err = statusFunction(ctx, lid, &rs)
+
+	if err == ErrStatusNoProxyConnection {
+		cs := models.CaptureSession{}
+
+		if cs.Running(lid) == nil {
+			rs = recordStatus{
+				VideoContentId: cs.ContentID,
+				MaxDuration:    cs.MaxDuration,
+				EllapsedTime:   cs.Elapsed(),
+				Estimated:      true,
+			}
+
+			err = nil
+		}
 	}
 
-	// Response
-	recvBuf := make([]byte, 1024)
-	connection.SetReadDeadline(time.Now().Add(1 * time.Second))
-	n, err = connection.Read(recvBuf)
 	if err != nil {
-		fmt.Println(err)
-		return Response{Result: "timeout"}
+		sendError(w, err)
+		return
+	}
+
+	api.Send(w, http.StatusOK, rs)
+}
+
+// Ping sends a status order to the proxy.
+// It only fails when the proxy can't be reached or answers garbage,
+// whatever the capture status is.
+func Ping(ctx context.Context) error {
+	response := sendRequest(ctx, Request{Order: "status"})
+
+	switch response.Result {
+	case resultConnError, resultJSONError, resultTimeout:
+		return errors.New(response.Result)
 	}
 
-	//fmt.Printf("Received data: %s\n", string(recvBuf[:n]))
-	connection.Close()
+	return nil
+}
 
-	var response Response
-	err = json.Unmarshal(recvBuf[:n], &response)
+// sendRequest sends the order through the configured client.
+// An ID is generated to match the response with the request.
+// Transport failures are returned as conn_error, json_error or timeout results.
+func sendRequest(ctx context.Context, jsonObject Request) Response {
+	jsonObject.ID = requestID(ctx)
+	logger := logging.FromContext(ctx).
+		With("order", jsonObject.Order).
+		With("member_id", jsonObject.MemberId).
+		With("capture_id", jsonObject.ID)
+
+	addr := *config.ProxyBluetooth
+
+	if d, ok := deviceFromContext(ctx); ok {
+		logger = logger.With("device_id", d.ID)
+		addr = d.Address
+	}
+
+	response, err := clientFor(ctx).Send(ctx, jsonObject)
 
 	if err != nil {
-		fmt.Println("JSON Unmarshal error:", err)
-		return Response{Result: "json_error"}
+		logger.Errorf("no valid response from proxy %s: %v", addr, err)
+
+		if e, ok := err.(*transportError); ok {
+			return Response{ID: jsonObject.ID, Result: e.result}
+		}
+
+		return Response{ID: jsonObject.ID, Result: resultConnError}
 	}
 
+	logger.Debugf("proxy answered %s", response.Result)
+
+	seen(ctx)
+
 	return response
 }
@@ -0,0 +1,315 @@
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"gitlab.com/arnaud-web/neli-webservices/config"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
+)
+
+// Transports available for the capture proxy
+const (
+	TransportUDP  = "udp"
+	TransportTCP  = "tcp"
+	TransportHTTP = "http"
+)
+
+// Results returned in place of proxy results when the transport fails
+const (
+	resultConnError = "conn_error"
+	resultJSONError = "json_error"
+	resultTimeout   = "timeout"
+)
+
+// Max size of an UDP datagram received from proxy
+const datagramSize = 64 * 1024
+
+// CaptureClient sends an order to the capture proxy and returns its response.
+// The response ID matches the request ID when the proxy echoes it.
+type CaptureClient interface {
+	Send(ctx context.Context, req Request) (Response, error)
+}
+
+// transportError is returned by clients when no valid response is received.
+// Result is the value sent back to handlers in place of the proxy result.
+type transportError struct {
+	result string
+	err    error
+}
+
+func (e *transportError) Error() string {
+	return fmt.Sprintf("%s: %v", e.result, e.err)
+}
+
+// wrap classifies err as a timeout or a connection error
+func wrap(err error) error {
+	if e, ok := err.(net.Error); ok && e.Timeout() {
+		return &transportError{result: resultTimeout, err: err}
+	}
+
+	if err == context.DeadlineExceeded {
+		return &transportError{result: resultTimeout, err: err}
+	}
+
+	return &transportError{result: resultConnError, err: err}
+}
+
+var client = NewClient(*config.ProxyTransport, *config.ProxyBluetooth,
+	time.Duration(*config.ProxyTimeout)*time.Millisecond, *config.ProxyRetries)
+
+// CheckTransport returns an error when the transport is unknown
+func CheckTransport(transport string) error {
+	switch transport {
+	case TransportUDP, TransportTCP, TransportHTTP:
+		return nil
+	}
+
+	return fmt.Errorf("unknown proxy transport %q, it has to be %s, %s or %s",
+		transport, TransportUDP, TransportTCP, TransportHTTP)
+}
+
+// NewClient creates a client for the transport.
+// Unknown transports fall back to UDP which is the historical proxy protocol,
+// the configured transport is checked on start with CheckTransport.
+// A failed status order is sent again up to retries times. Play and stop orders
+// are never sent again, the proxy may have executed them before timing out.
+func NewClient(transport, addr string, timeout time.Duration, retries int) CaptureClient {
+	var c CaptureClient
+
+	switch transport {
+	case TransportTCP:
+		c = &tcpClient{addr: addr, timeout: timeout}
+	case TransportHTTP:
+		if !strings.HasPrefix(addr, "http://") && !strings.HasPrefix(addr, "https://") {
+			addr = "http://" + addr
+		}
+
+		c = &httpClient{url: addr, client: &http.Client{Timeout: timeout}}
+	default:
+		c = &udpClient{addr: addr, timeout: timeout}
+	}
+
+	if retries > 0 {
+		c = &retryClient{client: c, retries: retries}
+	}
+
+	return c
+}
+
+var sequence uint64
+
+// requestID generates an ID prefixed by the http request id when available
+func requestID(ctx context.Context) string {
+	n := atomic.AddUint64(&sequence, 1)
+
+	if id := middleware.GetReqID(ctx); id != "" {
+		return fmt.Sprintf("%s-%d", id, n)
+	}
+
+	return fmt.Sprintf("capture-%d", n)
+}
+
+// matches checks the response ID.
+// Proxies which don't echo the ID are trusted.
+func matches(req Request, res Response) bool {
+	return res.ID == "" || res.ID == req.ID
+}
+
+// deadline returns the earliest of ctx deadline and now plus timeout
+func deadline(ctx context.Context, timeout time.Duration) time.Time {
+	d := time.Now().Add(timeout)
+
+	if cd, ok := ctx.Deadline(); ok && cd.Before(d) {
+		return cd
+	}
+
+	return d
+}
+
+type udpClient struct {
+	addr    string
+	timeout time.Duration
+}
+
+// Send writes one datagram then reads datagrams until the matching response.
+// Responses to previous timed out requests are ignored.
+func (c *udpClient) Send(ctx context.Context, req Request) (Response, error) {
+	var res Response
+
+	b, err := json.Marshal(req)
+
+	if err != nil {
+		return res, &transportError{result: resultJSONError, err: err}
+	}
+
+	conn, err := net.Dial("udp", c.addr)
+
+	if err != nil {
+		return res, wrap(err)
+	}
+
+	defer conn.Close()
+
+	conn.SetDeadline(deadline(ctx, c.timeout))
+
+	if _, err := conn.Write(b); err != nil {
+		return res, wrap(err)
+	}
+
+	buf := make([]byte, datagramSize)
+
+	for {
+		n, err := conn.Read(buf)
+
+		if err != nil {
+			return res, wrap(err)
+		}
+
+		logging.FromContext(ctx).Debugf("received data: %s", string(buf[:n]))
+
+		res = Response{}
+
+		if err := json.Unmarshal(buf[:n], &res); err != nil {
+			return res, &transportError{result: resultJSONError, err: err}
+		}
+
+		if matches(req, res) {
+			return res, nil
+		}
+	}
+}
+
+type tcpClient struct {
+	addr    string
+	timeout time.Duration
+}
+
+// Send writes the order as a json line then reads json lines
+// until the matching response.
+func (c *tcpClient) Send(ctx context.Context, req Request) (Response, error) {
+	var res Response
+
+	b, err := json.Marshal(req)
+
+	if err != nil {
+		return res, &transportError{result: resultJSONError, err: err}
+	}
+
+	d := net.Dialer{Deadline: deadline(ctx, c.timeout)}
+	conn, err := d.Dial("tcp", c.addr)
+
+	if err != nil {
+		return res, wrap(err)
+	}
+
+	defer conn.Close()
+
+	conn.SetDeadline(deadline(ctx, c.timeout))
+
+	if _, err := conn.Write(append(b, '\n')); err != nil {
+		return res, wrap(err)
+	}
+
+	s := bufio.NewScanner(conn)
+	s.Buffer(make([]byte, 4096), datagramSize)
+
+	for s.Scan() {
+		logging.FromContext(ctx).Debugf("received data: %s", s.Text())
+
+		res = Response{}
+
+		if err := json.Unmarshal(s.Bytes(), &res); err != nil {
+			return res, &transportError{result: resultJSONError, err: err}
+		}
+
+		if matches(req, res) {
+			return res, nil
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return res, wrap(err)
+	}
+
+	return res, &transportError{result: resultConnError, err: fmt.Errorf("connection closed by %s", c.addr)}
+}
+
+type httpClient struct {
+	url    string
+	client *http.Client
+}
+
+// Send posts the order as json and decodes the response body.
+// The ID is also sent in X-Request-Id header.
+func (c *httpClient) Send(ctx context.Context, req Request) (Response, error) {
+	var res Response
+
+	b, err := json.Marshal(req)
+
+	if err != nil {
+		return res, &transportError{result: resultJSONError, err: err}
+	}
+
+	r, err := http.NewRequest("POST", c.url, bytes.NewReader(b))
+
+	if err != nil {
+		return res, wrap(err)
+	}
+
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-Request-Id", req.ID)
+
+	hr, err := c.client.Do(r.WithContext(ctx))
+
+	if err != nil {
+		return res, wrap(err)
+	}
+
+	defer hr.Body.Close()
+
+	if hr.StatusCode != http.StatusOK {
+		return res, &transportError{result: resultConnError, err: fmt.Errorf("proxy answered %s", hr.Status)}
+	}
+
+	if err := json.NewDecoder(hr.Body).Decode(&res); err != nil {
+		return res, &transportError{result: resultJSONError, err: err}
+	}
+
+	if !matches(req, res) {
+		return res, &transportError{result: resultJSONError, err: fmt.Errorf("response %s to request %s", res.ID, req.ID)}
+	}
+
+	return res, nil
+}
+
+type retryClient struct {
+	client  CaptureClient
+	retries int
+}
+
+// Send retries status orders on transport errors only, answers of the proxy are final.
+// Other orders aren't idempotent, they are sent once.
+func (c *retryClient) Send(ctx context.Context, req Request) (Response, error) {
+	res, err := c.client.Send(ctx, req)
+
+	if req.Order != orderStatus {
+		return res, err
+	}
+
+	for i := 0; i < c.retries && err != nil && ctx.Err() == nil; i++ {
+		logging.FromContext(ctx).Warnf("retrying order %s %s after %v", req.Order, req.ID, err)
+		res, err = c.client.Send(ctx, req)
+	}
+
+	return res, err
+}
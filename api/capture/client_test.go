@@ -0,0 +1,177 @@
+package capture
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gitlab.com/arnaud-web/neli-webservices/test/assert"
+)
+
+type fakeClient struct {
+	calls int
+	res   Response
+	err   error
+}
+
+func (c *fakeClient) Send(ctx context.Context, req Request) (Response, error) {
+	c.calls++
+	c.res.ID = req.ID
+	return c.res, c.err
+}
+
+func TestUDPClient(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer pc.Close()
+
+	go func() {
+		buf := make([]byte, datagramSize)
+		n, addr, _ := pc.ReadFrom(buf)
+
+		var req Request
+		json.Unmarshal(buf[:n], &req)
+
+		// A late response of a previous order has to be ignored
+		old, _ := json.Marshal(Response{ID: "old", Result: "busy"})
+		pc.WriteTo(old, addr)
+
+		b, _ := json.Marshal(Response{ID: req.ID, Result: "playstarted"})
+		pc.WriteTo(b, addr)
+	}()
+
+	c := NewClient(TransportUDP, pc.LocalAddr().String(), time.Second, 0)
+	res, err := c.Send(context.Background(), Request{ID: "1", Order: "play"})
+
+	assert.NoError(t, err)
+	assert.Equals(t, res.Result, "playstarted")
+}
+
+func TestUDPClient_Timeout(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer pc.Close()
+
+	c := NewClient(TransportUDP, pc.LocalAddr().String(), 10*time.Millisecond, 0)
+	_, err = c.Send(context.Background(), Request{ID: "1", Order: "status"})
+
+	e, ok := err.(*transportError)
+
+	if !ok {
+		t.Fatalf("Expected transport error, got %v", err)
+	}
+
+	assert.Equals(t, e.result, resultTimeout)
+}
+
+func TestTCPClient(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer l.Close()
+
+	go func() {
+		conn, err := l.Accept()
+
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		s := bufio.NewScanner(conn)
+		s.Scan()
+
+		var req Request
+		json.Unmarshal(s.Bytes(), &req)
+
+		b, _ := json.Marshal(Response{ID: req.ID, Result: "stopdone", Arg1: 42})
+		conn.Write(append(b, '\n'))
+	}()
+
+	c := NewClient(TransportTCP, l.Addr().String(), time.Second, 0)
+	res, err := c.Send(context.Background(), Request{ID: "1", Order: "stop"})
+
+	assert.NoError(t, err)
+	assert.Equals(t, res.Result, "stopdone")
+	assert.NotZero(t, res.Arg1)
+}
+
+func TestHTTPClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		json.NewDecoder(r.Body).Decode(&req)
+
+		json.NewEncoder(w).Encode(Response{ID: r.Header.Get("X-Request-Id"), Result: "nostatus"})
+	}))
+	defer srv.Close()
+
+	c := NewClient(TransportHTTP, srv.URL, time.Second, 0)
+	res, err := c.Send(context.Background(), Request{ID: "1", Order: "status"})
+
+	assert.NoError(t, err)
+	assert.Equals(t, res.Result, "nostatus")
+}
+
+func TestHTTPClient_Mismatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Response{ID: "other", Result: "nostatus"})
+	}))
+	defer srv.Close()
+
+	c := NewClient(TransportHTTP, srv.URL, time.Second, 0)
+	_, err := c.Send(context.Background(), Request{ID: "1", Order: "status"})
+
+	assert.Error(t, err)
+}
+
+func TestRetryClient(t *testing.T) {
+	f := &fakeClient{err: &transportError{result: resultTimeout, err: errors.New("timeout")}}
+	c := &retryClient{client: f, retries: 2}
+
+	_, err := c.Send(context.Background(), Request{ID: "1", Order: orderStatus})
+
+	assert.Error(t, err)
+
+	if f.calls != 3 {
+		t.Fatalf("Expected 3 calls, got %d", f.calls)
+	}
+}
+
+func TestRetryClient_Play(t *testing.T) {
+	f := &fakeClient{err: &transportError{result: resultTimeout, err: errors.New("timeout")}}
+	c := &retryClient{client: f, retries: 2}
+
+	_, err := c.Send(context.Background(), Request{ID: "1", Order: orderPlay})
+
+	assert.Error(t, err)
+
+	if f.calls != 1 {
+		t.Fatalf("Expected 1 call, got %d", f.calls)
+	}
+}
+
+func TestCheckTransport(t *testing.T) {
+	assert.NoError(t, CheckTransport(TransportTCP))
+	assert.Error(t, CheckTransport("udp6"))
+}
+
+func TestSendRequest(t *testing.T) {
+	defer func(c CaptureClient) { client = c }(client)
+
+	client = &fakeClient{err: &transportError{result: resultTimeout, err: errors.New("timeout")}}
+	res := sendRequest(context.Background(), Request{Order: "status"})
+
+	assert.Equals(t, res.Result, resultTimeout)
+	assert.NotEmpty(t, res.ID)
+
+	client = &fakeClient{res: Response{Result: "status", Arg1: 42}}
+	res = sendRequest(context.Background(), Request{Order: "status"})
+
+	assert.Equals(t, res.Result, "status")
+}
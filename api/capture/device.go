@@ -0,0 +1,110 @@
+package capture
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gitlab.com/arnaud-web/neli-webservices/config"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
+)
+
+type deviceKey struct{}
+
+// withDevice returns a context sending the orders to the device
+func withDevice(ctx context.Context, d models.Device) context.Context {
+	return context.WithValue(ctx, deviceKey{}, d)
+}
+
+// deviceFromContext returns the device receiving the orders, if any
+func deviceFromContext(ctx context.Context) (models.Device, bool) {
+	d, ok := ctx.Value(deviceKey{}).(models.Device)
+	return d, ok
+}
+
+// device returns the request context carrying the device of the leader.
+// The deviceId query parameter chooses one of the devices assigned to the leader,
+// otherwise the device recording the running capture, then the oldest one.
+// Leaders without device send their orders to the configured proxy.
+func device(r *http.Request, lid int64) (context.Context, error) {
+	l, err := models.LeaderDevices(lid)
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		return nil, err
+	}
+
+	p := r.URL.Query().Get("deviceId")
+
+	if p == "" {
+		if len(l) == 0 {
+			return r.Context(), nil
+		}
+
+		return withDevice(r.Context(), recording(lid, l)), nil
+	}
+
+	did, err := strconv.ParseInt(p, 10, 64)
+
+	if err != nil {
+		return nil, ErrDeviceNotAssigned
+	}
+
+	for _, d := range l {
+		if d.ID == did {
+			return withDevice(r.Context(), d), nil
+		}
+	}
+
+	return nil, ErrDeviceNotAssigned
+}
+
+// recording returns the device of the running capture of the leader
+// when it is still assigned, the oldest device otherwise
+func recording(lid int64, l []models.Device) models.Device {
+	if len(l) == 1 {
+		return l[0]
+	}
+
+	cs := models.CaptureSession{}
+
+	if err := cs.Running(lid); err != nil {
+		return l[0]
+	}
+
+	for _, d := range l {
+		if d.ID == cs.DeviceID {
+			return d
+		}
+	}
+
+	return l[0]
+}
+
+// clientFor returns the client of the device carried by the context,
+// the configured client otherwise
+func clientFor(ctx context.Context) CaptureClient {
+	d, ok := deviceFromContext(ctx)
+
+	if !ok {
+		return client
+	}
+
+	return NewClient(d.Transport, d.Address,
+		time.Duration(*config.ProxyTimeout)*time.Millisecond, *config.ProxyRetries)
+}
+
+// seen records that the device carried by the context answered
+func seen(ctx context.Context) {
+	d, ok := deviceFromContext(ctx)
+
+	if !ok {
+		return
+	}
+
+	if err := models.DeviceSeen(d.ID); err != nil {
+		logging.FromContext(ctx).Error(err)
+	}
+}
@@ -0,0 +1,125 @@
+package capture
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/test/assert"
+	"gitlab.com/arnaud-web/neli-webservices/test/expect"
+	"gitlab.com/arnaud-web/neli-webservices/test/stub"
+)
+
+func TestDevice(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	uid := int64(rand.Uint32())
+	first, second := stub.Device(), stub.Device()
+
+	expect.LeaderDevices(uid, mock, first, second)
+	expect.CaptureSessionNone(uid, mock)
+
+	_, r := stub.HttpWithContext(uid)
+	ctx, err := device(r, uid)
+	assert.NoError(t, err)
+
+	d, ok := deviceFromContext(ctx)
+
+	if !ok || d.ID != first.ID {
+		t.Fatalf("Expected the first device, got %+v", d)
+	}
+
+	expect.LeaderDevices(uid, mock, first, second)
+
+	r.URL.RawQuery = fmt.Sprintf("deviceId=%d", second.ID)
+	ctx, err = device(r, uid)
+	assert.NoError(t, err)
+
+	if d, _ := deviceFromContext(ctx); d.ID != second.ID {
+		t.Fatalf("Expected the chosen device, got %+v", d)
+	}
+}
+
+func TestDeviceRecording(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	uid := int64(rand.Uint32())
+	first, second := stub.Device(), stub.Device()
+
+	expect.LeaderDevices(uid, mock, first, second)
+	expect.CaptureSessionRunning(models.CaptureSession{
+		ID: 1, ContentID: 2, LeaderID: uid, DeviceID: second.ID, MaxDuration: 60, StartedAt: time.Now(),
+	}, mock)
+
+	_, r := stub.HttpWithContext(uid)
+	ctx, err := device(r, uid)
+	assert.NoError(t, err)
+
+	if d, _ := deviceFromContext(ctx); d.ID != second.ID {
+		t.Fatalf("Expected the device of the running capture, got %+v", d)
+	}
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeviceNone(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	uid := int64(rand.Uint32())
+	expect.LeaderDevices(uid, mock)
+
+	_, r := stub.HttpWithContext(uid)
+	ctx, err := device(r, uid)
+	assert.NoError(t, err)
+
+	if _, ok := deviceFromContext(ctx); ok {
+		t.Fatal("Expected no device")
+	}
+
+	if clientFor(ctx) != client {
+		t.Fatal("Expected the configured client")
+	}
+}
+
+func TestStatusDeviceNotAssigned(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	uid := int64(rand.Uint32())
+	expect.LeaderDevices(uid, mock, stub.Device())
+
+	rr, r := stub.HttpWithContext(uid)
+	r.URL.RawQuery = "deviceId=1"
+
+	Status(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusNotFound, messages.InvalidDeviceId)
+}
+
+func TestSendRequestDevice(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Response{Result: "nostatus"})
+	}))
+	defer srv.Close()
+
+	d := models.Device{ID: int64(rand.Uint32()), Address: srv.URL, Transport: TransportHTTP}
+	expect.DeviceSeen(d.ID, mock)
+
+	res := sendRequest(withDevice(context.Background(), d), Request{Order: orderStatus})
+
+	assert.Equals(t, res.Result, "nostatus")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
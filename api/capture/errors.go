@@ -0,0 +1,136 @@
+package capture
+
+import (
+	"context"
+	"net/http"
+
+	"gitlab.com/arnaud-web/neli-webservices/api"
+	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
+	"gitlab.com/arnaud-web/neli-webservices/metrics"
+)
+
+// Orders understood by the proxy
+const (
+	orderPlay   = "play"
+	orderStop   = "stop"
+	orderStatus = "status"
+)
+
+// Error is a failure of a capture order.
+// Name is the historical PLAY*, STOP* or STATUS* code used in metrics,
+// status and message are sent to the client.
+type Error struct {
+	Name    string
+	Status  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Name
+}
+
+// Failures of play orders
+var (
+	ErrPlayNoVideoInput       = &Error{"PLAYNOVIDEOINPUT", http.StatusServiceUnavailable, messages.NoVideoInput}
+	ErrPlayNoServerConnection = &Error{"PLAYNOSERVERCONNECTION", http.StatusGatewayTimeout, messages.Timeout}
+	ErrPlayNoBTConnection     = &Error{"PLAYNOBTCONNECTION", http.StatusBadGateway, messages.NoBluetoothConnection}
+	ErrPlayAlreadyInProgress  = &Error{"PLAYALREADYINPROGRESS", http.StatusForbidden, messages.CaptureAlreadyInProgress}
+	ErrPlayNoProxyConnection  = &Error{"PLAYNOPROXYCONNECTION", http.StatusGatewayTimeout, messages.NoProxyConnection}
+)
+
+// Failures of stop orders
+var (
+	ErrStopNoBTConnection      = &Error{"STOPNOBTCONNECTION", http.StatusBadGateway, messages.NoBluetoothConnection}
+	ErrStopNoCaptureInProgress = &Error{"STOPNOCAPTUREINPROGRESS", http.StatusForbidden, messages.CaptureAlreadyDone}
+	ErrStopAuthFailure         = &Error{"STOPAUTHFAILURE", http.StatusUnauthorized, messages.InvalidToken}
+	ErrStopNoProxyConnection   = &Error{"STOPNOPROXYCONNECTION", http.StatusGatewayTimeout, messages.NoProxyConnectionStopOrStatus}
+)
+
+// Failures of status orders
+var (
+	ErrStatusNoCapture         = &Error{"STATUSNOCAPTURE", http.StatusNotFound, messages.NoCapture}
+	ErrStatusNoProxyConnection = &Error{"STATUSNOPROXYCONNECTION", http.StatusGatewayTimeout, messages.NoProxyConnectionStopOrStatus}
+)
+
+// ErrDeviceNotAssigned is returned when the device chosen by the leader
+// is not assigned to them
+var ErrDeviceNotAssigned = &Error{"DEVICENOTASSIGNED", http.StatusNotFound, messages.InvalidDeviceId}
+
+// ErrUnknown is returned for results unknown by the proxy or by the service
+var ErrUnknown = &Error{"ERROR", http.StatusInternalServerError, ""}
+
+// Names of successful orders used in metrics
+var succeeded = map[string]string{
+	orderPlay:   "PLAYSTARTED",
+	orderStop:   "STOPDONE",
+	orderStatus: "STATUSCAPTUREINPROGRESS",
+}
+
+// results maps each proxy result to the outcome of the order, nil on success.
+// Transport failures are considered as proxy results so they share the table.
+// A result missing for an order is an unknown result.
+var results = map[string]map[string]error{
+	orderPlay: {
+		"playstarted":   nil,
+		"nosource":      ErrPlayNoVideoInput,
+		"noserver":      ErrPlayNoServerConnection,
+		"nobt":          ErrPlayNoBTConnection,
+		"busy":          ErrPlayAlreadyInProgress,
+		resultConnError: ErrPlayNoProxyConnection,
+		resultJSONError: ErrPlayNoProxyConnection,
+		resultTimeout:   ErrPlayNoProxyConnection,
+	},
+	orderStop: {
+		"stopdone":      nil,
+		"nobt":          ErrStopNoBTConnection,
+		"idle":          ErrStopNoCaptureInProgress,
+		"authfailure":   ErrStopAuthFailure,
+		resultConnError: ErrStopNoProxyConnection,
+		resultJSONError: ErrStopNoProxyConnection,
+		resultTimeout:   ErrStopNoProxyConnection,
+	},
+	orderStatus: {
+		"status":        nil,
+		"nostatus":      ErrStatusNoCapture,
+		resultConnError: ErrStatusNoProxyConnection,
+		resultJSONError: ErrStatusNoProxyConnection,
+		resultTimeout:   ErrStatusNoProxyConnection,
+	},
+}
+
+// resolve returns the outcome of the order from the proxy response.
+// The outcome is counted in metrics.
+func resolve(ctx context.Context, order string, response Response) error {
+	err, ok := results[order][response.Result]
+
+	switch {
+	case !ok && response.Result == "unknown":
+		logging.FromContext(ctx).Warnf("unknown code received from device on %s: %d", order, response.Arg1)
+		err = ErrUnknown
+	case !ok:
+		logging.FromContext(ctx).Warnf("unhandled result received from proxy on %s: %s", order, response.Result)
+		err = ErrUnknown
+	}
+
+	name := succeeded[order]
+
+	if e, ok := err.(*Error); ok {
+		name = e.Name
+	}
+
+	metrics.Capture.WithLabelValues(order, name).Inc()
+
+	return err
+}
+
+// sendError sends the status and message of a capture error.
+// Other errors are technical errors.
+func sendError(w http.ResponseWriter, err error) {
+	if e, ok := err.(*Error); ok {
+		api.SendError(w, e.Status, e.Message)
+		return
+	}
+
+	api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+}
@@ -0,0 +1,89 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/test/assert"
+)
+
+func TestResolve(t *testing.T) {
+	cases := []struct {
+		order  string
+		result string
+		err    error
+	}{
+		{orderPlay, "playstarted", nil},
+		{orderPlay, "nosource", ErrPlayNoVideoInput},
+		{orderPlay, "noserver", ErrPlayNoServerConnection},
+		{orderPlay, "nobt", ErrPlayNoBTConnection},
+		{orderPlay, "busy", ErrPlayAlreadyInProgress},
+		{orderPlay, "conn_error", ErrPlayNoProxyConnection},
+		{orderPlay, "json_error", ErrPlayNoProxyConnection},
+		{orderPlay, "timeout", ErrPlayNoProxyConnection},
+		{orderPlay, "unknown", ErrUnknown},
+		{orderPlay, "stopdone", ErrUnknown},
+		{orderStop, "stopdone", nil},
+		{orderStop, "nobt", ErrStopNoBTConnection},
+		{orderStop, "idle", ErrStopNoCaptureInProgress},
+		{orderStop, "authfailure", ErrStopAuthFailure},
+		{orderStop, "conn_error", ErrStopNoProxyConnection},
+		{orderStop, "json_error", ErrStopNoProxyConnection},
+		{orderStop, "timeout", ErrStopNoProxyConnection},
+		{orderStop, "unknown", ErrUnknown},
+		{orderStop, "", ErrUnknown},
+		{orderStatus, "status", nil},
+		{orderStatus, "nostatus", ErrStatusNoCapture},
+		{orderStatus, "conn_error", ErrStatusNoProxyConnection},
+		{orderStatus, "json_error", ErrStatusNoProxyConnection},
+		{orderStatus, "timeout", ErrStatusNoProxyConnection},
+		{orderStatus, "unknown", ErrUnknown},
+		{orderStatus, "busy", ErrUnknown},
+	}
+
+	for _, c := range cases {
+		if err := resolve(context.Background(), c.order, Response{Result: c.result, Arg1: 7}); err != c.err {
+			t.Errorf("%s %q: expected %v, got %v", c.order, c.result, c.err, err)
+		}
+	}
+}
+
+func TestPlayRecord_ConnError(t *testing.T) {
+	defer func(c CaptureClient) { client = c }(client)
+
+	client = &fakeClient{err: &transportError{result: resultConnError, err: errors.New("refused")}}
+
+	err := playRecord(context.Background(), 1, 2, 60)
+
+	if err != ErrPlayNoProxyConnection {
+		t.Fatalf("Expected %v, got %v", ErrPlayNoProxyConnection, err)
+	}
+}
+
+func TestStopRecord_Duration(t *testing.T) {
+	defer func(c CaptureClient) { client = c }(client)
+
+	client = &fakeClient{res: Response{Result: "stopdone", Arg1: 42}}
+
+	d, err := stopRecord(context.Background(), 1)
+
+	assert.NoError(t, err)
+
+	if d != 42 {
+		t.Fatalf("Expected 42, got %d", d)
+	}
+}
+
+func TestSendError(t *testing.T) {
+	rr := httptest.NewRecorder()
+	sendError(rr, ErrPlayNoProxyConnection)
+	assert.ResponseError(t, rr, http.StatusGatewayTimeout, messages.NoProxyConnection)
+
+	rr = httptest.NewRecorder()
+	sendError(rr, errors.New("technical"))
+	assert.ResponseError(t, rr, http.StatusInternalServerError, messages.TechnicalError)
+}
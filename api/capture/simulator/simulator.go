@@ -0,0 +1,220 @@
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+
+	"gitlab.com/arnaud-web/neli-webservices/api/capture"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
+)
+
+// Faults the simulator can reproduce
+const (
+	// FaultNone answers as a working N5 device
+	FaultNone = ""
+	// FaultNoBT answers nobt to every order
+	FaultNoBT = "nobt"
+	// FaultNoSource answers nosource to play orders
+	FaultNoSource = "nosource"
+	// FaultNoServer answers noserver to play orders
+	FaultNoServer = "noserver"
+	// FaultAuth answers authfailure to stop orders
+	FaultAuth = "authfailure"
+	// FaultTimeout never answers
+	FaultTimeout = "timeout"
+	// FaultGarbage answers a datagram which is not json
+	FaultGarbage = "garbage"
+)
+
+// Faults lists the valid fault modes
+var Faults = []string{FaultNone, FaultNoBT, FaultNoSource, FaultNoServer, FaultAuth, FaultTimeout, FaultGarbage}
+
+// Max size of a datagram received by the simulator
+const datagramSize = 64 * 1024
+
+type record struct {
+	contentID   int64
+	maxDuration int64
+	started     time.Time
+}
+
+// Simulator reproduces the proxy and the N5 devices behind it.
+// Each leader has at most one capture which stops by itself
+// when its max duration is reached.
+type Simulator struct {
+	mu      sync.Mutex
+	fault   string
+	records map[int64]*record
+	now     func() time.Time
+}
+
+// New creates a simulator without fault
+func New() *Simulator {
+	return &Simulator{
+		records: map[int64]*record{},
+		now:     time.Now,
+	}
+}
+
+// SetFault changes the fault mode, FaultNone restores normal behaviour
+func (s *Simulator) SetFault(f string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fault = f
+}
+
+// Fault returns the current fault mode
+func (s *Simulator) Fault() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.fault
+}
+
+// Handle executes an order and returns the response.
+// The boolean is false when no response has to be sent.
+func (s *Simulator) Handle(req capture.Request) (capture.Response, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fault == FaultTimeout {
+		return capture.Response{}, false
+	}
+
+	res := capture.Response{ID: req.ID}
+
+	if s.fault == FaultNoBT {
+		res.Result = "nobt"
+		return res, true
+	}
+
+	r := s.current(req.MemberId)
+
+	switch req.Order {
+	case "play":
+		switch {
+		case s.fault == FaultNoSource:
+			res.Result = "nosource"
+		case s.fault == FaultNoServer:
+			res.Result = "noserver"
+		case r != nil:
+			res.Result = "busy"
+		default:
+			s.records[req.MemberId] = &record{contentID: req.Arg1, maxDuration: req.Arg2, started: s.now()}
+			res.Result = "playstarted"
+		}
+
+	case "stop":
+		switch {
+		case s.fault == FaultAuth:
+			res.Result = "authfailure"
+		case r == nil:
+			res.Result = "idle"
+		default:
+			delete(s.records, req.MemberId)
+			res.Result = "stopdone"
+			res.Arg1 = s.elapsed(r)
+		}
+
+	case "status":
+		if r == nil {
+			res.Result = "nostatus"
+			break
+		}
+
+		res.Result = "status"
+		res.Arg1 = r.contentID
+		res.Arg2 = r.maxDuration
+		res.Arg3 = s.elapsed(r)
+
+	default:
+		res.Result = "unknown"
+		res.Arg1 = -1
+	}
+
+	return res, true
+}
+
+// current returns the capture of the leader.
+// A capture which reached its max duration is stopped.
+func (s *Simulator) current(lid int64) *record {
+	r, ok := s.records[lid]
+
+	if !ok {
+		return nil
+	}
+
+	if r.maxDuration > 0 && s.elapsed(r) >= r.maxDuration {
+		delete(s.records, lid)
+		return nil
+	}
+
+	return r
+}
+
+// elapsed returns the capture duration in seconds
+func (s *Simulator) elapsed(r *record) int64 {
+	return int64(s.now().Sub(r.started) / time.Second)
+}
+
+// Serve answers the orders received on conn until it's closed
+func (s *Simulator) Serve(ctx context.Context, conn net.PacketConn) error {
+	buf := make([]byte, datagramSize)
+
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+
+		if err != nil {
+			return err
+		}
+
+		var req capture.Request
+
+		if err := json.Unmarshal(buf[:n], &req); err != nil {
+			logging.FromContext(ctx).Warnf("invalid order from %s: %v", addr, err)
+			continue
+		}
+
+		res, ok := s.Handle(req)
+
+		logging.FromContext(ctx).
+			With("capture_id", req.ID).
+			With("member_id", req.MemberId).
+			Infof("%s order answered %q", req.Order, res.Result)
+
+		if !ok {
+			continue
+		}
+
+		b, err := json.Marshal(res)
+
+		if err != nil {
+			return err
+		}
+
+		if s.Fault() == FaultGarbage {
+			b = []byte("garbage")
+		}
+
+		if _, err := conn.WriteTo(b, addr); err != nil {
+			logging.FromContext(ctx).Warnf("cannot answer to %s: %v", addr, err)
+		}
+	}
+}
+
+// ListenAndServe listens on the UDP address then serves orders
+func (s *Simulator) ListenAndServe(ctx context.Context, addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	return s.Serve(ctx, conn)
+}
@@ -0,0 +1,122 @@
+package simulator
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"gitlab.com/arnaud-web/neli-webservices/api/capture"
+	"gitlab.com/arnaud-web/neli-webservices/test/assert"
+)
+
+func clock(s *Simulator) *time.Time {
+	t := time.Now()
+	s.now = func() time.Time { return t }
+	return &t
+}
+
+func TestHandle(t *testing.T) {
+	s := New()
+	now := clock(s)
+
+	res, _ := s.Handle(capture.Request{ID: "1", MemberId: 1, Order: "play", Arg1: 42, Arg2: 60})
+	assert.Equals(t, res.Result, "playstarted")
+	assert.Equals(t, res.ID, "1")
+
+	res, _ = s.Handle(capture.Request{MemberId: 1, Order: "play", Arg1: 43, Arg2: 60})
+	assert.Equals(t, res.Result, "busy")
+
+	*now = now.Add(10 * time.Second)
+
+	res, _ = s.Handle(capture.Request{MemberId: 1, Order: "status"})
+	assert.Equals(t, res.Result, "status")
+
+	if res.Arg1 != 42 || res.Arg2 != 60 || res.Arg3 != 10 {
+		t.Fatalf("Unexpected status %+v", res)
+	}
+
+	res, _ = s.Handle(capture.Request{MemberId: 2, Order: "status"})
+	assert.Equals(t, res.Result, "nostatus")
+
+	res, _ = s.Handle(capture.Request{MemberId: 1, Order: "stop"})
+	assert.Equals(t, res.Result, "stopdone")
+
+	if res.Arg1 != 10 {
+		t.Fatalf("Expected 10 seconds, got %d", res.Arg1)
+	}
+
+	res, _ = s.Handle(capture.Request{MemberId: 1, Order: "stop"})
+	assert.Equals(t, res.Result, "idle")
+
+	res, _ = s.Handle(capture.Request{MemberId: 1, Order: "rewind"})
+	assert.Equals(t, res.Result, "unknown")
+}
+
+func TestHandle_MaxDuration(t *testing.T) {
+	s := New()
+	now := clock(s)
+
+	s.Handle(capture.Request{MemberId: 1, Order: "play", Arg1: 42, Arg2: 5})
+
+	*now = now.Add(5 * time.Second)
+
+	res, _ := s.Handle(capture.Request{MemberId: 1, Order: "status"})
+	assert.Equals(t, res.Result, "nostatus")
+
+	res, _ = s.Handle(capture.Request{MemberId: 1, Order: "play", Arg1: 43, Arg2: 5})
+	assert.Equals(t, res.Result, "playstarted")
+}
+
+func TestHandle_Faults(t *testing.T) {
+	s := New()
+
+	cases := []struct {
+		fault  string
+		order  string
+		result string
+	}{
+		{FaultNoBT, "status", "nobt"},
+		{FaultNoSource, "play", "nosource"},
+		{FaultNoServer, "play", "noserver"},
+		{FaultAuth, "stop", "authfailure"},
+	}
+
+	for _, c := range cases {
+		s.SetFault(c.fault)
+
+		res, ok := s.Handle(capture.Request{MemberId: 1, Order: c.order})
+
+		if !ok {
+			t.Fatalf("Expected a response for %s", c.fault)
+		}
+
+		assert.Equals(t, res.Result, c.result)
+	}
+
+	s.SetFault(FaultTimeout)
+
+	if _, ok := s.Handle(capture.Request{MemberId: 1, Order: "status"}); ok {
+		t.Fatal("Expected no response")
+	}
+}
+
+func TestServe(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	s := New()
+	go s.Serve(context.Background(), conn)
+
+	c := capture.NewClient(capture.TransportUDP, conn.LocalAddr().String(), time.Second, 0)
+
+	res, err := c.Send(context.Background(), capture.Request{ID: "1", MemberId: 1, Order: "play", Arg1: 42, Arg2: 60})
+	assert.NoError(t, err)
+	assert.Equals(t, res.Result, "playstarted")
+
+	s.SetFault(FaultGarbage)
+
+	_, err = c.Send(context.Background(), capture.Request{ID: "2", MemberId: 1, Order: "status"})
+	assert.Error(t, err)
+}
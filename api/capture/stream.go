@@ -0,0 +1,308 @@
+package capture
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"gitlab.com/arnaud-web/neli-webservices/api"
+	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/config"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
+)
+
+// Events sent on the status stream
+const (
+	eventStatus = "status"
+	eventStop   = "stop"
+	eventReady  = "ready"
+	eventError  = "error"
+)
+
+// Delay before a browser reconnects a closed stream, in milliseconds
+const retry = 1000
+
+// Max number of events waiting for a slow subscriber.
+// Older status events are dropped when the buffer is full.
+const buffer = 16
+
+type event struct {
+	name string
+	data interface{}
+}
+
+// feed polls the proxy for a leader and fans out events to subscribers
+type feed struct {
+	subs      map[chan event]bool
+	contentID int64
+	cancel    context.CancelFunc
+}
+
+// broker holds a feed per leader having at least one subscriber.
+// status is the order sent by pollers.
+type broker struct {
+	mu       sync.Mutex
+	feeds    map[int64]*feed
+	interval time.Duration
+	status   func(context.Context, int64, *recordStatus) error
+}
+
+var streams = newBroker(time.Duration(*config.StreamInterval)*time.Second, getStatusRecord)
+
+func newBroker(interval time.Duration, status func(context.Context, int64, *recordStatus) error) *broker {
+	return &broker{
+		feeds:    map[int64]*feed{},
+		interval: interval,
+		status:   status,
+	}
+}
+
+// subscribe returns a channel receiving the events of the leader.
+// The poller of the leader is started by the first subscriber
+// and polls the device carried by its context.
+func (b *broker) subscribe(ctx context.Context, lid int64) chan event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan event, buffer)
+	f, ok := b.feeds[lid]
+
+	if !ok {
+		pctx := logging.NewContext(context.Background(),
+			logging.FromContext(ctx).With("stream_leader_id", lid))
+
+		if d, ok := deviceFromContext(ctx); ok {
+			pctx = withDevice(pctx, d)
+		}
+
+		pctx, cancel := context.WithCancel(pctx)
+
+		f = &feed{subs: map[chan event]bool{}, cancel: cancel}
+		b.feeds[lid] = f
+
+		go b.poll(pctx, lid)
+	}
+
+	f.subs[ch] = true
+
+	return ch
+}
+
+// unsubscribe removes the channel, the poller stops with the last subscriber
+func (b *broker) unsubscribe(lid int64, ch chan event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	f, ok := b.feeds[lid]
+
+	if !ok || !f.subs[ch] {
+		return
+	}
+
+	delete(f.subs, ch)
+	close(ch)
+
+	if len(f.subs) == 0 {
+		f.cancel()
+		delete(b.feeds, lid)
+	}
+}
+
+// publish sends the event to all subscribers of the leader.
+// A status is dropped for a subscriber whose buffer is full.
+func (b *broker) publish(lid int64, e event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	f, ok := b.feeds[lid]
+
+	if !ok {
+		return
+	}
+
+	f.track(e)
+
+	for ch := range f.subs {
+		offer(ch, e)
+	}
+}
+
+// publishTo sends the event to one subscriber of the leader only
+func (b *broker) publishTo(lid int64, ch chan event, e event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	f, ok := b.feeds[lid]
+
+	if !ok || !f.subs[ch] {
+		return
+	}
+
+	f.track(e)
+	offer(ch, e)
+}
+
+// track records the content awaited by the feed from a status event
+func (f *feed) track(e event) {
+	if s, ok := e.data.(recordStatus); ok && s.VideoContentId != 0 {
+		f.contentID = s.VideoContentId
+	}
+}
+
+// offer sends the event unless the buffer of the subscriber is full
+func offer(ch chan event, e event) {
+	select {
+	case ch <- e:
+	default:
+	}
+}
+
+// ready sends a ready event to the leaders waiting for the content
+// then closes their streams
+func (b *broker) ready(cid int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for lid, f := range b.feeds {
+		if f.contentID != cid {
+			continue
+		}
+
+		for ch := range f.subs {
+			select {
+			case ch <- event{eventReady, map[string]int64{"videoContentId": cid}}:
+			default:
+			}
+
+			close(ch)
+		}
+
+		f.cancel()
+		delete(b.feeds, lid)
+	}
+}
+
+// poll sends the status of the leader capture at each interval.
+// A stop event is sent when a running capture is over.
+func (b *broker) poll(ctx context.Context, lid int64) {
+	t := time.NewTicker(b.interval)
+	defer t.Stop()
+
+	running := false
+
+	for {
+		rs := recordStatus{}
+		err := b.status(ctx, lid, &rs)
+
+		switch {
+		case err == nil:
+			running = true
+			b.publish(lid, event{eventStatus, rs})
+		case err == ErrStatusNoCapture:
+			if running {
+				b.publish(lid, event{eventStop, rs})
+			}
+
+			running = false
+		default:
+			if e, ok := err.(*Error); ok {
+				b.publish(lid, event{eventError, api.JSONError{Code: e.Status, Message: e.Message}})
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// NotifyReady closes the status streams of the content.
+// It's called when the hub reports the content as ready.
+func NotifyReady(cid int64) {
+	streams.ready(cid)
+}
+
+// Stream sends the capture status of the leader as Server-Sent Events.
+// The proxy is polled once per leader whatever the number of streams,
+// on the device chosen as in Play by the first stream.
+// Events are:
+// * status, with the same data as Status, while a capture runs
+// * stop, when the capture is over
+// * ready, when the hub processed the content, then the stream is closed
+// * error, with the same data as an error response, when the proxy fails
+// EventSource can't set headers, the token can be passed with jwt query parameter.
+// The stream is closed after stream_lifetime seconds, browsers reconnect it.
+func Stream(w http.ResponseWriter, r *http.Request) {
+	fl, ok := w.(http.Flusher)
+
+	if !ok {
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	lid := api.UserIdFromContext(r)
+	ctx, err := device(r, lid)
+
+	if err != nil {
+		sendError(w, err)
+		return
+	}
+
+	if *config.StreamLifetime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(*config.StreamLifetime)*time.Second)
+		defer cancel()
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", retry)
+	fl.Flush()
+
+	ch := streams.subscribe(ctx, lid)
+	defer streams.unsubscribe(lid, ch)
+
+	// The running capture tells the new stream which content is awaited
+	// even before the first poll or when the proxy is unreachable,
+	// the other streams already have the polled status
+	cs := models.CaptureSession{}
+	if err := cs.Running(lid); err == nil {
+		streams.publishTo(lid, ch, event{eventStatus, recordStatus{
+			VideoContentId: cs.ContentID,
+			MaxDuration:    cs.MaxDuration,
+			EllapsedTime:   cs.Elapsed(),
+			Estimated:      true,
+		}})
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			b, err := json.Marshal(e.data)
+
+			if err != nil {
+				logging.FromContext(r.Context()).Error(err)
+				continue
+			}
+
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.name, b)
+			fl.Flush()
+		}
+	}
+}
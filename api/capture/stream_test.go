@@ -0,0 +1,253 @@
+package capture
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gitlab.com/arnaud-web/neli-webservices/api"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/test/assert"
+	"gitlab.com/arnaud-web/neli-webservices/test/expect"
+	"gitlab.com/arnaud-web/neli-webservices/test/stub"
+)
+
+func receive(t *testing.T, ch chan event) event {
+	select {
+	case e, ok := <-ch:
+		if !ok {
+			t.Fatal("Unexpected closed stream")
+		}
+
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("No event received")
+	}
+
+	return event{}
+}
+
+func TestBrokerFanOut(t *testing.T) {
+	var polls int32
+	cid := int64(rand.Uint32())
+
+	b := newBroker(time.Millisecond, func(ctx context.Context, memberId int64, status *recordStatus) error {
+		atomic.AddInt32(&polls, 1)
+		status.VideoContentId = cid
+		return nil
+	})
+
+	first := b.subscribe(context.Background(), 1)
+	second := b.subscribe(context.Background(), 1)
+
+	if len(b.feeds) != 1 {
+		t.Fatalf("Expected one feed, got %d", len(b.feeds))
+	}
+
+	for _, ch := range []chan event{first, second} {
+		e := receive(t, ch)
+		assert.Equals(t, e.name, eventStatus)
+
+		if e.data.(recordStatus).VideoContentId != cid {
+			t.Fatalf("Unexpected status %+v", e.data)
+		}
+	}
+
+	b.unsubscribe(1, first)
+
+	if len(b.feeds) != 1 {
+		t.Fatal("Expected the feed to remain for the second subscriber")
+	}
+
+	b.unsubscribe(1, second)
+
+	if len(b.feeds) != 0 {
+		t.Fatal("Expected the feed to be removed")
+	}
+
+	// Let a running poll end then check no more polls happen
+	time.Sleep(10 * time.Millisecond)
+	n := atomic.LoadInt32(&polls)
+	time.Sleep(10 * time.Millisecond)
+
+	if atomic.LoadInt32(&polls) != n {
+		t.Fatal("Expected the poller to be stopped")
+	}
+}
+
+func TestBrokerPublishTo(t *testing.T) {
+	b := newBroker(time.Hour, func(ctx context.Context, memberId int64, status *recordStatus) error {
+		return ErrStatusNoCapture
+	})
+
+	first := b.subscribe(context.Background(), 1)
+	second := b.subscribe(context.Background(), 1)
+	defer b.unsubscribe(1, first)
+	defer b.unsubscribe(1, second)
+
+	b.publishTo(1, second, event{eventStatus, recordStatus{VideoContentId: 42, Estimated: true}})
+
+	if e := receive(t, second); !e.data.(recordStatus).Estimated {
+		t.Fatalf("Unexpected status %+v", e.data)
+	}
+
+	select {
+	case e := <-first:
+		t.Fatalf("Expected no event for the other subscriber, got %+v", e)
+	default:
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.feeds[1].contentID != 42 {
+		t.Fatalf("Expected content 42 to be tracked, got %d", b.feeds[1].contentID)
+	}
+}
+
+func TestBrokerStop(t *testing.T) {
+	var polls int32
+
+	b := newBroker(time.Millisecond, func(ctx context.Context, memberId int64, status *recordStatus) error {
+		if atomic.AddInt32(&polls, 1) == 1 {
+			return nil
+		}
+
+		return ErrStatusNoCapture
+	})
+
+	ch := b.subscribe(context.Background(), 1)
+	defer b.unsubscribe(1, ch)
+
+	assert.Equals(t, receive(t, ch).name, eventStatus)
+	assert.Equals(t, receive(t, ch).name, eventStop)
+}
+
+func TestBrokerError(t *testing.T) {
+	b := newBroker(time.Hour, func(ctx context.Context, memberId int64, status *recordStatus) error {
+		return ErrStatusNoProxyConnection
+	})
+
+	ch := b.subscribe(context.Background(), 1)
+	defer b.unsubscribe(1, ch)
+
+	e := receive(t, ch)
+
+	assert.Equals(t, e.name, eventError)
+	if e.data.(api.JSONError).Code != http.StatusGatewayTimeout {
+		t.Fatalf("Unexpected error %+v", e.data)
+	}
+}
+
+func TestBrokerReady(t *testing.T) {
+	cid := int64(rand.Uint32())
+
+	b := newBroker(time.Hour, func(ctx context.Context, memberId int64, status *recordStatus) error {
+		status.VideoContentId = cid + memberId
+		return nil
+	})
+
+	ch := b.subscribe(context.Background(), 1)
+	other := b.subscribe(context.Background(), 2)
+	defer b.unsubscribe(2, other)
+
+	receive(t, ch)
+	receive(t, other)
+
+	b.ready(cid + 1)
+
+	assert.Equals(t, receive(t, ch).name, eventReady)
+
+	if _, ok := <-ch; ok {
+		t.Fatal("Expected a closed stream")
+	}
+
+	if len(b.feeds) != 1 {
+		t.Fatal("Expected only the feed of the other leader")
+	}
+
+	// Already removed by ready
+	b.unsubscribe(1, ch)
+}
+
+func TestStream(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	cs := models.CaptureSession{
+		ID:          int64(rand.Uint32()),
+		ContentID:   int64(rand.Uint32()),
+		LeaderID:    int64(rand.Uint32()),
+		MaxDuration: 3600,
+		StartedAt:   time.Now().Add(-time.Minute),
+	}
+
+	streams = newBroker(time.Hour, func(ctx context.Context, memberId int64, status *recordStatus) error {
+		return ErrStatusNoCapture
+	})
+
+	expect.LeaderDevices(cs.LeaderID, mock)
+	expect.CaptureSessionRunning(cs, mock)
+
+	rr, r := stub.HttpWithContext(cs.LeaderID)
+	done := make(chan bool)
+
+	go func() {
+		Stream(rr, r)
+		close(done)
+	}()
+
+	// The content is known once the session is read
+	for i := 0; ; i++ {
+		NotifyReady(cs.ContentID)
+
+		select {
+		case <-done:
+		case <-time.After(10 * time.Millisecond):
+			if i == 100 {
+				t.Fatal("Stream not closed")
+			}
+
+			continue
+		}
+
+		break
+	}
+
+	b := rr.Body.String()
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Equals(t, rr.Header().Get("Content-Type"), "text/event-stream")
+	assert.Contains(t, b, "retry: 1000\n\n")
+	assert.Contains(t, b, fmt.Sprintf("event: status\ndata: {\"videoContentId\":%d", cs.ContentID))
+	assert.Contains(t, b, `"estimated":true`)
+	assert.Contains(t, b, fmt.Sprintf("event: ready\ndata: {\"videoContentId\":%d}\n\n", cs.ContentID))
+}
+
+func TestStreamClientGone(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint32())
+
+	streams = newBroker(time.Hour, func(ctx context.Context, memberId int64, status *recordStatus) error {
+		return ErrStatusNoCapture
+	})
+
+	expect.LeaderDevices(lid, mock)
+	expect.CaptureSessionNone(lid, mock)
+
+	rr, r := stub.HttpWithContext(lid)
+	ctx, cancel := context.WithCancel(r.Context())
+	cancel()
+
+	Stream(rr, r.WithContext(ctx))
+
+	assert.Response(t, rr, http.StatusOK, "")
+	if len(streams.feeds) != 0 {
+		t.Fatal("Expected the feed to be removed")
+	}
+}
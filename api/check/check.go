@@ -1,9 +1,7 @@
 package check
 
 import (
-	"log"
 	"net/http"
-	"os"
 	"strings"
 
 	"github.com/go-chi/jwtauth"
@@ -12,8 +10,6 @@ import (
 	"gitlab.com/arnaud-web/neli-webservices/db/models"
 )
 
-var logger = log.New(os.Stdout, "", log.LstdFlags|log.Lshortfile)
-
 // Authenticator validate JWT token
 func Authenticator(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
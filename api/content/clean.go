@@ -0,0 +1,160 @@
+package content
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"gitlab.com/arnaud-web/neli-webservices/api/asset"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
+	"gitlab.com/arnaud-web/neli-webservices/storage"
+)
+
+// Clean removes the expired uploads with their partial files
+// then runs the pending cleanings: the contents attached to zombie
+// until their date are removed from the storage then from database.
+// A cleaning is done once all its contents are removed,
+// a failing one is tried again on the next run.
+func Clean(ctx context.Context) error {
+	if err := expire(ctx); err != nil {
+		return err
+	}
+
+	l, err := models.PendingCleanings()
+
+	if err != nil {
+		return err
+	}
+
+	for _, c := range l {
+		if err := clean(ctx, c); err != nil {
+			return err
+		}
+
+		logging.FromContext(ctx).Infof("cleaning %d is done", c.ID)
+	}
+
+	return nil
+}
+
+// expire removes the expired uploads and their partial files.
+// An upload receiving a chunk is removed on the next run.
+func expire(ctx context.Context) error {
+	l, err := models.ExpiredUploads()
+
+	if err != nil {
+		return err
+	}
+
+	for _, u := range l {
+		if !lockUpload(u.ContentID) {
+			continue
+		}
+
+		err := os.Remove(partFile(u))
+
+		if err == nil || os.IsNotExist(err) {
+			err = u.Delete()
+		}
+
+		unlockUpload(u.ContentID)
+
+		if err != nil {
+			return err
+		}
+
+		logging.FromContext(ctx).Infof("upload %d of content %d expired", u.ID, u.ContentID)
+	}
+
+	return nil
+}
+
+// clean removes the contents of the cleaning and marks it done
+func clean(ctx context.Context, cl models.Cleaning) error {
+	l, err := cl.Contents()
+
+	if err != nil {
+		return err
+	}
+
+	for _, c := range l {
+		keys, err := assetKeys(ctx, c)
+
+		if err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			if err := storage.Default.Delete(ctx, k); err != nil && err != storage.ErrNotExist {
+				return err
+			}
+		}
+
+		if err := c.Delete(); err != nil {
+			return err
+		}
+
+		logging.FromContext(ctx).Infof("content %d deleted", c.ID)
+	}
+
+	return cl.Done()
+}
+
+// assetKeys returns the storage keys of the posters and the renditions of the content,
+// with the variants and segments of its HLS playlists
+func assetKeys(ctx context.Context, c models.Content) ([]string, error) {
+	keys := []string{models.PosterKey(c.ID), models.HubPosterKey(c.ID)}
+
+	if c.Path == "" {
+		return keys, nil
+	}
+
+	l, err := models.FindRenditions(c.ID, c.Path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+
+	for _, r := range l {
+		seen[r.Key(c.Path)] = true
+		keys = append(keys, r.Key(c.Path))
+	}
+
+	// Playlists are listed before their entries, which are appended while iterating.
+	// An entry is listed once so playlists referencing each other end.
+	for i := 0; i < len(keys); i++ {
+		if path.Ext(keys[i]) != ".m3u8" {
+			continue
+		}
+
+		o, _, err := storage.Default.Get(ctx, keys[i])
+
+		if err == storage.ErrNotExist {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := ioutil.ReadAll(o)
+		o.Close()
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, k := range asset.PlaylistKeys(keys[i], b) {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+
+	return keys, nil
+}
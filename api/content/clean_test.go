@@ -0,0 +1,103 @@
+package content
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"os"
+	"testing"
+
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/storage"
+	"gitlab.com/arnaud-web/neli-webservices/test/assert"
+	"gitlab.com/arnaud-web/neli-webservices/test/expect"
+	"gitlab.com/arnaud-web/neli-webservices/test/stub"
+)
+
+func TestClean(t *testing.T) {
+	defer assets(t)()
+
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	ctx := context.Background()
+	recorded := models.Content{ID: int64(rand.Uint32()), Path: "abcdef"}
+	empty := models.Content{ID: int64(rand.Uint32())}
+
+	keys := map[string]string{
+		"abcdef/abcdef_360.mp4":          "mp4",
+		"abcdef/hls/master.m3u8":         "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000\n360p.m3u8\n",
+		"abcdef/hls/360p.m3u8":           "#EXTM3U\n#EXTINF:10.0,\n360p_0.ts\n#EXTINF:10.0,\nhttps://cdn.example.com/360p_1.ts\n",
+		"abcdef/hls/360p_0.ts":           "ts",
+		models.PosterKey(recorded.ID):    "jpeg",
+		models.HubPosterKey(recorded.ID): "jpeg",
+	}
+
+	for k, v := range keys {
+		assert.NoError(t, storage.Default.Put(ctx, k, bytes.NewReader([]byte(v))))
+	}
+
+	expect.ExpiredUploads(mock)
+	expect.Cleanings(mock, 1)
+	expect.CleaningContents(mock, recorded, empty)
+	expect.Renditions(mock,
+		models.Rendition{Name: "360p", Type: models.RenditionMP4, File: "abcdef_360.mp4"},
+		models.Rendition{Name: "hls", Type: models.RenditionHLS, File: "hls/master.m3u8"},
+	)
+	expect.ContentDelete(recorded.ID, mock)
+	expect.ContentDelete(empty.ID, mock)
+	expect.CleaningDone(1, mock)
+
+	assert.NoError(t, Clean(ctx))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	for k := range keys {
+		if _, err := storage.Default.Stat(ctx, k); err != storage.ErrNotExist {
+			t.Fatalf("Expected %s to be removed, got %v", k, err)
+		}
+	}
+}
+
+func TestClean_ExpiredUploads(t *testing.T) {
+	defer assets(t)()
+
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	u := models.Upload{ID: int64(rand.Uint32()), ContentID: int64(rand.Uint32()), Path: "abcdef", Length: 1024}
+	started(t, u, make([]byte, 100))
+
+	expect.ExpiredUploads(mock, u)
+	expect.UploadDelete(u.ID, mock)
+	expect.Cleanings(mock)
+
+	assert.NoError(t, Clean(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	if _, err := os.Stat(partFile(u)); !os.IsNotExist(err) {
+		t.Fatalf("Expected the partial file to be removed, got %v", err)
+	}
+}
+
+func TestClean_Failure(t *testing.T) {
+	defer assets(t)()
+
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	c := models.Content{ID: int64(rand.Uint32())}
+
+	expect.ExpiredUploads(mock)
+	expect.Cleanings(mock, 1)
+	expect.CleaningContents(mock, c)
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM upload (.+)").WillReturnError(errors.New("connection lost"))
+	mock.ExpectRollback()
+
+	if err := Clean(context.Background()); err == nil {
+		t.Fatal("Expected the cleaning to fail")
+	}
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,123 @@
+package content
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi"
+	"gitlab.com/arnaud-web/neli-webservices/api"
+	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
+)
+
+// maxCommentSize is the max number of characters of a comment
+const maxCommentSize = 2000
+
+// Comments returns the comments of a content ordered by offset,
+// to the users allowed to watch it as in Markers
+func Comments(w http.ResponseWriter, r *http.Request) {
+	cid, ok := viewable(w, r)
+
+	if !ok {
+		return
+	}
+
+	l, err := models.ListComments(cid)
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	api.Send(w, http.StatusOK, l)
+}
+
+// AddComment posts a comment at an offset of a content,
+// by its leader or a member having a share not expired yet
+func AddComment(w http.ResponseWriter, r *http.Request) {
+	cid, ok := viewable(w, r)
+
+	if !ok {
+		return
+	}
+
+	p := struct {
+		Offset int    `json:"offset"`
+		Body   string `json:"body"`
+	}{}
+
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
+		return
+	}
+
+	defer r.Body.Close()
+
+	c := models.Comment{
+		ContentID: cid,
+		UserID:    api.UserIdFromContext(r),
+		Offset:    p.Offset,
+		Body:      strings.TrimSpace(p.Body),
+	}
+
+	if c.Offset < 0 || c.Body == "" || utf8.RuneCountInString(c.Body) > maxCommentSize {
+		api.SendError(w, http.StatusBadRequest, messages.InvalidComment)
+		return
+	}
+
+	if err := c.New(); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	api.Send(w, http.StatusOK, c)
+}
+
+// DeleteComment removes a comment of a content.
+// Members can only remove their own comments,
+// the leader of the content can remove any of them.
+func DeleteComment(w http.ResponseWriter, r *http.Request) {
+	cid, ok := viewable(w, r)
+
+	if !ok {
+		return
+	}
+
+	// Ignore error because id is necessarily an int due to match param url
+	id, _ := strconv.ParseInt(chi.URLParam(r, "commentId"), 10, 64)
+	c := models.Comment{ID: id, ContentID: cid}
+
+	err := c.Find()
+
+	if err == sql.ErrNoRows {
+		api.SendError(w, http.StatusNotFound, messages.InvalidCommentId)
+		return
+	}
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	// The leader passed viewable so it owns the content
+	if c.UserID != api.UserIdFromContext(r) && api.RoleFromContext(r) != models.LeaderRole {
+		api.SendError(w, http.StatusForbidden, messages.ActionForbidden)
+		return
+	}
+
+	if err := c.Delete(); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	api.Send(w, http.StatusNoContent, nil)
+}
@@ -0,0 +1,140 @@
+package content
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/test/assert"
+	"gitlab.com/arnaud-web/neli-webservices/test/expect"
+	"gitlab.com/arnaud-web/neli-webservices/test/stub"
+)
+
+func TestComments_Leader(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	cid := expect.Content(lid, mock)
+	expect.Comments(cid, mock, models.Comment{ID: 1, UserID: 2, Author: "Jane Doe", Offset: 12, Body: "Nice pass"})
+
+	rr := httptest.NewRecorder()
+	Comments(rr, as(lid, models.LeaderRole, cid, nil))
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), `"author":"Jane Doe","offset":12,"body":"Nice pass"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestComments_Admin(t *testing.T) {
+	rr := httptest.NewRecorder()
+	Comments(rr, as(int64(rand.Uint64()), models.AdminRole, 42, nil))
+
+	assert.ResponseError(t, rr, http.StatusForbidden, messages.ActionForbidden)
+}
+
+func TestAddComment_Member(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	mid := int64(rand.Uint64())
+	expect.SharedContent(mid, 42, "abcdef", time.Now().Add(time.Hour), mock)
+	expect.CommentInsert(models.Comment{ContentID: 42, UserID: mid, Offset: 15, Body: "Great"}, mock)
+
+	rr := httptest.NewRecorder()
+	AddComment(rr, as(mid, models.MemberRole, 42, map[string]interface{}{"offset": 15, "body": "Great"}))
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), `"offset":15,"body":"Great"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddComment_Invalid(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	mid := int64(rand.Uint64())
+	expect.SharedContent(mid, 42, "abcdef", time.Now().Add(time.Hour), mock)
+
+	rr := httptest.NewRecorder()
+	AddComment(rr, as(mid, models.MemberRole, 42, map[string]interface{}{"offset": 15, "body": ""}))
+
+	assert.ResponseError(t, rr, http.StatusBadRequest, messages.InvalidComment)
+}
+
+func TestAddComment_ShareExpired(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	expect.SharedNotFound(mock)
+
+	rr := httptest.NewRecorder()
+	AddComment(rr, as(int64(rand.Uint64()), models.MemberRole, 42, map[string]interface{}{"offset": 15, "body": "Great"}))
+
+	assert.ResponseError(t, rr, http.StatusForbidden, messages.ActionForbidden)
+}
+
+func TestDeleteComment_Author(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	mid := int64(rand.Uint64())
+	expect.SharedContent(mid, 42, "abcdef", time.Now().Add(time.Hour), mock)
+	expect.Comment(models.Comment{ID: 3, ContentID: 42, UserID: mid}, mock)
+	expect.CommentDelete(3, mock)
+
+	rr := httptest.NewRecorder()
+	DeleteComment(rr, stub.AddUrlParam(as(mid, models.MemberRole, 42, nil), "commentId", "3"))
+
+	assert.Response(t, rr, http.StatusNoContent, "")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteComment_OtherMember(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	mid := int64(rand.Uint64())
+	expect.SharedContent(mid, 42, "abcdef", time.Now().Add(time.Hour), mock)
+	expect.Comment(models.Comment{ID: 3, ContentID: 42, UserID: mid + 1}, mock)
+
+	rr := httptest.NewRecorder()
+	DeleteComment(rr, stub.AddUrlParam(as(mid, models.MemberRole, 42, nil), "commentId", "3"))
+
+	assert.ResponseError(t, rr, http.StatusForbidden, messages.ActionForbidden)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteComment_Leader(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	cid := expect.Content(lid, mock)
+	expect.Comment(models.Comment{ID: 3, ContentID: cid, UserID: lid + 1}, mock)
+	expect.CommentDelete(3, mock)
+
+	rr := httptest.NewRecorder()
+	DeleteComment(rr, stub.AddUrlParam(as(lid, models.LeaderRole, cid, nil), "commentId", "3"))
+
+	assert.Response(t, rr, http.StatusNoContent, "")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteComment_NotFound(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	cid := expect.Content(lid, mock)
+	expect.Comment(models.Comment{ID: 3, ContentID: cid}, mock)
+
+	rr := httptest.NewRecorder()
+	DeleteComment(rr, stub.AddUrlParam(as(lid, models.LeaderRole, cid, nil), "commentId", "3"))
+
+	assert.ResponseError(t, rr, http.StatusNotFound, messages.InvalidCommentId)
+}
@@ -1,30 +1,47 @@
 package content
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
-	"log"
 	"net/http"
-	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi"
 	"gitlab.com/arnaud-web/neli-webservices/api"
+	"gitlab.com/arnaud-web/neli-webservices/api/audit"
+	"gitlab.com/arnaud-web/neli-webservices/api/capture"
 	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/background"
+	"gitlab.com/arnaud-web/neli-webservices/config"
 	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
 	"gitlab.com/arnaud-web/neli-webservices/mail"
+	"gitlab.com/arnaud-web/neli-webservices/metrics"
 )
 
-var logger = log.New(os.Stdout, "", log.LstdFlags|log.Lshortfile)
-
 type contentInfo struct {
 	MaxDuration int `json:"maxDuration"`
 }
 
 type contentReady struct {
-	RandomString string `json:"randomString"`
-	Duration     int    `json:"duration"`
+	RandomString string      `json:"randomString"`
+	Duration     int         `json:"duration"`
+	Renditions   []rendition `json:"renditions"`
+}
+
+// rendition is a rendition registered by the hub,
+// its file is relative to the randomString directory
+type rendition struct {
+	models.Rendition
+	File string `json:"file"`
+}
+
+type contentFailed struct {
+	Reason string `json:"reason"`
 }
 
 type videoContentSharingInfo struct {
@@ -50,14 +67,50 @@ type multipleShareError struct {
 	Ids     []int64 `json:"ids"`
 }
 
-// Get a content related to the leader identified
+// statuses returns the statuses of the status parameter, separated by commas.
+// False is returned when a status is unknown.
+func statuses(r *http.Request) ([]string, bool) {
+	p := r.URL.Query().Get("status")
+
+	if p == "" {
+		return nil, true
+	}
+
+	l := strings.Split(p, ",")
+
+	for _, s := range l {
+		if !models.IsStatus(s) {
+			return nil, false
+		}
+	}
+
+	return l, true
+}
+
+// Get a content related to the leader identified.
+// The status parameter filters contents by status, deleted ones are excluded by default.
+// The tag parameters keep the contents having all of them.
 func Get(w http.ResponseWriter, r *http.Request) {
 	lid := api.UserIdFromContext(r)
 	l := models.Leader{User: &models.User{ID: lid}}
-	c, err := l.Content()
+	st, ok := statuses(r)
+
+	if !ok {
+		api.SendError(w, http.StatusBadRequest, messages.InvalidContentStatus)
+		return
+	}
+
+	tags, ok := queryTags(r)
+
+	if !ok {
+		api.SendError(w, http.StatusBadRequest, messages.InvalidTags)
+		return
+	}
+
+	c, err := l.Content(st, tags)
 
 	if err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -74,7 +127,7 @@ func Shares(w http.ResponseWriter, r *http.Request) {
 	c, err := s.List(cid)
 
 	if err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -82,14 +135,29 @@ func Shares(w http.ResponseWriter, r *http.Request) {
 	api.Send(w, http.StatusOK, c)
 }
 
-// All returns all shares by all leader
+// All returns all shares by all leader.
+// The status and tag parameters filter contents as in Get.
 func All(w http.ResponseWriter, r *http.Request) {
 	aid := api.UserIdFromContext(r)
 	a := models.Admin{User: &models.User{ID: aid}}
-	c, err := a.Content()
+	st, ok := statuses(r)
+
+	if !ok {
+		api.SendError(w, http.StatusBadRequest, messages.InvalidContentStatus)
+		return
+	}
+
+	tags, ok := queryTags(r)
+
+	if !ok {
+		api.SendError(w, http.StatusBadRequest, messages.InvalidTags)
+		return
+	}
+
+	c, err := a.Content(st, tags)
 
 	if err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -97,14 +165,72 @@ func All(w http.ResponseWriter, r *http.Request) {
 	api.Send(w, http.StatusOK, c)
 }
 
+// Trash returns all content deleted by leaders and not cleaned yet
+func Trash(w http.ResponseWriter, r *http.Request) {
+	aid := api.UserIdFromContext(r)
+	a := models.Admin{User: &models.User{ID: aid}}
+	c, err := a.Trash()
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	api.Send(w, http.StatusOK, c)
+}
+
+// Restore attaches a deleted content to its original leader.
+// It has to be done before the cleaning removes the content.
+func Restore(w http.ResponseWriter, r *http.Request) {
+	aid := api.UserIdFromContext(r)
+	a := models.Admin{User: &models.User{ID: aid}}
+
+	// Ignore error because cid is necessarily an int due to match param url
+	cid, _ := strconv.ParseInt(chi.URLParam(r, "videoContentId"), 10, 64)
+
+	err := a.Restore(cid)
+
+	if err == sql.ErrNoRows {
+		api.SendError(w, http.StatusNotFound, messages.InvalidVideoContentId)
+		return
+	}
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	audit.Record(r, models.AuditContentRestore, models.ContentTarget, cid,
+		map[string]int64{"leaderId": *config.ZombieID}, nil)
+
+	api.Send(w, http.StatusNoContent, nil)
+}
+
 // New create a new content.
 // It loads the max durations from settings.
+// The leader can't create more videos than their quota allows.
 func New(w http.ResponseWriter, r *http.Request) {
 	uid := api.UserIdFromContext(r)
 	cnt := models.Content{LeaderID: uid}
+	l := models.Leader{User: &models.User{ID: uid}}
+
+	u, err := l.Usage()
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	if !u.CanCreate() {
+		api.SendError(w, http.StatusForbidden, messages.VideoQuotaExceeded)
+		return
+	}
 
 	if err := cnt.New(); err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -112,7 +238,7 @@ func New(w http.ResponseWriter, r *http.Request) {
 	s := models.Settings{}
 
 	if err := s.Load(); err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -134,21 +260,30 @@ func MaxDuration(w http.ResponseWriter, r *http.Request) {
 	c := contentInfo{}
 
 	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
 		return
 	}
 
 	defer r.Body.Close()
 
+	// Keep previous value for audit log
+	before := models.Settings{}
+
+	if err := before.Load(); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+	}
+
 	s := models.Settings{MaxDuration: c.MaxDuration}
 
 	if err := s.Update(); err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	audit.Record(r, models.AuditMaxDuration, models.SettingsTarget, 0, before, s)
+
 	api.Send(w, http.StatusNoContent, nil)
 }
 
@@ -157,7 +292,7 @@ func GetMaxDuration(w http.ResponseWriter, r *http.Request) {
 	s := models.Settings{}
 
 	if err := s.Load(); err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -178,7 +313,7 @@ func Edit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
 		return
 	}
@@ -191,7 +326,7 @@ func Edit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := c.Update(); err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -208,7 +343,7 @@ func Share(w http.ResponseWriter, r *http.Request) {
 	v := videoContentSharingInfo{}
 
 	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
 		return
 	}
@@ -231,7 +366,7 @@ func Share(w http.ResponseWriter, r *http.Request) {
 	c := models.Content{ID: vid, LeaderID: lid}
 
 	if err := c.Find(); err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusNotFound, messages.InvalidVideoContentIdOrUserId)
 		return
 	}
@@ -239,30 +374,42 @@ func Share(w http.ResponseWriter, r *http.Request) {
 	m := models.Member{User: &models.User{ID: uid}}
 
 	if b := m.InTribe(lid); !b {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusNotFound, messages.InvalidVideoContentIdOrUserId)
 		return
 	}
 
+	if err := share(r, uid, &c, v.Message, t); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	api.Send(w, http.StatusNoContent, nil)
+}
+
+// share records the share of the content with the member
+// and mails its url when the content is ready
+func share(r *http.Request, uid int64, c *models.Content, message string, t time.Time) error {
 	s := models.Share{
 		UserID:         uid,
-		ContentID:      vid,
-		Message:        v.Message,
+		ContentID:      c.ID,
+		Message:        message,
 		ExpirationDate: models.JSONTime(t),
 	}
 
-	err = s.New(&c)
-	if err != nil {
-		logger.Println(err)
-		api.SendError(w, http.StatusInternalServerError, err.Error())
-		return
+	if err := s.New(c); err != nil {
+		return err
 	}
 
+	audit.Record(r, models.AuditContentShare, models.ContentTarget, c.ID, nil, s)
+	metrics.Share.Inc()
+
 	if s.IsReady() {
-		go sendShareURL(uid, &s, &c)
+		background.Go(r.Context(), "mail.share", func() { sendShareURL(r.Context(), uid, &s, c) })
 	}
 
-	api.Send(w, http.StatusNoContent, nil)
+	return nil
 }
 
 func singleShare(w http.ResponseWriter, r *http.Request, ssi singleShareInfo, ch chan int64) {
@@ -273,7 +420,7 @@ func singleShare(w http.ResponseWriter, r *http.Request, ssi singleShareInfo, ch
 	c := models.Content{ID: vid, LeaderID: lid}
 
 	if err := c.Find(); err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		ch <- c.ID
 		return
 	}
@@ -285,24 +432,12 @@ func singleShare(w http.ResponseWriter, r *http.Request, ssi singleShareInfo, ch
 		return
 	}
 
-	s := models.Share{
-		UserID:         ssi.UserID,
-		ContentID:      vid,
-		Message:        ssi.Message,
-		ExpirationDate: models.JSONTime(ssi.ExpirationDate),
-	}
-
-	err := s.New(&c)
-	if err != nil {
-		logger.Println(err)
+	if err := share(r, ssi.UserID, &c, ssi.Message, ssi.ExpirationDate); err != nil {
+		logging.FromContext(r.Context()).Error(err)
 		ch <- ssi.UserID
 		return
 	}
 
-	if s.IsReady() {
-		go sendShareURL(ssi.UserID, &s, &c)
-	}
-
 	ch <- 0
 }
 
@@ -310,7 +445,7 @@ func MultipleShares(w http.ResponseWriter, r *http.Request) {
 	v := videoContentSharingInfoMultiple{}
 
 	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
 		return
 	}
@@ -355,22 +490,24 @@ func MultipleShares(w http.ResponseWriter, r *http.Request) {
 	api.Send(w, http.StatusNoContent, nil)
 }
 
-func sendShareURL(uid int64, share *models.Share, c *models.Content) {
+// sendShareURL mails the share url to the member.
+// It runs as background work, ctx only carries the request logger.
+func sendShareURL(ctx context.Context, uid int64, share *models.Share, c *models.Content) {
 	u := new(models.User)
 
 	if err := u.Find(uid); err != nil {
-		logger.Println(err)
+		logging.FromContext(ctx).Error(err)
 		return
 	}
 
 	l := new(models.User)
 
 	if err := l.Find(c.LeaderID); err != nil {
-		logger.Println(err)
+		logging.FromContext(ctx).Error(err)
 		return
 	}
 
-	mail.Share(u, share, c, l)
+	mail.Share(ctx, u, share, c, l)
 }
 
 // Delete a content.
@@ -388,19 +525,31 @@ func Delete(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := l.DeleteContent(cid); err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	audit.Record(r, models.AuditContentDelete, models.ContentTarget, cid,
+		map[string]int64{"leaderId": lid}, map[string]int64{"leaderId": *config.ZombieID})
+
 	api.Send(w, http.StatusNoContent, nil)
 }
 
+// Ready is called by the hub once the content is processed.
+// The renditions, like 360p and 720p mp4s or an HLS master playlist,
+// replace the single randomString.mp4 when they are sent.
+// The content must exist and not be deleted, its duration
+// is truncated to the max duration and to the minutes left to its leader.
+// Calling it again with the same randomString does nothing.
 func Ready(w http.ResponseWriter, r *http.Request) {
+	outcome := metrics.Failure
+	defer func() { metrics.Ready.WithLabelValues(outcome).Inc() }()
+
 	cr := contentReady{}
 
 	if err := json.NewDecoder(r.Body).Decode(&cr); err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
 		return
 	}
@@ -412,65 +561,253 @@ func Ready(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	l, ok := renditions(cr.Renditions)
+
+	if !ok {
+		api.SendError(w, http.StatusBadRequest, messages.InvalidRendition)
+		return
+	}
+
+	// Ignore error because cid is necessarily an int due to match param url
 	cid, _ := strconv.ParseInt(chi.URLParam(r, "videoContentId"), 10, 64)
+	c := models.Content{ID: cid}
+
+	// The content is looked up whatever its leader,
+	// the hub is told when it doesn't exist
+	err := c.Load()
 
-	// Hack for hub
-	// We return status no content if hub send video content id equals to 0
-	if cid == 0 {
-		api.Send(w, http.StatusNoContent, nil)
+	if err == sql.ErrNoRows {
+		api.SendError(w, http.StatusNotFound, messages.InvalidVideoContentId)
 		return
 	}
 
-	c := models.Content{
-		ID:       cid,
-		Path:     cr.RandomString,
-		Duration: cr.Duration,
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
 	}
 
+	c.Path = cr.RandomString
+	c.Duration = cr.Duration
+	c.Renditions = l
+
 	s := models.Settings{}
 
 	if err := s.Load(); err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	if c.Duration > s.MaxDuration {
-		logger.Printf("Duration %d truncated to %d", c.Duration, s.MaxDuration)
+		logging.FromContext(r.Context()).Warnf("duration %d truncated to %d", c.Duration, s.MaxDuration)
 		c.Duration = s.MaxDuration
 	}
 
-	shares, err := c.Shares()
+	// The capture was clamped to the minutes left when it started,
+	// they may have decreased since: the recorded video is kept but only
+	// the minutes left are counted. A ready content is already counted.
+	if c.Status != models.ContentReady {
+		left, ok := secondsLeft(w, r, c.LeaderID, cid)
+
+		if !ok {
+			return
+		}
+
+		if left >= 0 && int64(c.Duration) > left {
+			logging.FromContext(r.Context()).Warnf("duration %d truncated to quota %d", c.Duration, left)
+			c.Duration = int(left)
+		}
+	}
+
+	if !makeReady(w, r, &c) {
+		return
+	}
+
+	hubPoster(r, cid)
+
+	outcome = metrics.Success
+	api.Send(w, http.StatusNoContent, nil)
+}
+
+// renditions checks the renditions sent by the hub, names are unique.
+// No rendition means the single randomString.mp4.
+func renditions(l []rendition) (models.Renditions, bool) {
+	res := models.Renditions{}
+	names := map[string]bool{}
+
+	for _, r := range l {
+		r.Rendition.File = r.File
+
+		if !r.Valid() || names[r.Name] {
+			return nil, false
+		}
+
+		names[r.Name] = true
+		res = append(res, r.Rendition)
+	}
+
+	return res, true
+}
+
+// secondsLeft returns the duration the leader may still record, -1 when unlimited.
+// The content itself isn't counted, an error is sent when it fails.
+func secondsLeft(w http.ResponseWriter, r *http.Request, lid, cid int64) (int64, bool) {
+	l := models.Leader{User: &models.User{ID: lid}}
+	u, err := l.UsageExcept(cid)
 
 	if err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
-		return
+		return 0, false
 	}
 
+	return u.SecondsLeft(), true
+}
+
+// makeReady makes the content ready then mails its pending shares
+// and notifies the capture status streams, an error is sent when it fails
+func makeReady(w http.ResponseWriter, r *http.Request, c *models.Content) bool {
+	// The content and its shares are updated in one transaction,
+	// a repeated callback returns no share to mail
+	shares, err := c.MakeReady()
+
+	if err == sql.ErrNoRows {
+		api.SendError(w, http.StatusNotFound, messages.InvalidVideoContentId)
+		return false
+	}
+
+	if err == models.ErrTransition {
+		api.SendError(w, http.StatusConflict, messages.InvalidContentStatus)
+		return false
+	}
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return false
+	}
+
+	// Mails are only sent once the transaction is committed
 	for _, share := range shares {
+		// Copy the loop variable, the closure runs after next iteration
+		share := share
+		background.Go(r.Context(), "mail.share", func() { sendShareURL(r.Context(), share.UserID, &share, c) })
+	}
 
-		err := share.UpdateURL(&c)
+	capture.NotifyReady(c.ID)
 
-		if err != nil {
-			logger.Println(err)
-			api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
-			return
-		}
+	return true
+}
+
+// Failed is called by the hub when the content cannot be processed.
+// The content has to be recording or processing,
+// its leader is mailed with the reason.
+func Failed(w http.ResponseWriter, r *http.Request) {
+	cf := contentFailed{}
+
+	if err := json.NewDecoder(r.Body).Decode(&cf); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
+		return
+	}
+
+	defer r.Body.Close()
+
+	if strings.TrimSpace(cf.Reason) == "" {
+		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
+		return
+	}
+
+	// Ignore error because cid is necessarily an int due to match param url
+	cid, _ := strconv.ParseInt(chi.URLParam(r, "videoContentId"), 10, 64)
+	c := models.Content{ID: cid}
 
-		go sendShareURL(share.UserID, &share, &c)
+	err := c.Fail(cf.Reason)
 
+	if err == models.ErrTransition {
+		api.SendError(w, http.StatusConflict, messages.InvalidContentStatus)
+		return
 	}
 
-	if err := c.UpdatePath(); err != nil {
-		logger.Println(err)
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
 		return
 	}
 
+	background.Go(r.Context(), "mail.failed", func() { sendFailed(r.Context(), cid) })
+
 	api.Send(w, http.StatusNoContent, nil)
 }
 
+// sendFailed mails the processing failure to the leader of the content.
+// It runs as background work, ctx only carries the request logger.
+func sendFailed(ctx context.Context, cid int64) {
+	c := models.Content{ID: cid}
+
+	if err := c.Load(); err != nil {
+		logging.FromContext(ctx).Error(err)
+		return
+	}
+
+	l := new(models.User)
+
+	if err := l.Find(c.LeaderID); err != nil {
+		logging.FromContext(ctx).Error(err)
+		return
+	}
+
+	mail.Failed(ctx, &c, l.Email)
+}
+
+// Reprocess asks the hub to process again a failed content of the leader.
+// Failed contents are listed with the failed status filter.
+func Reprocess(w http.ResponseWriter, r *http.Request) {
+	lid := api.UserIdFromContext(r)
+	l := models.Leader{User: &models.User{ID: lid}}
+
+	// Ignore error because cid is necessarily an int due to match param url
+	cid, _ := strconv.ParseInt(chi.URLParam(r, "videoContentId"), 10, 64)
+
+	if b := l.IsOwner(cid); !b {
+		api.SendError(w, http.StatusForbidden, messages.ActionForbidden)
+		return
+	}
+
+	c := models.Content{ID: cid}
+	err := c.Reprocess()
+
+	if err == models.ErrTransition {
+		api.SendError(w, http.StatusConflict, messages.InvalidContentStatus)
+		return
+	}
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	audit.Record(r, models.AuditContentReprocess, models.ContentTarget, cid,
+		map[string]string{"status": models.ContentFailed}, map[string]string{"status": models.ContentProcessing})
+
+	api.Send(w, http.StatusNoContent, nil)
+}
+
+// Reprocessing returns to the hub the contents to process again, oldest request first
+func Reprocessing(w http.ResponseWriter, r *http.Request) {
+	l, err := models.ListReprocess()
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	api.Send(w, http.StatusOK, l)
+}
+
 func Cleaning(w http.ResponseWriter, r *http.Request) {
 	s := struct {
 		Date string `json: "date",omitempty`
@@ -492,11 +829,13 @@ func Cleaning(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := models.Clean(t); err != nil {
-		log.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
 		return
 	}
 
+	audit.Record(r, models.AuditCleaning, models.CleaningTarget, 0, nil, map[string]string{"date": t})
+
 	api.Send(w, http.StatusNoContent, nil)
 
 	return
@@ -1,6 +1,8 @@
 package content
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"net/http"
@@ -9,6 +11,7 @@ import (
 
 	"github.com/icrowley/fake"
 	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/background"
 	"gitlab.com/arnaud-web/neli-webservices/db/models"
 	"gitlab.com/arnaud-web/neli-webservices/test/assert"
 	"gitlab.com/arnaud-web/neli-webservices/test/expect"
@@ -21,6 +24,7 @@ func TestNew(t *testing.T) {
 
 	uid := int64(rand.Uint64())
 
+	expect.Usage(uid, models.Usage{}, mock)
 	cid := expect.ContentInsert(uid, mock)
 	expect.Settings(mock)
 
@@ -33,11 +37,28 @@ func TestNew(t *testing.T) {
 	assert.Contains(t, rr.Body.String(), fmt.Sprintf("%d", 3600))
 }
 
+func TestNew_VideoQuotaExceeded(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	uid := int64(rand.Uint64())
+	expect.Usage(uid, models.Usage{Videos: 10, MaxVideos: 10}, mock)
+
+	rr, r := stub.HttpWithContext(uid)
+
+	New(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusForbidden, messages.VideoQuotaExceeded)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestMaxDuration(t *testing.T) {
 	mock, mockDB := stub.DB()
 	defer mockDB.Close()
 
+	expect.Settings(mock)
 	m := expect.SettingsUpdate(mock)
+	expect.AuditInsert(models.AuditMaxDuration, mock)
 
 	p := map[string]interface{}{
 		"maxDuration": m,
@@ -158,6 +179,7 @@ func TestShare(t *testing.T) {
 
 	expect.IsInTribe(lid, uid, mock)
 	expect.ShareInsert(mock)
+	expect.AuditInsert(models.AuditContentShare, mock)
 	expect.FindByIdInParameter(uid, mock)
 
 	Share(rr, r)
@@ -202,6 +224,7 @@ func TestShare_MessageEmpty(t *testing.T) {
 
 	expect.IsInTribe(lid, uid, mock)
 	expect.ShareInsert(mock)
+	expect.AuditInsert(models.AuditContentShare, mock)
 	expect.FindByIdInParameter(uid, mock)
 
 	Share(rr, r)
@@ -294,6 +317,66 @@ func TestAll(t *testing.T) {
 	assert.Contains(t, b, fmt.Sprintf("%v", c.SharingStatus))
 }
 
+func TestTrash(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	aid := int64(rand.Uint64())
+
+	c := stub.Content()
+	c.PreviousID = int64(rand.Uint64())
+	c.PreviousName = fake.FullName()
+	expect.Trash(c, mock)
+
+	rr, r := stub.HttpWithContext(aid)
+
+	Trash(rr, r)
+
+	b := rr.Body.String()
+
+	assert.Response(t, rr, http.StatusOK, "")
+
+	assert.Contains(t, b, fmt.Sprintf("%d", c.ID))
+	assert.Contains(t, b, c.Name)
+	assert.Contains(t, b, fmt.Sprintf("%d", c.PreviousID))
+	assert.Contains(t, b, c.PreviousName)
+}
+
+func TestRestore(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	aid := int64(rand.Uint64())
+	cid := int64(rand.Uint64())
+
+	expect.ContentRestore(cid, 1, mock)
+	expect.AuditInsert(models.AuditContentRestore, mock)
+
+	rr, r := stub.HttpWithContext(aid)
+	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
+
+	Restore(rr, r)
+
+	assert.Response(t, rr, http.StatusNoContent, "")
+}
+
+func TestRestore_NotInTrash(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	aid := int64(rand.Uint64())
+	cid := int64(rand.Uint64())
+
+	expect.ContentRestore(cid, 0, mock)
+
+	rr, r := stub.HttpWithContext(aid)
+	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
+
+	Restore(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusNotFound, messages.InvalidVideoContentId)
+}
+
 func TestShareList(t *testing.T) {
 	mock, mockDB := stub.DB()
 	defer mockDB.Close()
@@ -323,7 +406,8 @@ func TestDelete(t *testing.T) {
 	lid := int64(rand.Uint64())
 	cid := expect.Content(lid, mock)
 
-	expect.ContentUpdate(mock)
+	expect.ContentTrash(cid, mock)
+	expect.AuditInsert(models.AuditContentDelete, mock)
 
 	rr, r := stub.HttpWithContext(lid)
 	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
@@ -331,6 +415,7 @@ func TestDelete(t *testing.T) {
 	Delete(rr, r)
 
 	assert.Response(t, rr, http.StatusNoContent, "")
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestReady(t *testing.T) {
@@ -349,16 +434,197 @@ func TestReady(t *testing.T) {
 
 	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
 
+	lid := int64(rand.Uint64())
+	expect.ContentLoad(cid, lid, models.ContentProcessing, mock)
 	expect.Settings(mock)
+	expect.UsageExcept(lid, cid, models.Usage{}, mock)
+	expect.ContentReadyLock(cid, "", models.ContentProcessing, mock)
+	expect.ContentUpdate(mock)
+	expect.RenditionsReplace(cid, mock)
 	expect.SelectShare(models.Share{
 		UserID: uid,
 	}, mock)
 	expect.UpdateShare(mock)
+	mock.ExpectCommit()
+
+	Ready(rr, r)
+	background.Wait(context.Background())
+
+	assert.Response(t, rr, http.StatusNoContent, "")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReady_Renditions(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	p := map[string]interface{}{
+		"randomString": "abcdef",
+		"duration":     10,
+		"renditions": []map[string]interface{}{
+			{"name": "720p", "type": "mp4", "file": "abcdef_720.mp4", "width": 1280, "height": 720, "bandwidth": 2500000},
+			{"name": "hls", "type": "hls", "file": "hls/master.m3u8"},
+		},
+	}
+
+	cid := int64(rand.Uint64())
+
+	rr, r := stub.PostHttpWithContext(int64(rand.Uint64()), p)
+	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
+
+	lid := int64(rand.Uint64())
+	expect.ContentLoad(cid, lid, models.ContentProcessing, mock)
+	expect.Settings(mock)
+	expect.UsageExcept(lid, cid, models.Usage{}, mock)
+	expect.ContentReadyLock(cid, "", models.ContentProcessing, mock)
 	expect.ContentUpdate(mock)
+	expect.RenditionsReplace(cid, mock,
+		models.Rendition{Name: "720p", Type: models.RenditionMP4, File: "abcdef_720.mp4", Width: 1280, Height: 720, Bandwidth: 2500000},
+		models.Rendition{Name: "hls", Type: models.RenditionHLS, File: "hls/master.m3u8"},
+	)
+	expect.SelectShare(models.Share{UserID: int64(rand.Uint32())}, mock)
+	expect.UpdateShare(mock)
+	mock.ExpectCommit()
+
+	Ready(rr, r)
+	background.Wait(context.Background())
+
+	assert.Response(t, rr, http.StatusNoContent, "")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReady_InvalidRendition(t *testing.T) {
+	for _, rd := range []map[string]interface{}{
+		{"name": "720p", "type": "webm", "file": "abcdef.webm"},
+		{"name": "720p", "type": "mp4", "file": "../other/other.mp4"},
+		{"name": "720p", "type": "mp4", "file": "/abcdef.mp4"},
+		{"name": "", "type": "mp4", "file": "abcdef.mp4"},
+	} {
+		p := map[string]interface{}{
+			"randomString": "abcdef",
+			"duration":     10,
+			"renditions":   []map[string]interface{}{rd},
+		}
+
+		rr, r := stub.PostHttpWithContext(int64(rand.Uint64()), p)
+		r = stub.AddUrlParam(r, "videoContentId", "42")
+
+		Ready(rr, r)
+
+		assert.ResponseError(t, rr, http.StatusBadRequest, messages.InvalidRendition)
+	}
+}
+
+func TestReady_Repeated(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	p := map[string]interface{}{
+		"randomString": fake.Sentence(),
+		"duration":     10,
+	}
+
+	cid := int64(rand.Uint64())
+
+	rr, r := stub.PostHttpWithContext(int64(rand.Uint64()), p)
+	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
+
+	expect.ContentLoad(cid, int64(rand.Uint64()), models.ContentReady, mock)
+	expect.Settings(mock)
+	expect.ContentReadyLock(cid, p["randomString"].(string), models.ContentReady, mock)
+	mock.ExpectRollback()
+
+	Ready(rr, r)
+
+	assert.Response(t, rr, http.StatusNoContent, "")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReady_ShareFailure(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	p := map[string]interface{}{
+		"randomString": fake.Sentence(),
+		"duration":     10,
+	}
+
+	uid := int64(rand.Uint64())
+	cid := int64(rand.Uint64())
+
+	rr, r := stub.PostHttpWithContext(uid, p)
+	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
+
+	lid := int64(rand.Uint64())
+	expect.ContentLoad(cid, lid, models.ContentProcessing, mock)
+	expect.Settings(mock)
+	expect.UsageExcept(lid, cid, models.Usage{}, mock)
+	expect.ContentReadyLock(cid, "", models.ContentProcessing, mock)
+	expect.ContentUpdate(mock)
+	expect.RenditionsReplace(cid, mock)
+	expect.SelectShare(models.Share{
+		UserID: uid,
+	}, mock)
+	expect.UpdateShare(mock).WillReturnError(errors.New("share locked"))
+	mock.ExpectRollback()
+
+	Ready(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusInternalServerError, messages.TechnicalError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReady_TruncatedToQuota(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	path := fake.Sentence()
+	p := map[string]interface{}{
+		"randomString": path,
+		"duration":     120,
+	}
+
+	cid := int64(rand.Uint64())
+	lid := int64(rand.Uint64())
+
+	rr, r := stub.PostHttpWithContext(int64(rand.Uint64()), p)
+	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
+
+	expect.ContentLoad(cid, lid, models.ContentProcessing, mock)
+	expect.Settings(mock)
+	expect.UsageExcept(lid, cid, models.Usage{Seconds: 3540, MaxMinutes: 60}, mock)
+	expect.ContentReadyLock(cid, "", models.ContentProcessing, mock)
+	expect.ContentReadyUpdate(cid, path, 60, mock)
+	expect.RenditionsReplace(cid, mock)
+	expect.SelectShare(models.Share{}, mock)
+	expect.UpdateShare(mock)
+	mock.ExpectCommit()
 
 	Ready(rr, r)
+	background.Wait(context.Background())
 
 	assert.Response(t, rr, http.StatusNoContent, "")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReady_NotFound(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	p := map[string]interface{}{
+		"randomString": fake.Sentence(),
+		"duration":     10,
+	}
+
+	rr, r := stub.PostHttpWithContext(int64(rand.Uint64()), p)
+	r = stub.AddUrlParam(r, "videoContentId", "0")
+
+	expect.ContentLoadNotFound(0, mock)
+
+	Ready(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusNotFound, messages.InvalidVideoContentId)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestReady_EmptyRandomString(t *testing.T) {
@@ -377,3 +643,153 @@ func TestReady_EmptyRandomString(t *testing.T) {
 
 	assert.Response(t, rr, http.StatusBadRequest, messages.InvalidParameters)
 }
+
+func TestList_Status(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+
+	c := stub.Content()
+	c.Status = models.ContentProcessing
+	expect.ContentLeaderStatus(lid, c, mock, models.ContentRecording, models.ContentProcessing)
+
+	rr, r := stub.HttpWithContext(lid)
+	r.URL.RawQuery = "status=recording,processing"
+
+	Get(rr, r)
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), `"status":"processing"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestList_InvalidStatus(t *testing.T) {
+	rr, r := stub.HttpWithContext(int64(rand.Uint64()))
+	r.URL.RawQuery = "status=ready,lost"
+
+	Get(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusBadRequest, messages.InvalidContentStatus)
+}
+
+func TestReady_InvalidStatus(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	p := map[string]interface{}{
+		"randomString": fake.Sentence(),
+		"duration":     10,
+	}
+
+	cid := int64(rand.Uint64())
+
+	rr, r := stub.PostHttpWithContext(int64(rand.Uint64()), p)
+	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
+
+	lid := int64(rand.Uint64())
+	expect.ContentLoad(cid, lid, models.ContentDeleted, mock)
+	expect.Settings(mock)
+	expect.UsageExcept(lid, cid, models.Usage{}, mock)
+	expect.ContentReadyLock(cid, "", models.ContentDeleted, mock)
+	mock.ExpectRollback()
+
+	Ready(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusConflict, messages.InvalidContentStatus)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFailed(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	reason := fake.Sentence()
+	cid := int64(rand.Uint64())
+
+	expect.ContentFail(cid, reason, mock)
+
+	rr, r := stub.PostHttpWithContext(1, map[string]interface{}{"reason": reason})
+	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
+
+	Failed(rr, r)
+	background.Wait(context.Background())
+
+	assert.Response(t, rr, http.StatusNoContent, "")
+}
+
+func TestFailed_EmptyReason(t *testing.T) {
+	rr, r := stub.PostHttpWithContext(1, map[string]interface{}{"reason": " "})
+	r = stub.AddUrlParam(r, "videoContentId", "1")
+
+	Failed(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusBadRequest, messages.InvalidParameters)
+}
+
+func TestFailed_InvalidStatus(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	cid := int64(rand.Uint64())
+	expect.ContentTransitionDenied(cid, models.ContentReady, mock)
+
+	rr, r := stub.PostHttpWithContext(1, map[string]interface{}{"reason": fake.Sentence()})
+	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
+
+	Failed(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusConflict, messages.InvalidContentStatus)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReprocess(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	cid := expect.Content(lid, mock)
+
+	expect.ContentReprocess(cid, mock)
+	expect.AuditInsert(models.AuditContentReprocess, mock)
+
+	rr, r := stub.HttpWithContext(lid)
+	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
+
+	Reprocess(rr, r)
+
+	assert.Response(t, rr, http.StatusNoContent, "")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReprocess_NotFailed(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	cid := expect.Content(lid, mock)
+
+	expect.ContentReprocessDenied(cid, mock)
+
+	rr, r := stub.HttpWithContext(lid)
+	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
+
+	Reprocess(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusConflict, messages.InvalidContentStatus)
+}
+
+func TestReprocessing(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	cid := int64(rand.Uint32())
+	expect.Reprocessing(mock, cid)
+
+	rr, r := stub.HttpWithContext(1)
+
+	Reprocessing(rr, r)
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), fmt.Sprintf(`"videoContentId":%d`, cid))
+}
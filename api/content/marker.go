@@ -0,0 +1,190 @@
+package content
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi"
+	"gitlab.com/arnaud-web/neli-webservices/api"
+	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
+)
+
+// maxTitleSize is the max number of characters of a marker title
+const maxTitleSize = 255
+
+// Markers returns the markers of a content ordered by offset.
+// They are returned to the leader of the content
+// and to the members having a share not expired yet.
+func Markers(w http.ResponseWriter, r *http.Request) {
+	cid, ok := viewable(w, r)
+
+	if !ok {
+		return
+	}
+
+	l, err := models.ListMarkers(cid)
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	api.Send(w, http.StatusOK, l)
+}
+
+// AddMarker adds a marker to a content of the leader
+func AddMarker(w http.ResponseWriter, r *http.Request) {
+	m, ok := decodeMarker(w, r)
+
+	if !ok {
+		return
+	}
+
+	if err := m.New(); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	api.Send(w, http.StatusOK, m)
+}
+
+// EditMarker changes the offset and title of a marker of a content of the leader
+func EditMarker(w http.ResponseWriter, r *http.Request) {
+	m, ok := decodeMarker(w, r)
+
+	if !ok {
+		return
+	}
+
+	// Ignore error because mid is necessarily an int due to match param url
+	m.ID, _ = strconv.ParseInt(chi.URLParam(r, "markerId"), 10, 64)
+
+	err := m.Update()
+
+	if err == sql.ErrNoRows {
+		api.SendError(w, http.StatusNotFound, messages.InvalidMarkerId)
+		return
+	}
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	api.Send(w, http.StatusOK, m)
+}
+
+// DeleteMarker removes a marker of a content of the leader
+func DeleteMarker(w http.ResponseWriter, r *http.Request) {
+	cid, ok := owned(w, r)
+
+	if !ok {
+		return
+	}
+
+	// Ignore error because mid is necessarily an int due to match param url
+	mid, _ := strconv.ParseInt(chi.URLParam(r, "markerId"), 10, 64)
+
+	err := models.Marker{ID: mid, ContentID: cid}.Delete()
+
+	if err == sql.ErrNoRows {
+		api.SendError(w, http.StatusNotFound, messages.InvalidMarkerId)
+		return
+	}
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	api.Send(w, http.StatusNoContent, nil)
+}
+
+// decodeMarker reads the marker of the body for a content of the leader,
+// an error is sent when it fails
+func decodeMarker(w http.ResponseWriter, r *http.Request) (models.Marker, bool) {
+	m := models.Marker{}
+	cid, ok := owned(w, r)
+
+	if !ok {
+		return m, false
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
+		return m, false
+	}
+
+	defer r.Body.Close()
+
+	m.ContentID = cid
+	m.Title = strings.TrimSpace(m.Title)
+
+	if m.Offset < 0 || m.Title == "" || utf8.RuneCountInString(m.Title) > maxTitleSize {
+		api.SendError(w, http.StatusBadRequest, messages.InvalidMarker)
+		return m, false
+	}
+
+	return m, true
+}
+
+// owned checks the leader owns the content of the url,
+// an error is sent otherwise
+func owned(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	l := models.Leader{User: &models.User{ID: api.UserIdFromContext(r)}}
+
+	// Ignore error because cid is necessarily an int due to match param url
+	cid, _ := strconv.ParseInt(chi.URLParam(r, "videoContentId"), 10, 64)
+
+	if b := l.IsOwner(cid); !b {
+		api.SendError(w, http.StatusForbidden, messages.ActionForbidden)
+		return cid, false
+	}
+
+	return cid, true
+}
+
+// viewable checks the user can watch the content of the url:
+// its leader or a member having a share not expired yet.
+// An error is sent otherwise.
+func viewable(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	uid := api.UserIdFromContext(r)
+
+	// Ignore error because cid is necessarily an int due to match param url
+	cid, _ := strconv.ParseInt(chi.URLParam(r, "videoContentId"), 10, 64)
+
+	switch api.RoleFromContext(r) {
+	case models.LeaderRole:
+		l := models.Leader{User: &models.User{ID: uid}}
+
+		if l.IsOwner(cid) {
+			return cid, true
+		}
+	case models.MemberRole:
+		s := models.Shared{}
+		err := s.FindByContent(uid, cid)
+
+		if err == nil {
+			return cid, true
+		}
+
+		if err != sql.ErrNoRows {
+			logging.FromContext(r.Context()).Error(err)
+			api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+			return cid, false
+		}
+	}
+
+	api.SendError(w, http.StatusForbidden, messages.ActionForbidden)
+	return cid, false
+}
@@ -0,0 +1,160 @@
+package content
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/test/assert"
+	"gitlab.com/arnaud-web/neli-webservices/test/expect"
+	"gitlab.com/arnaud-web/neli-webservices/test/stub"
+)
+
+// as returns a request of the user with the role on the content, sending the body
+func as(uid int64, role string, cid int64, body interface{}) *http.Request {
+	_, r := stub.HttpWithRole(uid, role)
+	b, _ := json.Marshal(body)
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	return stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
+}
+
+func TestMarkers_Member(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	mid := int64(rand.Uint64())
+	expect.SharedContent(mid, 42, "abcdef", time.Now().Add(time.Hour), mock)
+	expect.Markers(42, mock, models.Marker{ID: 1, Offset: 30, Title: "Warm up"})
+
+	rr := httptest.NewRecorder()
+	Markers(rr, as(mid, models.MemberRole, 42, nil))
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), `"offset":30,"title":"Warm up"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMarkers_NotShared(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	expect.SharedNotFound(mock)
+
+	rr := httptest.NewRecorder()
+	Markers(rr, as(int64(rand.Uint64()), models.MemberRole, 42, nil))
+
+	assert.ResponseError(t, rr, http.StatusForbidden, messages.ActionForbidden)
+}
+
+func TestAddMarker(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	cid := expect.Content(lid, mock)
+	expect.MarkerInsert(models.Marker{ContentID: cid, Offset: 90, Title: "Drill"}, mock)
+
+	rr := httptest.NewRecorder()
+	AddMarker(rr, as(lid, models.LeaderRole, cid, map[string]interface{}{"offset": 90, "title": " Drill "}))
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), `"offset":90,"title":"Drill"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddMarker_Invalid(t *testing.T) {
+	for _, p := range []map[string]interface{}{
+		{"offset": -1, "title": "Drill"},
+		{"offset": 10, "title": " "},
+		{"offset": 10, "title": string(make([]byte, maxTitleSize+1))},
+	} {
+		mock, mockDB := stub.DB()
+		lid := int64(rand.Uint64())
+		cid := expect.Content(lid, mock)
+
+		rr := httptest.NewRecorder()
+		AddMarker(rr, as(lid, models.LeaderRole, cid, p))
+
+		assert.ResponseError(t, rr, http.StatusBadRequest, messages.InvalidMarker)
+		mockDB.Close()
+	}
+}
+
+func TestAddMarker_NotOwner(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	expect.ContentNotFound(lid, mock)
+
+	rr := httptest.NewRecorder()
+	AddMarker(rr, as(lid, models.LeaderRole, 42, map[string]interface{}{"offset": 90, "title": "Drill"}))
+
+	assert.ResponseError(t, rr, http.StatusForbidden, messages.ActionForbidden)
+}
+
+func TestEditMarker(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	cid := expect.Content(lid, mock)
+	expect.MarkerUpdate(models.Marker{ID: 7, ContentID: cid, Offset: 120, Title: "Cool down"}, mock)
+
+	rr := httptest.NewRecorder()
+	EditMarker(rr, stub.AddUrlParam(as(lid, models.LeaderRole, cid, map[string]interface{}{"offset": 120, "title": "Cool down"}), "markerId", "7"))
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEditMarker_NotFound(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	cid := expect.Content(lid, mock)
+	expect.MarkerNotFound(mock)
+
+	rr := httptest.NewRecorder()
+	EditMarker(rr, stub.AddUrlParam(as(lid, models.LeaderRole, cid, map[string]interface{}{"offset": 120, "title": "Cool down"}), "markerId", "7"))
+
+	assert.ResponseError(t, rr, http.StatusNotFound, messages.InvalidMarkerId)
+}
+
+func TestDeleteMarker(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	cid := expect.Content(lid, mock)
+	expect.MarkerDelete(7, cid, 1, mock)
+
+	rr := httptest.NewRecorder()
+	DeleteMarker(rr, stub.AddUrlParam(as(lid, models.LeaderRole, cid, nil), "markerId", "7"))
+
+	assert.Response(t, rr, http.StatusNoContent, "")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteMarker_NotFound(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	cid := expect.Content(lid, mock)
+	expect.MarkerDelete(7, cid, 0, mock)
+
+	rr := httptest.NewRecorder()
+	DeleteMarker(rr, stub.AddUrlParam(as(lid, models.LeaderRole, cid, nil), "markerId", "7"))
+
+	assert.ResponseError(t, rr, http.StatusNotFound, messages.InvalidMarkerId)
+}
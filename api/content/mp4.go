@@ -0,0 +1,116 @@
+package content
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// errNoDuration is returned when a file has no mp4 movie header
+var errNoDuration = errors.New("no mp4 movie header")
+
+// mp4Duration returns the duration in seconds, rounded up,
+// read from the movie header box (moov/mvhd) of a mp4 file
+func mp4Duration(r io.ReadSeeker) (int, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	size, err := findBox(r, "moov", end)
+
+	if err != nil {
+		return 0, err
+	}
+
+	start, _ := r.Seek(0, io.SeekCurrent)
+
+	size, err = findBox(r, "mvhd", start+size)
+
+	if err != nil {
+		return 0, err
+	}
+
+	b := make([]byte, size)
+
+	if _, err := io.ReadFull(r, b); err != nil {
+		return 0, err
+	}
+
+	var scale, d uint64
+
+	// Version 1 has 64 bits dates and duration
+	switch {
+	case len(b) >= 32 && b[0] == 1:
+		scale = uint64(binary.BigEndian.Uint32(b[20:24]))
+		d = binary.BigEndian.Uint64(b[24:32])
+	case len(b) >= 20 && b[0] == 0:
+		scale = uint64(binary.BigEndian.Uint32(b[12:16]))
+		d = uint64(binary.BigEndian.Uint32(b[16:20]))
+	default:
+		return 0, errNoDuration
+	}
+
+	if scale == 0 {
+		return 0, errNoDuration
+	}
+
+	return int((d + scale - 1) / scale), nil
+}
+
+// findBox moves r to the content of the first box of type t before end
+// and returns its content size
+func findBox(r io.ReadSeeker, t string, end int64) (int64, error) {
+	h := make([]byte, 8)
+
+	for {
+		pos, err := r.Seek(0, io.SeekCurrent)
+
+		if err != nil {
+			return 0, err
+		}
+
+		if pos+8 > end {
+			return 0, errNoDuration
+		}
+
+		if _, err := io.ReadFull(r, h); err != nil {
+			return 0, err
+		}
+
+		size := int64(binary.BigEndian.Uint32(h[:4]))
+		typ := string(h[4:8])
+		header := int64(8)
+
+		switch size {
+		case 0:
+			// The box extends to the end
+			size = end - pos
+		case 1:
+			// The size is a 64 bits integer following the type
+			if _, err := io.ReadFull(r, h); err != nil {
+				return 0, err
+			}
+
+			size = int64(binary.BigEndian.Uint64(h))
+			header = 16
+		}
+
+		if size < header || pos+size > end {
+			return 0, errNoDuration
+		}
+
+		if typ == t {
+			return size - header, nil
+		}
+
+		if _, err := r.Seek(pos+size, io.SeekStart); err != nil {
+			return 0, err
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package content
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// box returns a mp4 box of type t with the content
+func box(t string, content ...[]byte) []byte {
+	c := bytes.Join(content, nil)
+	b := make([]byte, 8, 8+len(c))
+	binary.BigEndian.PutUint32(b, uint32(8+len(c)))
+	copy(b[4:], t)
+	return append(b, c...)
+}
+
+// mvhd returns a version 0 movie header box
+func mvhd(scale, duration uint32) []byte {
+	b := make([]byte, 100)
+	binary.BigEndian.PutUint32(b[12:], scale)
+	binary.BigEndian.PutUint32(b[16:], duration)
+	return box("mvhd", b)
+}
+
+// mp4 returns a mp4 file lasting seconds
+func mp4(seconds uint32) []byte {
+	return bytes.Join([][]byte{
+		box("ftyp", []byte("isom")),
+		box("mdat", make([]byte, 64)),
+		box("moov", box("trak"), mvhd(1000, seconds*1000)),
+	}, nil)
+}
+
+func TestMp4Duration(t *testing.T) {
+	d, err := mp4Duration(bytes.NewReader(mp4(42)))
+
+	if err != nil || d != 42 {
+		t.Fatalf("Expected 42 seconds, got %d: %v", d, err)
+	}
+}
+
+func TestMp4Duration_RoundedUp(t *testing.T) {
+	f := box("moov", mvhd(600, 601))
+	d, err := mp4Duration(bytes.NewReader(f))
+
+	if err != nil || d != 2 {
+		t.Fatalf("Expected 2 seconds, got %d: %v", d, err)
+	}
+}
+
+func TestMp4Duration_Version1(t *testing.T) {
+	b := make([]byte, 112)
+	b[0] = 1
+	binary.BigEndian.PutUint32(b[20:], 10)
+	binary.BigEndian.PutUint64(b[24:], 300)
+
+	d, err := mp4Duration(bytes.NewReader(box("moov", box("mvhd", b))))
+
+	if err != nil || d != 30 {
+		t.Fatalf("Expected 30 seconds, got %d: %v", d, err)
+	}
+}
+
+func TestMp4Duration_NotMp4(t *testing.T) {
+	if _, err := mp4Duration(bytes.NewReader([]byte("not a video at all"))); err == nil {
+		t.Fatal("Expected an error")
+	}
+}
+
+func TestMp4Duration_Truncated(t *testing.T) {
+	f := mp4(42)
+
+	if _, err := mp4Duration(bytes.NewReader(f[:len(f)-20])); err == nil {
+		t.Fatal("Expected an error")
+	}
+}
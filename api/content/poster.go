@@ -0,0 +1,159 @@
+package content
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	// Register png so posters can be uploaded as png
+	_ "image/png"
+
+	"github.com/go-chi/chi"
+	"gitlab.com/arnaud-web/neli-webservices/api"
+	"gitlab.com/arnaud-web/neli-webservices/api/asset"
+	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/config"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
+	"gitlab.com/arnaud-web/neli-webservices/storage"
+)
+
+// posterQuality is the jpeg quality of the stored posters
+const posterQuality = 85
+
+// Max dimensions of an uploaded poster, a small file can declare
+// dimensions which would take gigabytes once decoded
+const (
+	posterMaxWidth  = 4096
+	posterMaxHeight = 4096
+)
+
+// Poster replaces the poster of a content of the leader by the image of the body.
+// The image is a jpeg or a png, it is stored as a jpeg and recorded
+// in place of the hub one, its signed url is returned.
+// Its dimensions are checked before it is decoded.
+func Poster(w http.ResponseWriter, r *http.Request) {
+	lid := api.UserIdFromContext(r)
+	l := models.Leader{User: &models.User{ID: lid}}
+
+	// Ignore error because cid is necessarily an int due to match param url
+	cid, _ := strconv.ParseInt(chi.URLParam(r, "videoContentId"), 10, 64)
+
+	if b := l.IsOwner(cid); !b {
+		api.SendError(w, http.StatusForbidden, messages.ActionForbidden)
+		return
+	}
+
+	switch r.Header.Get("Content-Type") {
+	case "image/jpeg", "image/png":
+	default:
+		api.SendError(w, http.StatusUnsupportedMediaType, messages.InvalidPoster)
+		return
+	}
+
+	defer r.Body.Close()
+
+	b, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, *config.PosterMaxSize<<20))
+
+	if err != nil {
+		logging.FromContext(r.Context()).Warnf("poster of content %d rejected: %v", cid, err)
+		api.SendError(w, http.StatusBadRequest, messages.InvalidPoster)
+		return
+	}
+
+	ic, _, err := image.DecodeConfig(bytes.NewReader(b))
+
+	if err == nil && (ic.Width > posterMaxWidth || ic.Height > posterMaxHeight) {
+		err = fmt.Errorf("dimensions %dx%d above %dx%d", ic.Width, ic.Height, posterMaxWidth, posterMaxHeight)
+	}
+
+	var img image.Image
+
+	if err == nil {
+		img, _, err = image.Decode(bytes.NewReader(b))
+	}
+
+	if err != nil {
+		logging.FromContext(r.Context()).Warnf("poster of content %d rejected: %v", cid, err)
+		api.SendError(w, http.StatusBadRequest, messages.InvalidPoster)
+		return
+	}
+
+	if err := savePoster(r.Context(), models.PosterKey(cid), img); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	if err := (models.Content{ID: cid}).SetPoster(models.PosterKey(cid)); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	u, err := asset.Sign(models.PosterKey(cid), time.Time{})
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	api.Send(w, http.StatusOK, struct {
+		ThumbnailURL string `json:"thumbnailUrl"`
+	}{
+		u.URL,
+	})
+}
+
+// hubPoster records the poster produced by the hub next to the video of the content,
+// the storage is checked once when the content is ready
+func hubPoster(r *http.Request, cid int64) {
+	_, err := storage.Default.Stat(r.Context(), models.HubPosterKey(cid))
+
+	if err == storage.ErrNotExist {
+		return
+	}
+
+	if err == nil {
+		err = models.Content{ID: cid}.SetHubPoster()
+	}
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+	}
+}
+
+// Placeholder creates the poster of the contents without one,
+// a plain grey image, unless it already exists
+func Placeholder(ctx context.Context) error {
+	_, err := storage.Default.Stat(ctx, models.PlaceholderKey)
+
+	if err != storage.ErrNotExist {
+		return err
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 640, 360))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.RGBA{0x81, 0x7b, 0x7c, 0xff}}, image.Point{}, draw.Src)
+
+	return savePoster(ctx, models.PlaceholderKey, img)
+}
+
+// savePoster puts the image as a jpeg in the storage
+func savePoster(ctx context.Context, key string, img image.Image) error {
+	var b bytes.Buffer
+
+	if err := jpeg.Encode(&b, img, &jpeg.Options{Quality: posterQuality}); err != nil {
+		return err
+	}
+
+	return storage.Default.Put(ctx, key, bytes.NewReader(b.Bytes()))
+}
@@ -0,0 +1,141 @@
+package content
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/storage"
+	"gitlab.com/arnaud-web/neli-webservices/test/assert"
+	"gitlab.com/arnaud-web/neli-webservices/test/expect"
+	"gitlab.com/arnaud-web/neli-webservices/test/stub"
+)
+
+// poster returns a request sending the body as the poster of the content
+func poster(lid, cid int64, contentType string, body []byte) *http.Request {
+	_, r := stub.HttpWithContext(lid)
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	return stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
+}
+
+func TestPoster(t *testing.T) {
+	defer assets(t)()
+
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	cid := expect.Content(lid, mock)
+	expect.ContentPoster(cid, models.PosterKey(cid), mock)
+
+	b := bytes.Buffer{}
+	assert.NoError(t, png.Encode(&b, image.NewGray(image.Rect(0, 0, 16, 9))))
+
+	rr := httptest.NewRecorder()
+	Poster(rr, poster(lid, cid, "image/png", b.Bytes()))
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), fmt.Sprintf(`/assets/public/%d.jpg?expires=`, cid))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	f, _, err := storage.Default.Get(context.Background(), models.PosterKey(cid))
+	assert.NoError(t, err)
+	defer f.Close()
+
+	if _, format, err := image.Decode(f); err != nil || format != "jpeg" {
+		t.Fatalf("Expected a jpeg poster, got %s: %v", format, err)
+	}
+}
+
+func TestPoster_NotImage(t *testing.T) {
+	defer assets(t)()
+
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	cid := expect.Content(lid, mock)
+
+	rr := httptest.NewRecorder()
+	Poster(rr, poster(lid, cid, "image/jpeg", []byte("not an image")))
+
+	assert.ResponseError(t, rr, http.StatusBadRequest, messages.InvalidPoster)
+
+	if _, err := storage.Default.Stat(context.Background(), models.PosterKey(cid)); err != storage.ErrNotExist {
+		t.Fatalf("Expected no poster, got %v", err)
+	}
+}
+
+func TestPoster_TooLarge(t *testing.T) {
+	defer assets(t)()
+
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	cid := expect.Content(lid, mock)
+
+	b := bytes.Buffer{}
+	assert.NoError(t, png.Encode(&b, image.NewGray(image.Rect(0, 0, posterMaxWidth+1, 1))))
+
+	rr := httptest.NewRecorder()
+	Poster(rr, poster(lid, cid, "image/png", b.Bytes()))
+
+	assert.ResponseError(t, rr, http.StatusBadRequest, messages.InvalidPoster)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPoster_ContentType(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	cid := expect.Content(lid, mock)
+
+	rr := httptest.NewRecorder()
+	Poster(rr, poster(lid, cid, "image/gif", []byte("GIF89a")))
+
+	assert.ResponseError(t, rr, http.StatusUnsupportedMediaType, messages.InvalidPoster)
+}
+
+func TestPlaceholder(t *testing.T) {
+	defer assets(t)()
+
+	assert.NoError(t, Placeholder(context.Background()))
+
+	if _, err := storage.Default.Stat(context.Background(), models.PlaceholderKey); err != nil {
+		t.Fatalf("Expected the placeholder, got %v", err)
+	}
+
+	assert.Contains(t, models.ThumbnailURL("", time.Now().Add(time.Minute)), "/assets/public/placeholder.jpg?expires=")
+}
+
+func TestHubPoster(t *testing.T) {
+	defer assets(t)()
+
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+
+	// Nothing is recorded without poster from the hub
+	hubPoster(r, 42)
+
+	assert.NoError(t, storage.Default.Put(context.Background(), models.HubPosterKey(42), bytes.NewReader([]byte("jpeg"))))
+	expect.ContentHubPoster(42, mock)
+
+	hubPoster(r, 42)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,203 @@
+package content
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gitlab.com/arnaud-web/neli-webservices/api"
+	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
+)
+
+const (
+	// maxTagSize is the max number of characters of a tag
+	maxTagSize = 64
+	// maxTags is the max number of tags of a content
+	maxTags = 20
+	// maxSuggestions is the max number of tags returned to autocomplete
+	maxSuggestions = 20
+)
+
+type videoContentTags struct {
+	Tags []string `json:"tags"`
+}
+
+type videoContentSharingInfoTag struct {
+	videoContentSharingInfoMultiple
+	Tag string `json:"tag"`
+}
+
+// SetTags replaces the tags of a content of the leader.
+// Tags are trimmed and lowercased, duplicates are removed.
+// The tags kept are sent back.
+func SetTags(w http.ResponseWriter, r *http.Request) {
+	cid, ok := owned(w, r)
+
+	if !ok {
+		return
+	}
+
+	v := videoContentTags{}
+
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
+		return
+	}
+
+	defer r.Body.Close()
+
+	v.Tags, ok = tagNames(v.Tags)
+
+	if !ok || len(v.Tags) > maxTags {
+		api.SendError(w, http.StatusBadRequest, messages.InvalidTags)
+		return
+	}
+
+	l := models.Leader{User: &models.User{ID: api.UserIdFromContext(r)}}
+
+	if err := l.SetTags(cid, v.Tags); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	api.Send(w, http.StatusOK, v)
+}
+
+// Tags returns the tags of the leader starting with the prefix parameter,
+// the most used first, to autocomplete them
+func Tags(w http.ResponseWriter, r *http.Request) {
+	l := models.Leader{User: &models.User{ID: api.UserIdFromContext(r)}}
+	p := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("prefix")))
+
+	t, err := l.Tags(p, maxSuggestions)
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	api.Send(w, http.StatusOK, t)
+}
+
+// TagStats returns the tags of all the leaders merged by name,
+// with their number of contents and of leaders using them
+func TagStats(w http.ResponseWriter, r *http.Request) {
+	a := models.Admin{User: &models.User{ID: api.UserIdFromContext(r)}}
+	t, err := a.TagStats()
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	api.Send(w, http.StatusOK, t)
+}
+
+// ShareTag shares all the contents of the leader having the tag
+// with each member of the ids, as MultipleShares does for one content.
+// The members out of the tribe or whose share failed are sent back.
+func ShareTag(w http.ResponseWriter, r *http.Request) {
+	v := videoContentSharingInfoTag{}
+
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
+		return
+	}
+
+	defer r.Body.Close()
+
+	t, err := time.Parse(time.RFC3339, v.ExpirationDate)
+	if err != nil {
+		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
+		return
+	}
+
+	tags, ok := tagNames([]string{v.Tag})
+
+	if !ok {
+		api.SendError(w, http.StatusBadRequest, messages.InvalidTags)
+		return
+	}
+
+	lid := api.UserIdFromContext(r)
+	l := models.Leader{User: &models.User{ID: lid}}
+	c, err := l.Tagged(tags[0])
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	if len(c) == 0 {
+		api.SendError(w, http.StatusNotFound, messages.InvalidTag)
+		return
+	}
+
+	var ids []int64
+
+	for _, uid := range v.Ids {
+		m := models.Member{User: &models.User{ID: uid}}
+
+		if b := m.InTribe(lid); !b {
+			ids = append(ids, uid)
+			continue
+		}
+
+		for i := range c {
+			if err := share(r, uid, &c[i], v.Message, t); err != nil {
+				logging.FromContext(r.Context()).Error(err)
+				ids = append(ids, uid)
+				break
+			}
+		}
+	}
+
+	if len(ids) > 0 {
+		mse := multipleShareError{
+			Code:    404,
+			Message: messages.InvalidUserIdOrContentId,
+			Ids:     ids,
+		}
+		api.Send(w, http.StatusNotFound, mse)
+		return
+	}
+
+	api.Send(w, http.StatusNoContent, nil)
+}
+
+// queryTags returns the tags of the tag parameters.
+// False is returned when a tag is invalid.
+func queryTags(r *http.Request) ([]string, bool) {
+	return tagNames(r.URL.Query()["tag"])
+}
+
+// tagNames trims and lowercases the tags and removes the duplicates.
+// False is returned when a tag is empty or too long.
+func tagNames(l []string) ([]string, bool) {
+	t := []string{}
+	seen := map[string]bool{}
+
+	for _, s := range l {
+		s = strings.ToLower(strings.TrimSpace(s))
+
+		if s == "" || utf8.RuneCountInString(s) > maxTagSize {
+			return nil, false
+		}
+
+		if !seen[s] {
+			seen[s] = true
+			t = append(t, s)
+		}
+	}
+
+	return t, true
+}
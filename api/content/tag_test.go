@@ -0,0 +1,196 @@
+package content
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/icrowley/fake"
+	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/test/assert"
+	"gitlab.com/arnaud-web/neli-webservices/test/expect"
+	"gitlab.com/arnaud-web/neli-webservices/test/stub"
+)
+
+func TestSetTags(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	cid := expect.Content(lid, mock)
+	expect.TagsReplace(cid, lid, mock, "match-2026", "final")
+
+	rr := httptest.NewRecorder()
+	SetTags(rr, as(lid, models.LeaderRole, cid, map[string]interface{}{
+		"tags": []string{" Match-2026 ", "final", "match-2026"},
+	}))
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), `{"tags":["match-2026","final"]}`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetTags_Invalid(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+
+	for _, tags := range [][]string{{" "}, {strings.Repeat("a", maxTagSize+1)}} {
+		cid := expect.Content(lid, mock)
+
+		rr := httptest.NewRecorder()
+		SetTags(rr, as(lid, models.LeaderRole, cid, map[string]interface{}{"tags": tags}))
+
+		assert.ResponseError(t, rr, http.StatusBadRequest, messages.InvalidTags)
+	}
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetTags_NotOwner(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	expect.ContentNotFound(lid, mock)
+
+	rr := httptest.NewRecorder()
+	SetTags(rr, as(lid, models.LeaderRole, int64(rand.Uint32()), map[string]interface{}{"tags": []string{"final"}}))
+
+	assert.ResponseError(t, rr, http.StatusForbidden, messages.ActionForbidden)
+}
+
+func TestTags(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	expect.Tags(lid, `ma\_%`, mock, models.Tag{Name: "ma_tch", Contents: 3})
+
+	rr, r := stub.HttpWithContext(lid)
+	r.URL.RawQuery = "prefix=Ma_"
+
+	Tags(rr, r)
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), `[{"name":"ma_tch","contents":3}]`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTagStats(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	expect.TagStats(mock, models.Tag{Name: "match-2026", Contents: 12, Leaders: 2})
+
+	rr, r := stub.HttpWithContext(int64(rand.Uint64()))
+
+	TagStats(rr, r)
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), `[{"name":"match-2026","contents":12,"leaders":2}]`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestList_Tags(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+
+	c := stub.Content()
+	c.Status = models.ContentReady
+	c.Tags = models.Tags{"match-2026", "final"}
+	expect.ContentLeaderTags(lid, c, mock, "match-2026", "final")
+
+	rr, r := stub.HttpWithContext(lid)
+	r.URL.RawQuery = "tag=Match-2026&tag=final"
+
+	Get(rr, r)
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), `"tags":["match-2026","final"]`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestList_InvalidTag(t *testing.T) {
+	rr, r := stub.HttpWithContext(int64(rand.Uint64()))
+	r.URL.RawQuery = "tag="
+
+	Get(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusBadRequest, messages.InvalidTags)
+}
+
+func TestShareTag(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	uid := int64(rand.Uint64())
+
+	expect.Tagged(lid, "match-2026", mock, models.Content{ID: 1}, models.Content{ID: 2})
+	expect.IsInTribe(lid, uid, mock)
+	expect.ShareInsert(mock)
+	expect.AuditInsert(models.AuditContentShare, mock)
+	expect.ShareInsert(mock)
+	expect.AuditInsert(models.AuditContentShare, mock)
+
+	p := stub.ShareParam(fake.Sentence(), time.Now().Format(time.RFC3339))
+	p["tag"] = "Match-2026"
+	p["ids"] = []int64{uid}
+
+	rr, r := stub.PostHttpWithContext(lid, p)
+
+	ShareTag(rr, r)
+
+	assert.Response(t, rr, http.StatusNoContent, "")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShareTag_NotInTribe(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	uid := int64(rand.Uint64())
+
+	expect.Tagged(lid, "match-2026", mock, models.Content{ID: 1})
+	expect.IsInTribe(uid, uid, mock)
+
+	p := stub.ShareParam(fake.Sentence(), time.Now().Format(time.RFC3339))
+	p["tag"] = "match-2026"
+	p["ids"] = []int64{uid}
+
+	rr, r := stub.PostHttpWithContext(lid, p)
+
+	ShareTag(rr, r)
+
+	assert.Response(t, rr, http.StatusNotFound, "")
+	assert.Contains(t, rr.Body.String(), fmt.Sprintf("%d", uid))
+}
+
+func TestShareTag_UnknownTag(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	expect.Tagged(lid, "match-2026", mock)
+
+	p := stub.ShareParam(fake.Sentence(), time.Now().Format(time.RFC3339))
+	p["tag"] = "match-2026"
+	p["ids"] = []int64{int64(rand.Uint64())}
+
+	rr, r := stub.PostHttpWithContext(lid, p)
+
+	ShareTag(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusNotFound, messages.InvalidTag)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,403 @@
+package content
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/go-chi/chi"
+	"gitlab.com/arnaud-web/neli-webservices/api"
+	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/config"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
+	"gitlab.com/arnaud-web/neli-webservices/random"
+	"gitlab.com/arnaud-web/neli-webservices/storage"
+)
+
+// tusVersion is the version of the tus resumable upload protocol followed by uploads
+const tusVersion = "1.0.0"
+
+// uploadPathSize is the size of the random path of an uploaded video
+const uploadPathSize = 32
+
+// uploading holds the contents whose upload is receiving a chunk,
+// so that concurrent chunks don't append at the same offset
+var uploading = struct {
+	sync.Mutex
+	cids map[int64]bool
+}{cids: map[int64]bool{}}
+
+// lockUpload marks the upload of the content as receiving a chunk,
+// false is returned when another chunk is being received
+func lockUpload(cid int64) bool {
+	uploading.Lock()
+	defer uploading.Unlock()
+
+	if uploading.cids[cid] {
+		return false
+	}
+
+	uploading.cids[cid] = true
+	return true
+}
+
+// unlockUpload lets the upload of the content receive the next chunk
+func unlockUpload(cid int64) {
+	uploading.Lock()
+	delete(uploading.cids, cid)
+	uploading.Unlock()
+}
+
+// partFile returns the file receiving the chunks of the upload.
+// The video is put in the storage once complete.
+func partFile(u models.Upload) string {
+	return filepath.Join(*config.UploadDir, u.Path+".part")
+}
+
+// CreateUpload starts the upload of the video of a content of the leader.
+// The Upload-Length header is the size of the video in bytes.
+// Only a content never recorded can be uploaded,
+// its chunks are then sent to the Location url.
+// A content has one open upload: an upload of the same length is resumed
+// from its Upload-Offset, one of another length is replaced.
+func CreateUpload(w http.ResponseWriter, r *http.Request) {
+	lid := api.UserIdFromContext(r)
+
+	// Ignore error because cid is necessarily an int due to match param url
+	cid, _ := strconv.ParseInt(chi.URLParam(r, "videoContentId"), 10, 64)
+	c := models.Content{ID: cid, LeaderID: lid}
+
+	err := c.Find()
+
+	if err == sql.ErrNoRows {
+		api.SendError(w, http.StatusForbidden, messages.ActionForbidden)
+		return
+	}
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	if c.Status != models.ContentCreated && c.Status != models.ContentFailed {
+		api.SendError(w, http.StatusForbidden, messages.ContentAlreadyRecorded)
+		return
+	}
+
+	l, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+
+	if err != nil || l <= 0 {
+		api.SendError(w, http.StatusBadRequest, messages.InvalidUploadLength)
+		return
+	}
+
+	if l > *config.UploadMaxSize<<20 {
+		api.SendError(w, http.StatusRequestEntityTooLarge, messages.InvalidUploadLength)
+		return
+	}
+
+	u := models.Upload{ContentID: cid}
+	err = u.Open()
+
+	if err == nil && u.Length == l {
+		o, err := offset(u)
+
+		if err != nil {
+			logging.FromContext(r.Context()).Error(err)
+			api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+			return
+		}
+
+		w.Header().Set("Tus-Resumable", tusVersion)
+		w.Header().Set("Location", fmt.Sprintf("/video-content/%d/upload/%d", cid, u.ID))
+		w.Header().Set("Upload-Offset", strconv.FormatInt(o, 10))
+
+		api.Send(w, http.StatusCreated, u)
+		return
+	}
+
+	if err != nil && err != sql.ErrNoRows {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	if err == nil {
+		// The chunk being received would be appended to a removed file
+		if !lockUpload(cid) {
+			api.SendError(w, http.StatusConflict, messages.InvalidUploadOffset)
+			return
+		}
+
+		reject(r, u)
+		unlockUpload(cid)
+	}
+
+	u = models.Upload{ContentID: cid, Path: random.String(uploadPathSize), Length: l}
+
+	if err := os.MkdirAll(*config.UploadDir, 0755); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	f, err := os.OpenFile(partFile(u), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	f.Close()
+
+	if err := u.New(); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		os.Remove(partFile(u))
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Location", fmt.Sprintf("/video-content/%d/upload/%d", cid, u.ID))
+
+	api.Send(w, http.StatusCreated, u)
+}
+
+// HeadUpload returns the size already uploaded in the Upload-Offset header,
+// so an interrupted upload is resumed from there
+func HeadUpload(w http.ResponseWriter, r *http.Request) {
+	u, ok := findUpload(w, r)
+
+	if !ok {
+		return
+	}
+
+	o, err := offset(u)
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Length", strconv.FormatInt(u.Length, 10))
+	w.Header().Set("Upload-Offset", strconv.FormatInt(o, 10))
+	w.WriteHeader(http.StatusOK)
+}
+
+// PatchUpload appends the chunk of the body at the Upload-Offset header,
+// which has to be the size already uploaded.
+// Once the whole video is uploaded, its duration is checked against
+// the max duration and the minutes left to the leader,
+// then the content is made ready like with the hub.
+// One chunk of an upload is received at a time, a concurrent one gets a conflict.
+// The route isn't bounded by api.Timeout, a chunk lasts as long as the client sends it.
+func PatchUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		api.SendError(w, http.StatusUnsupportedMediaType, messages.InvalidParameters)
+		return
+	}
+
+	u, ok := findUpload(w, r)
+
+	if !ok {
+		return
+	}
+
+	// A chunk sent while another one is received would be appended
+	// at the same offset, its client resumes once it is done
+	if !lockUpload(u.ContentID) {
+		api.SendError(w, http.StatusConflict, messages.InvalidUploadOffset)
+		return
+	}
+
+	defer unlockUpload(u.ContentID)
+
+	// The offset is checked under the lock, the previous chunk being written
+	o, err := offset(u)
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	if h, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64); err != nil || h != o {
+		api.SendError(w, http.StatusConflict, messages.InvalidUploadOffset)
+		return
+	}
+
+	f, err := os.OpenFile(partFile(u), os.O_WRONLY|os.O_APPEND, 0644)
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	// The written bytes are kept when the client is gone,
+	// it resumes from the new offset
+	n, err := io.Copy(f, io.LimitReader(r.Body, u.Length-o))
+	f.Close()
+
+	if err != nil {
+		logging.FromContext(r.Context()).Warnf("upload %d interrupted at %d: %v", u.ID, o+n, err)
+	}
+
+	o += n
+
+	if o == u.Length && !complete(w, r, u) {
+		return
+	}
+
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Upload-Offset", strconv.FormatInt(o, 10))
+	api.Send(w, http.StatusNoContent, nil)
+}
+
+// complete checks the uploaded video and makes the content ready,
+// an error is sent when it fails.
+// A rejected video is removed with its upload.
+func complete(w http.ResponseWriter, r *http.Request, u models.Upload) bool {
+	s := models.Settings{}
+
+	if err := s.Load(); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return false
+	}
+
+	f, err := os.Open(partFile(u))
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return false
+	}
+
+	defer f.Close()
+
+	d, err := mp4Duration(f)
+
+	if err != nil {
+		logging.FromContext(r.Context()).Warnf("upload %d rejected: %v", u.ID, err)
+		reject(r, u)
+		api.SendError(w, http.StatusUnprocessableEntity, messages.InvalidVideoFile)
+		return false
+	}
+
+	if d > s.MaxDuration {
+		reject(r, u)
+		api.SendError(w, http.StatusUnprocessableEntity, messages.VideoTooLong)
+		return false
+	}
+
+	left, ok := secondsLeft(w, r, api.UserIdFromContext(r), u.ContentID)
+
+	if !ok {
+		reject(r, u)
+		return false
+	}
+
+	if left >= 0 && int64(d) > left {
+		reject(r, u)
+		api.SendError(w, http.StatusForbidden, messages.MinuteQuotaExceeded)
+		return false
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return false
+	}
+
+	key := models.VideoKey(u.Path)
+
+	if err := storage.Default.Put(r.Context(), key, f); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return false
+	}
+
+	c := models.Content{ID: u.ContentID, Path: u.Path, Duration: d}
+
+	if !makeReady(w, r, &c) {
+		if err := storage.Default.Delete(r.Context(), key); err != nil {
+			logging.FromContext(r.Context()).Error(err)
+		}
+
+		reject(r, u)
+		return false
+	}
+
+	reject(r, u)
+	return true
+}
+
+// reject removes the partial file of the upload and the upload,
+// once the video is stored or when it is rejected
+func reject(r *http.Request, u models.Upload) {
+	if err := os.Remove(partFile(u)); err != nil && !os.IsNotExist(err) {
+		logging.FromContext(r.Context()).Error(err)
+	}
+
+	if err := u.Delete(); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+	}
+}
+
+// offset returns the size already uploaded
+func offset(u models.Upload) (int64, error) {
+	i, err := os.Stat(partFile(u))
+
+	if err != nil {
+		return 0, err
+	}
+
+	return i.Size(), nil
+}
+
+// findUpload loads the upload of the url for a content of the leader,
+// an error is sent when it fails
+func findUpload(w http.ResponseWriter, r *http.Request) (models.Upload, bool) {
+	lid := api.UserIdFromContext(r)
+	l := models.Leader{User: &models.User{ID: lid}}
+
+	// Ignore error because ids are necessarily ints due to match param url
+	cid, _ := strconv.ParseInt(chi.URLParam(r, "videoContentId"), 10, 64)
+	uid, _ := strconv.ParseInt(chi.URLParam(r, "uploadId"), 10, 64)
+
+	u := models.Upload{ID: uid, ContentID: cid}
+
+	if b := l.IsOwner(cid); !b {
+		api.SendError(w, http.StatusForbidden, messages.ActionForbidden)
+		return u, false
+	}
+
+	err := u.Find()
+
+	if err == sql.ErrNoRows {
+		api.SendError(w, http.StatusNotFound, messages.InvalidUploadId)
+		return u, false
+	}
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return u, false
+	}
+
+	return u, true
+}
@@ -0,0 +1,355 @@
+package content
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+
+	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/background"
+	"gitlab.com/arnaud-web/neli-webservices/config"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/storage"
+	"gitlab.com/arnaud-web/neli-webservices/test/assert"
+	"gitlab.com/arnaud-web/neli-webservices/test/expect"
+	"gitlab.com/arnaud-web/neli-webservices/test/stub"
+)
+
+// assets sets a temporary local storage and upload directory,
+// removed by the returned function
+func assets(t *testing.T) func() {
+	d, err := ioutil.TempDir("", "assets")
+	assert.NoError(t, err)
+
+	s := storage.Default
+	storage.Default = storage.Local{Dir: d}
+
+	p := *config.UploadDir
+	*config.UploadDir = d + "/uploads"
+
+	return func() {
+		storage.Default = s
+		*config.UploadDir = p
+		os.RemoveAll(d)
+	}
+}
+
+// started creates the partial file of an upload with the chunk
+func started(t *testing.T, u models.Upload, chunk []byte) {
+	assert.NoError(t, os.MkdirAll(*config.UploadDir, 0755))
+	assert.NoError(t, ioutil.WriteFile(partFile(u), chunk, 0644))
+}
+
+// patch returns a request sending the chunk at the offset
+func patch(lid int64, u models.Upload, offset int, chunk []byte) *http.Request {
+	_, r := stub.HttpWithContext(lid)
+	r.Body = ioutil.NopCloser(bytes.NewReader(chunk))
+	r.Header.Set("Content-Type", "application/offset+octet-stream")
+	r.Header.Set("Upload-Offset", strconv.Itoa(offset))
+	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", u.ContentID))
+	return stub.AddUrlParam(r, "uploadId", fmt.Sprintf("%d", u.ID))
+}
+
+func TestCreateUpload(t *testing.T) {
+	defer assets(t)()
+
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	cid := expect.Content(lid, mock)
+	expect.UploadNone(cid, mock)
+	uid := expect.UploadInsert(cid, mock)
+
+	rr, r := stub.HttpWithContext(lid)
+	r.Header.Set("Upload-Length", "1024")
+	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
+
+	CreateUpload(rr, r)
+
+	assert.Response(t, rr, http.StatusCreated, "")
+	assert.Equals(t, rr.Header().Get("Location"), fmt.Sprintf("/video-content/%d/upload/%d", cid, uid))
+	assert.Equals(t, rr.Header().Get("Tus-Resumable"), tusVersion)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateUpload_Resumed(t *testing.T) {
+	defer assets(t)()
+
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	u := models.Upload{ID: int64(rand.Uint32()), Path: "abcdef", Length: 1024}
+	u.ContentID = expect.Content(lid, mock)
+	expect.UploadOpen(u, mock)
+
+	started(t, u, make([]byte, 100))
+
+	rr, r := stub.HttpWithContext(lid)
+	r.Header.Set("Upload-Length", "1024")
+	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", u.ContentID))
+
+	CreateUpload(rr, r)
+
+	assert.Response(t, rr, http.StatusCreated, "")
+	assert.Equals(t, rr.Header().Get("Location"), fmt.Sprintf("/video-content/%d/upload/%d", u.ContentID, u.ID))
+	assert.Equals(t, rr.Header().Get("Upload-Offset"), "100")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateUpload_Replaced(t *testing.T) {
+	defer assets(t)()
+
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	u := models.Upload{ID: int64(rand.Uint32()), Path: "abcdef", Length: 1024}
+	u.ContentID = expect.Content(lid, mock)
+	expect.UploadOpen(u, mock)
+	expect.UploadDelete(u.ID, mock)
+	uid := expect.UploadInsert(u.ContentID, mock)
+
+	started(t, u, make([]byte, 100))
+
+	rr, r := stub.HttpWithContext(lid)
+	r.Header.Set("Upload-Length", "2048")
+	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", u.ContentID))
+
+	CreateUpload(rr, r)
+
+	assert.Response(t, rr, http.StatusCreated, "")
+	assert.Equals(t, rr.Header().Get("Location"), fmt.Sprintf("/video-content/%d/upload/%d", u.ContentID, uid))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	if _, err := os.Stat(partFile(u)); !os.IsNotExist(err) {
+		t.Fatalf("Expected the previous partial file to be removed, got %v", err)
+	}
+}
+
+func TestCreateUpload_AlreadyRecorded(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	cid := expect.ContentRecorded(lid, mock)
+
+	rr, r := stub.HttpWithContext(lid)
+	r.Header.Set("Upload-Length", "1024")
+	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
+
+	CreateUpload(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusForbidden, messages.ContentAlreadyRecorded)
+}
+
+func TestCreateUpload_TooLarge(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	cid := expect.Content(lid, mock)
+
+	rr, r := stub.HttpWithContext(lid)
+	r.Header.Set("Upload-Length", strconv.FormatInt(*config.UploadMaxSize<<20+1, 10))
+	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
+
+	CreateUpload(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusRequestEntityTooLarge, messages.InvalidUploadLength)
+}
+
+func TestHeadUpload(t *testing.T) {
+	defer assets(t)()
+
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	u := models.Upload{ID: int64(rand.Uint32()), Path: "abcdef", Length: 1024}
+	u.ContentID = expect.Content(lid, mock)
+	expect.Upload(u, mock)
+
+	started(t, u, make([]byte, 100))
+
+	rr, r := stub.HttpWithContext(lid)
+	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", u.ContentID))
+	r = stub.AddUrlParam(r, "uploadId", fmt.Sprintf("%d", u.ID))
+
+	HeadUpload(rr, r)
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Equals(t, rr.Header().Get("Upload-Offset"), "100")
+	assert.Equals(t, rr.Header().Get("Upload-Length"), "1024")
+}
+
+func TestPatchUpload(t *testing.T) {
+	defer assets(t)()
+
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	u := models.Upload{ID: int64(rand.Uint32()), Path: "abcdef", Length: 1024}
+	u.ContentID = expect.Content(lid, mock)
+	expect.Upload(u, mock)
+
+	started(t, u, make([]byte, 100))
+
+	rr := httptest.NewRecorder()
+	PatchUpload(rr, patch(lid, u, 100, make([]byte, 200)))
+
+	assert.Response(t, rr, http.StatusNoContent, "")
+	assert.Equals(t, rr.Header().Get("Upload-Offset"), "300")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPatchUpload_InvalidOffset(t *testing.T) {
+	defer assets(t)()
+
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	u := models.Upload{ID: int64(rand.Uint32()), Path: "abcdef", Length: 1024}
+	u.ContentID = expect.Content(lid, mock)
+	expect.Upload(u, mock)
+
+	started(t, u, make([]byte, 100))
+
+	rr := httptest.NewRecorder()
+	PatchUpload(rr, patch(lid, u, 50, make([]byte, 200)))
+
+	assert.ResponseError(t, rr, http.StatusConflict, messages.InvalidUploadOffset)
+}
+
+func TestPatchUpload_Concurrent(t *testing.T) {
+	defer assets(t)()
+
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	u := models.Upload{ID: int64(rand.Uint32()), Path: "abcdef", Length: 1024}
+	u.ContentID = expect.Content(lid, mock)
+	expect.Upload(u, mock)
+
+	started(t, u, make([]byte, 100))
+
+	// Another chunk of the upload is being received
+	lockUpload(u.ContentID)
+	defer unlockUpload(u.ContentID)
+
+	rr := httptest.NewRecorder()
+	PatchUpload(rr, patch(lid, u, 100, make([]byte, 200)))
+
+	assert.ResponseError(t, rr, http.StatusConflict, messages.InvalidUploadOffset)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	if o, _ := offset(u); o != 100 {
+		t.Fatalf("Expected the chunk not to be appended, got offset %d", o)
+	}
+}
+
+func TestPatchUpload_Complete(t *testing.T) {
+	defer assets(t)()
+
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	f := mp4(42)
+	lid := int64(rand.Uint64())
+	u := models.Upload{ID: int64(rand.Uint32()), Path: "abcdef", Length: int64(len(f))}
+	u.ContentID = expect.Content(lid, mock)
+	expect.Upload(u, mock)
+	expect.Settings(mock)
+	expect.UsageExcept(lid, u.ContentID, models.Usage{}, mock)
+	expect.ContentReadyLock(u.ContentID, "", models.ContentCreated, mock)
+	expect.ContentUpdate(mock)
+	expect.RenditionsReplace(u.ContentID, mock)
+	expect.SelectShare(models.Share{UserID: int64(rand.Uint32())}, mock)
+	expect.UpdateShare(mock)
+	mock.ExpectCommit()
+	expect.UploadDelete(u.ID, mock)
+
+	started(t, u, f[:10])
+
+	rr := httptest.NewRecorder()
+	PatchUpload(rr, patch(lid, u, 10, f[10:]))
+	background.Wait(context.Background())
+
+	assert.Response(t, rr, http.StatusNoContent, "")
+	assert.Equals(t, rr.Header().Get("Upload-Offset"), strconv.Itoa(len(f)))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	if i, err := storage.Default.Stat(context.Background(), models.VideoKey(u.Path)); err != nil || i.Size != int64(len(f)) {
+		t.Fatalf("Expected the stored video, got %v", err)
+	}
+
+	if _, err := os.Stat(partFile(u)); !os.IsNotExist(err) {
+		t.Fatalf("Expected the partial file to be removed, got %v", err)
+	}
+}
+
+func TestPatchUpload_TooLong(t *testing.T) {
+	defer assets(t)()
+
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	f := mp4(3601)
+	lid := int64(rand.Uint64())
+	u := models.Upload{ID: int64(rand.Uint32()), Path: "abcdef", Length: int64(len(f))}
+	u.ContentID = expect.Content(lid, mock)
+	expect.Upload(u, mock)
+	expect.Settings(mock)
+	expect.UploadDelete(u.ID, mock)
+
+	started(t, u, nil)
+
+	rr := httptest.NewRecorder()
+	PatchUpload(rr, patch(lid, u, 0, f))
+
+	assert.ResponseError(t, rr, http.StatusUnprocessableEntity, messages.VideoTooLong)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	if _, err := os.Stat(partFile(u)); !os.IsNotExist(err) {
+		t.Fatalf("Expected the upload to be removed, got %v", err)
+	}
+}
+
+func TestPatchUpload_MinuteQuotaExceeded(t *testing.T) {
+	defer assets(t)()
+
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	f := mp4(120)
+	lid := int64(rand.Uint64())
+	u := models.Upload{ID: int64(rand.Uint32()), Path: "abcdef", Length: int64(len(f))}
+	u.ContentID = expect.Content(lid, mock)
+	expect.Upload(u, mock)
+	expect.Settings(mock)
+	expect.UsageExcept(lid, u.ContentID, models.Usage{Seconds: 3540, MaxMinutes: 60}, mock)
+	expect.UploadDelete(u.ID, mock)
+
+	started(t, u, nil)
+
+	rr := httptest.NewRecorder()
+	PatchUpload(rr, patch(lid, u, 0, f))
+
+	assert.ResponseError(t, rr, http.StatusForbidden, messages.MinuteQuotaExceeded)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	if _, err := os.Stat(partFile(u)); !os.IsNotExist(err) {
+		t.Fatalf("Expected the upload to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package content
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"gitlab.com/arnaud-web/neli-webservices/api"
+	"gitlab.com/arnaud-web/neli-webservices/api/asset"
+	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
+)
+
+// URL returns short-lived signed urls of the renditions of a ready content.
+// It is only returned to the leader of the content
+// and to the members having a share not expired yet,
+// never after the share expiration.
+func URL(w http.ResponseWriter, r *http.Request) {
+	uid := api.UserIdFromContext(r)
+
+	// Ignore error because cid is necessarily an int due to match param url
+	cid, _ := strconv.ParseInt(chi.URLParam(r, "videoContentId"), 10, 64)
+
+	switch api.RoleFromContext(r) {
+	case models.LeaderRole:
+		c := models.Content{ID: cid, LeaderID: uid}
+		err := c.Find()
+
+		if err == sql.ErrNoRows {
+			api.SendError(w, http.StatusForbidden, messages.ActionForbidden)
+			return
+		}
+
+		if err != nil {
+			logging.FromContext(r.Context()).Error(err)
+			api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+			return
+		}
+
+		if c.Status != models.ContentReady || c.Path == "" {
+			api.SendError(w, http.StatusConflict, messages.InvalidContentStatus)
+			return
+		}
+
+		send(w, r, c.ID, c.Path, time.Time{})
+	case models.MemberRole:
+		s := models.Shared{}
+		err := s.FindByContent(uid, cid)
+
+		if err == sql.ErrNoRows {
+			api.SendError(w, http.StatusForbidden, messages.ActionForbidden)
+			return
+		}
+
+		if err != nil {
+			logging.FromContext(r.Context()).Error(err)
+			api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+			return
+		}
+
+		send(w, r, s.ContentID, s.Path, s.ExpirationDate)
+	default:
+		api.SendError(w, http.StatusForbidden, messages.ActionForbidden)
+	}
+}
+
+// SharedURL returns short-lived signed urls of the renditions of the video
+// of the share url mailed to a member, without authentication.
+// The share has to be not expired yet.
+func SharedURL(w http.ResponseWriter, r *http.Request) {
+	// Ignore error because mid is necessarily an int due to match param url
+	mid, _ := strconv.ParseInt(chi.URLParam(r, "memberId"), 10, 64)
+
+	s := models.Shared{}
+	err := s.FindByURL(mid, models.ShareURL(mid, chi.URLParam(r, "token")))
+
+	if err == sql.ErrNoRows {
+		api.SendError(w, http.StatusNotFound, messages.InvalidShare)
+		return
+	}
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	send(w, r, s.ContentID, s.Path, s.ExpirationDate)
+}
+
+// send sends the signed urls of the renditions of the video stored under path
+func send(w http.ResponseWriter, r *http.Request, cid int64, path string, max time.Time) {
+	l, err := models.FindRenditions(cid, path)
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	u, err := asset.Video(path, l, max)
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	api.Send(w, http.StatusOK, u)
+}
@@ -0,0 +1,140 @@
+package content
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"testing"
+	"time"
+
+	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/config"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/test/assert"
+	"gitlab.com/arnaud-web/neli-webservices/test/expect"
+	"gitlab.com/arnaud-web/neli-webservices/test/stub"
+)
+
+func TestURL_Leader(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	cid := expect.ContentRecorded(lid, mock)
+	expect.Renditions(mock)
+
+	rr, r := stub.HttpWithRole(lid, models.LeaderRole)
+	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
+
+	URL(rr, r)
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), `"url":"/assets/abcdef/abcdef.mp4?expires=`)
+	assert.Contains(t, rr.Body.String(), `"renditions":[{"name":"source","type":"mp4","url":"/assets/abcdef/abcdef.mp4?expires=`)
+}
+
+func TestURL_Renditions(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	cid := expect.ContentRecorded(lid, mock)
+	expect.Renditions(mock,
+		models.Rendition{Name: "hls", Type: models.RenditionHLS, File: "hls/master.m3u8"},
+		models.Rendition{Name: "360p", Type: models.RenditionMP4, File: "abcdef_360.mp4", Width: 640, Height: 360},
+	)
+
+	rr, r := stub.HttpWithRole(lid, models.LeaderRole)
+	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
+
+	URL(rr, r)
+
+	b := rr.Body.String()
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, b, `"url":"/assets/abcdef/abcdef_360.mp4?expires=`)
+	assert.Contains(t, b, `{"name":"hls","type":"hls","url":"/assets/abcdef/hls/master.m3u8?expires=`)
+	assert.Contains(t, b, `{"name":"360p","type":"mp4","width":640,"height":360,"url":"/assets/abcdef/abcdef_360.mp4?expires=`)
+}
+
+func TestURL_NotReady(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	cid := expect.Content(lid, mock)
+
+	rr, r := stub.HttpWithRole(lid, models.LeaderRole)
+	r = stub.AddUrlParam(r, "videoContentId", fmt.Sprintf("%d", cid))
+
+	URL(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusConflict, messages.InvalidContentStatus)
+}
+
+func TestURL_Member(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	mid := int64(rand.Uint64())
+	exp := time.Now().Add(time.Minute)
+	expect.SharedContent(mid, 42, "abcdef", exp, mock)
+	expect.Renditions(mock)
+
+	rr, r := stub.HttpWithRole(mid, models.MemberRole)
+	r = stub.AddUrlParam(r, "videoContentId", "42")
+
+	URL(rr, r)
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), fmt.Sprintf("expires=%d", exp.Unix()))
+}
+
+func TestURL_MemberNotShared(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	mid := int64(rand.Uint64())
+	expect.SharedNotFound(mock)
+
+	rr, r := stub.HttpWithRole(mid, models.MemberRole)
+	r = stub.AddUrlParam(r, "videoContentId", "42")
+
+	URL(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusForbidden, messages.ActionForbidden)
+}
+
+func TestSharedURL(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	mid := int64(rand.Uint32())
+	expect.Shared("abcdef", time.Now().Add(time.Hour), mock).
+		WithArgs(models.ContentReady, mid, fmt.Sprintf("%s/%d/video/token", *config.URL, mid))
+	expect.Renditions(mock)
+
+	rr, r := stub.Http()
+	r = stub.AddUrlParam(r, "memberId", fmt.Sprintf("%d", mid))
+	r = stub.AddUrlParam(r, "token", "token")
+
+	SharedURL(rr, r)
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), `"url":"/assets/abcdef/abcdef.mp4?expires=`)
+}
+
+func TestSharedURL_Expired(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	expect.SharedNotFound(mock)
+
+	rr, r := stub.Http()
+	r = stub.AddUrlParam(r, "memberId", "42")
+	r = stub.AddUrlParam(r, "token", "token")
+
+	SharedURL(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusNotFound, messages.InvalidShare)
+}
@@ -0,0 +1,183 @@
+package device
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"gitlab.com/arnaud-web/neli-webservices/api"
+	"gitlab.com/arnaud-web/neli-webservices/api/audit"
+	"gitlab.com/arnaud-web/neli-webservices/api/capture"
+	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/config"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
+)
+
+// Create records a new device and assigns its leaders.
+// Name and address have to be not empty, transport is udp, tcp or http
+// and defaults to the configured one.
+func Create(w http.ResponseWriter, r *http.Request) {
+	d := models.Device{}
+
+	if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
+		return
+	}
+
+	defer r.Body.Close()
+
+	if !valid(&d) {
+		api.SendError(w, http.StatusBadRequest, messages.InvalidDeviceInfo)
+		return
+	}
+
+	if err := d.New(); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	audit.Record(r, models.AuditDeviceCreate, models.DeviceTarget, d.ID, nil, d)
+
+	api.Send(w, http.StatusOK, d)
+}
+
+// List returns all devices with their leaders and online status
+func List(w http.ResponseWriter, r *http.Request) {
+	l, err := models.ListDevices()
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	api.Send(w, http.StatusOK, l)
+}
+
+// Get returns the device with its leaders and online status
+func Get(w http.ResponseWriter, r *http.Request) {
+	d, ok := find(w, r)
+
+	if !ok {
+		return
+	}
+
+	api.Send(w, http.StatusOK, d)
+}
+
+// Edit replaces the device settings and its leaders.
+// Parameters are validated as in Create.
+func Edit(w http.ResponseWriter, r *http.Request) {
+	before, ok := find(w, r)
+
+	if !ok {
+		return
+	}
+
+	d := models.Device{}
+
+	if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
+		return
+	}
+
+	defer r.Body.Close()
+
+	d.ID = before.ID
+
+	if !valid(&d) {
+		api.SendError(w, http.StatusBadRequest, messages.InvalidDeviceInfo)
+		return
+	}
+
+	if err := d.Update(); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	audit.Record(r, models.AuditDeviceEdit, models.DeviceTarget, d.ID, settings(before), d)
+
+	api.Send(w, http.StatusNoContent, nil)
+}
+
+// Delete removes the device.
+// Its leaders send their orders to their other devices or to the configured proxy.
+func Delete(w http.ResponseWriter, r *http.Request) {
+	d, ok := find(w, r)
+
+	if !ok {
+		return
+	}
+
+	if err := d.Delete(); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	audit.Record(r, models.AuditDeviceDelete, models.DeviceTarget, d.ID, settings(d), nil)
+
+	api.Send(w, http.StatusNoContent, nil)
+}
+
+// find loads the device of the url, an error is sent when it fails
+func find(w http.ResponseWriter, r *http.Request) (models.Device, bool) {
+	// Ignore error because did is necessarily an int due to match param url
+	did, _ := strconv.ParseInt(chi.URLParam(r, "deviceId"), 10, 64)
+	d := models.Device{ID: did}
+
+	err := d.Find()
+
+	if err == sql.ErrNoRows {
+		api.SendError(w, http.StatusNotFound, messages.InvalidDeviceId)
+		return d, false
+	}
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return d, false
+	}
+
+	return d, true
+}
+
+// valid checks the device parameters and sets the default transport
+func valid(d *models.Device) bool {
+	if d.Transport == "" {
+		d.Transport = *config.ProxyTransport
+	}
+
+	if capture.CheckTransport(d.Transport) != nil {
+		return false
+	}
+
+	// A leader given twice is assigned once
+	l := []int64{}
+	seen := map[int64]bool{}
+
+	for _, lid := range d.Leaders {
+		if !seen[lid] {
+			seen[lid] = true
+			l = append(l, lid)
+		}
+	}
+
+	d.Leaders = l
+
+	return d.Validate() == nil && models.AreLeaders(d.Leaders)
+}
+
+// settings removes the online status which is not audited
+func settings(d models.Device) models.Device {
+	d.LastSeenAt = nil
+	d.Online = false
+	return d
+}
@@ -0,0 +1,202 @@
+package device
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"testing"
+
+	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/test/assert"
+	"gitlab.com/arnaud-web/neli-webservices/test/expect"
+	"gitlab.com/arnaud-web/neli-webservices/test/stub"
+)
+
+func TestCreate(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	d := stub.Device()
+	d.Leaders = []int64{int64(rand.Uint32()), int64(rand.Uint32())}
+
+	expect.Leaders(mock, d.Leaders...)
+	expect.DeviceInsert(d, mock)
+	expect.AuditInsert(models.AuditDeviceCreate, mock)
+
+	rr, r := stub.PostHttpWithContext(1, stub.DeviceParam(d.Name, d.Address, d.Transport, d.Leaders...))
+
+	Create(rr, r)
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), fmt.Sprintf(`"id":%d`, d.ID))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreate_DuplicateLeaders(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint32())
+	d := stub.Device()
+	d.Leaders = []int64{lid}
+
+	expect.Leaders(mock, lid)
+	expect.DeviceInsert(d, mock)
+	expect.AuditInsert(models.AuditDeviceCreate, mock)
+
+	rr, r := stub.PostHttpWithContext(1, stub.DeviceParam(d.Name, d.Address, d.Transport, lid, lid))
+
+	Create(rr, r)
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), fmt.Sprintf(`"leaders":[%d]`, lid))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreate_DefaultTransport(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	d := stub.Device()
+
+	expect.DeviceInsert(d, mock)
+	expect.AuditInsert(models.AuditDeviceCreate, mock)
+
+	rr, r := stub.PostHttpWithContext(1, stub.DeviceParam(d.Name, d.Address, ""))
+
+	Create(rr, r)
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), `"transport":"udp"`)
+}
+
+func TestCreate_NameMissing(t *testing.T) {
+	d := stub.Device()
+
+	rr, r := stub.PostHttpWithContext(1, stub.DeviceParam("", d.Address, d.Transport))
+
+	Create(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusBadRequest, messages.InvalidDeviceInfo)
+}
+
+func TestCreate_TransportInvalid(t *testing.T) {
+	d := stub.Device()
+
+	rr, r := stub.PostHttpWithContext(1, stub.DeviceParam(d.Name, d.Address, "carrier-pigeon"))
+
+	Create(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusBadRequest, messages.InvalidDeviceInfo)
+}
+
+func TestCreate_NotLeader(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	d := stub.Device()
+	lid := int64(rand.Uint32())
+
+	mock.ExpectQuery("SELECT id FROM user (.+)").WithArgs(lid, models.LeaderRole).WillReturnError(fmt.Errorf("no rows"))
+
+	rr, r := stub.PostHttpWithContext(1, stub.DeviceParam(d.Name, d.Address, d.Transport, lid))
+
+	Create(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusBadRequest, messages.InvalidDeviceInfo)
+}
+
+func TestList(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	d := stub.Device()
+	d.Online = true
+	d.Leaders = []int64{int64(rand.Uint32())}
+
+	expect.Devices(mock, d, stub.Device())
+
+	rr, r := stub.HttpWithContext(1)
+
+	List(rr, r)
+
+	b := rr.Body.String()
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, b, fmt.Sprintf(`"leaders":[%d]`, d.Leaders[0]))
+	assert.Contains(t, b, `"online":true`)
+	assert.Contains(t, b, `"leaders":[]`)
+}
+
+func TestGet(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	d := stub.Device()
+	expect.Device(d, mock)
+
+	rr, r := stub.HttpWithContext(1)
+	r = stub.AddUrlParam(r, "deviceId", fmt.Sprintf("%d", d.ID))
+
+	Get(rr, r)
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), fmt.Sprintf(`"address":"%s"`, d.Address))
+}
+
+func TestGet_NotFound(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	expect.DeviceNotFound(42, mock)
+
+	rr, r := stub.HttpWithContext(1)
+	r = stub.AddUrlParam(r, "deviceId", "42")
+
+	Get(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusNotFound, messages.InvalidDeviceId)
+}
+
+func TestEdit(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	d := stub.Device()
+	expect.Device(d, mock)
+
+	d.Address = "10.0.0.1:2000"
+	d.Transport = "tcp"
+	d.Leaders = []int64{int64(rand.Uint32())}
+
+	expect.Leaders(mock, d.Leaders...)
+	expect.DeviceUpdate(d, mock)
+	expect.AuditInsert(models.AuditDeviceEdit, mock)
+
+	rr, r := stub.PostHttpWithContext(1, stub.DeviceParam(d.Name, d.Address, d.Transport, d.Leaders...))
+	r = stub.AddUrlParam(r, "deviceId", fmt.Sprintf("%d", d.ID))
+
+	Edit(rr, r)
+
+	assert.Response(t, rr, http.StatusNoContent, "")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDelete(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	d := stub.Device()
+	expect.Device(d, mock)
+	expect.DeviceDelete(d.ID, mock)
+	expect.AuditInsert(models.AuditDeviceDelete, mock)
+
+	rr, r := stub.HttpWithContext(1)
+	r = stub.AddUrlParam(r, "deviceId", fmt.Sprintf("%d", d.ID))
+
+	Delete(rr, r)
+
+	assert.Response(t, rr, http.StatusNoContent, "")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"gitlab.com/arnaud-web/neli-webservices/config"
+)
+
+func ExampleRealIP() {
+	*config.TrustedProxies = "10.0.0.0/8"
+	defer func() { *config.TrustedProxies = "" }()
+
+	h := RealIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Println(r.RemoteAddr)
+	}))
+
+	for _, a := range []string{"10.1.2.3:4000", "203.0.113.9:4000"} {
+		r := httptest.NewRequest("GET", "/fake/url", nil)
+		r.RemoteAddr = a
+		r.Header.Set("X-Forwarded-For", "198.51.100.7")
+
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	// Output:
+	// 198.51.100.7
+	// 203.0.113.9:4000
+}
@@ -0,0 +1,15 @@
+package api
+
+import (
+	"fmt"
+
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/test/stub"
+)
+
+func ExampleRoleFromContext() {
+	_, r := stub.HttpWithRole(123, models.AdminRole)
+
+	fmt.Println(RoleFromContext(r))
+	// Output: administrator
+}
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"gitlab.com/arnaud-web/neli-webservices/config"
+)
+
+func ExampleTimeout() {
+	r, w := *config.ReadTimeout, *config.WriteTimeout
+	*config.ReadTimeout, *config.WriteTimeout = 0, 0
+	defer func() { *config.ReadTimeout, *config.WriteTimeout = r, w }()
+
+	h := Timeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/fake/url", nil))
+
+	fmt.Println(rr.Code, rr.Body.String())
+	// Output: 503 {"code":503,"message":"Request took too long"}
+}
@@ -0,0 +1,151 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"gitlab.com/arnaud-web/neli-webservices/api"
+	"gitlab.com/arnaud-web/neli-webservices/api/capture"
+	"gitlab.com/arnaud-web/neli-webservices/config"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
+	"gitlab.com/arnaud-web/neli-webservices/storage"
+)
+
+// Status values of the service and of each dependency
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Max duration of a single dependency check
+const timeout = 2 * time.Second
+
+// Check is the result of a dependency check
+type Check struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration int64  `json:"durationMs"`
+}
+
+// Report is the result of the readiness probe
+type Report struct {
+	Status string           `json:"status"`
+	Checks map[string]Check `json:"checks,omitempty"`
+}
+
+type checker func(ctx context.Context) error
+
+// Dependencies checked by Ready, replaceable for testing
+var (
+	pingDB    checker = func(ctx context.Context) error { return models.Ping() }
+	pingProxy checker = capture.Ping
+	pingSMTP  checker = smtp
+)
+
+// Live always answers ok while the process is able to serve requests
+func Live(w http.ResponseWriter, r *http.Request) {
+	api.Send(w, http.StatusOK, Report{Status: StatusOK})
+}
+
+// Ready checks the dependencies concurrently.
+// Database and assets are always checked, SMTP and proxy
+// only when enabled in configuration.
+// A 503 is returned when at least one check fails.
+func Ready(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]checker{
+		"database": pingDB,
+		"assets":   assets,
+	}
+
+	if *config.HealthSMTP {
+		checks["smtp"] = pingSMTP
+	}
+
+	if *config.HealthProxy {
+		checks["proxy"] = pingProxy
+	}
+
+	res := Report{Status: StatusOK, Checks: map[string]Check{}}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for n, c := range checks {
+		wg.Add(1)
+
+		go func(n string, c checker) {
+			defer wg.Done()
+
+			ch := run(r.Context(), c)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			res.Checks[n] = ch
+
+			if ch.Status != StatusOK {
+				res.Status = StatusFail
+				logging.FromContext(r.Context()).Warnf("%s check failed: %s", n, ch.Error)
+			}
+		}(n, c)
+	}
+
+	wg.Wait()
+
+	if res.Status != StatusOK {
+		api.Send(w, http.StatusServiceUnavailable, res)
+		return
+	}
+
+	api.Send(w, http.StatusOK, res)
+}
+
+// run executes the check with a timeout
+func run(ctx context.Context, c checker) Check {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	t := time.Now()
+	done := make(chan error, 1)
+
+	go func() { done <- c(ctx) }()
+
+	var err error
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	ch := Check{Status: StatusOK, Duration: time.Since(t).Nanoseconds() / int64(time.Millisecond)}
+
+	if err != nil {
+		ch.Status = StatusFail
+		ch.Error = err.Error()
+	}
+
+	return ch
+}
+
+// assets checks that the storage serves the poster placeholder
+func assets(ctx context.Context) error {
+	_, err := storage.Default.Stat(ctx, models.PlaceholderKey)
+	return err
+}
+
+// smtp checks that the SMTP host accepts connections
+func smtp(ctx context.Context) error {
+	c, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", *config.SMTPHost, *config.SMTPPort), timeout)
+
+	if err != nil {
+		return err
+	}
+
+	return c.Close()
+}
@@ -0,0 +1,120 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/arnaud-web/neli-webservices/config"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/storage"
+	"gitlab.com/arnaud-web/neli-webservices/test/assert"
+	"gitlab.com/arnaud-web/neli-webservices/test/stub"
+)
+
+func ok(ctx context.Context) error {
+	return nil
+}
+
+// placeholder sets a temporary storage holding the placeholder,
+// removed by the returned function
+func placeholder(t *testing.T) func() {
+	d, err := ioutil.TempDir("", "assets")
+	assert.NoError(t, err)
+
+	s := storage.Default
+	storage.Default = storage.Local{Dir: d}
+
+	f := filepath.Join(d, filepath.FromSlash(models.PlaceholderKey))
+	assert.NoError(t, os.MkdirAll(filepath.Dir(f), 0755))
+	assert.NoError(t, ioutil.WriteFile(f, []byte("jpeg"), 0644))
+
+	return func() {
+		storage.Default = s
+		os.RemoveAll(d)
+	}
+}
+
+func TestLive(t *testing.T) {
+	rr, r := stub.Http()
+
+	Live(rr, r)
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), `"status":"ok"`)
+}
+
+func TestReady(t *testing.T) {
+	pingDB = ok
+	defer placeholder(t)()
+
+	rr, r := stub.Http()
+
+	Ready(rr, r)
+
+	b := rr.Body.String()
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, b, `"database":{"status":"ok"`)
+	assert.Contains(t, b, `"assets":{"status":"ok"`)
+}
+
+func TestReady_DatabaseDown(t *testing.T) {
+	pingDB = func(ctx context.Context) error { return errors.New("connection refused") }
+	defer placeholder(t)()
+
+	rr, r := stub.Http()
+
+	Ready(rr, r)
+
+	b := rr.Body.String()
+
+	assert.Response(t, rr, http.StatusServiceUnavailable, "")
+	assert.Contains(t, b, `"status":"fail"`)
+	assert.Contains(t, b, `"error":"connection refused"`)
+}
+
+func TestReady_AssetsMissing(t *testing.T) {
+	pingDB = ok
+	s := storage.Default
+	storage.Default = storage.Local{Dir: "./missing"}
+
+	defer func() {
+		storage.Default = s
+	}()
+
+	rr, r := stub.Http()
+
+	Ready(rr, r)
+
+	assert.Response(t, rr, http.StatusServiceUnavailable, "")
+	assert.Contains(t, rr.Body.String(), `"assets":{"status":"fail"`)
+}
+
+func TestReady_Optional(t *testing.T) {
+	pingDB = ok
+	pingSMTP = ok
+	pingProxy = func(ctx context.Context) error { return errors.New("timeout") }
+	defer placeholder(t)()
+	*config.HealthSMTP = true
+	*config.HealthProxy = true
+
+	defer func() {
+		*config.HealthSMTP = false
+		*config.HealthProxy = false
+	}()
+
+	rr, r := stub.Http()
+
+	Ready(rr, r)
+
+	b := rr.Body.String()
+
+	assert.Response(t, rr, http.StatusServiceUnavailable, "")
+	assert.Contains(t, b, `"smtp":{"status":"ok"`)
+	assert.Contains(t, b, `"proxy":{"status":"fail","error":"timeout"`)
+}
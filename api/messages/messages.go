@@ -20,4 +20,29 @@ const (
 	NoProxyConnectionStopOrStatus = "N7 server or proxy BT are not responding"
 	Timeout                       = "N7 server is not responding"
 	NoCapture                     = "No capture"
+	ContentAlreadyRecorded        = "Content already recorded"
+	InvalidContentStatus          = "invalid video content status"
+	InvalidDeviceInfo             = "Invalid device info"
+	InvalidDeviceId               = "invalid deviceId"
+	InvalidUploadId               = "invalid uploadId"
+	InvalidUploadLength           = "invalid Upload-Length, it has to be positive and under the max upload size"
+	InvalidUploadOffset           = "Upload-Offset doesn't match the uploaded size"
+	InvalidVideoFile              = "invalid mp4 video file"
+	VideoTooLong                  = "Video is longer than the max duration"
+	InvalidPoster                 = "invalid poster, it has to be a jpeg or png image under the max poster size"
+	InvalidSignature              = "invalid or expired signed url"
+	InvalidShare                  = "invalid or expired share"
+	InvalidMarker                 = "invalid marker, it needs a title of 255 characters at most and a positive offset"
+	InvalidMarkerId               = "invalid markerId"
+	InvalidComment                = "invalid comment, it needs a body of 2000 characters at most and a positive offset"
+	InvalidCommentId              = "invalid commentId"
+	InvalidRendition              = "invalid renditions, each needs a unique name, a type mp4 or hls and a relative file"
+	InvalidTags                   = "invalid tags, each needs 64 characters at most and a content 20 tags at most"
+	InvalidTag                    = "no content has this tag"
+	InvalidPlaylist               = "invalid playlist, it needs a name of 255 characters at most and contents of the leader"
+	InvalidPlaylistId             = "invalid playlistId"
+	InvalidQuota                  = "invalid quota, minutes and videos have to be positive or 0 for unlimited"
+	VideoQuotaExceeded            = "Video quota exceeded"
+	MinuteQuotaExceeded           = "Recorded minutes quota exceeded"
+	RequestTimeout                = "Request took too long"
 )
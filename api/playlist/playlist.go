@@ -0,0 +1,418 @@
+package playlist
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi"
+	"gitlab.com/arnaud-web/neli-webservices/api"
+	"gitlab.com/arnaud-web/neli-webservices/api/asset"
+	"gitlab.com/arnaud-web/neli-webservices/api/audit"
+	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/background"
+	"gitlab.com/arnaud-web/neli-webservices/config"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
+	"gitlab.com/arnaud-web/neli-webservices/mail"
+	"gitlab.com/arnaud-web/neli-webservices/random"
+)
+
+// maxNameSize is the max number of characters of a playlist name
+const maxNameSize = 255
+
+type sharingInfo struct {
+	Ids            []int64 `json:"ids"`
+	ExpirationDate string  `json:"expirationDate"`
+	Message        string  `json:"message"`
+}
+
+type shareError struct {
+	Code    int     `json:"code"`
+	Message string  `json:"message"`
+	Ids     []int64 `json:"ids"`
+}
+
+// video is a content of a playlist with the signed urls of its renditions
+type video struct {
+	models.Content
+	Playback asset.Playback `json:"playback"`
+}
+
+// viewer is a playlist opened by the url mailed to a member,
+// its videos replace the contents of the playlist
+type viewer struct {
+	models.SharedPlaylist
+	Videos []video `json:"contents"`
+}
+
+// Create records a new playlist of the leader.
+// Name has to be not empty, contents have to be owned by the leader
+// and are kept in their order.
+func Create(w http.ResponseWriter, r *http.Request) {
+	p := models.Playlist{}
+
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
+		return
+	}
+
+	defer r.Body.Close()
+
+	p.LeaderID = api.UserIdFromContext(r)
+
+	if !valid(&p) {
+		api.SendError(w, http.StatusBadRequest, messages.InvalidPlaylist)
+		return
+	}
+
+	if err := p.New(); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	api.Send(w, http.StatusOK, p)
+}
+
+// List returns the playlists of the leader with their content ids
+func List(w http.ResponseWriter, r *http.Request) {
+	l := models.Leader{User: &models.User{ID: api.UserIdFromContext(r)}}
+	p, err := l.Playlists()
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	api.Send(w, http.StatusOK, p)
+}
+
+// Shared returns the playlists shared with the member by shares not expired yet
+func Shared(w http.ResponseWriter, r *http.Request) {
+	p, err := models.SharedPlaylists(api.UserIdFromContext(r))
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	api.Send(w, http.StatusOK, p)
+}
+
+// Get returns a playlist with its contents in their order.
+// The leader gets all its contents not deleted, a member having a share
+// not expired yet gets the ready ones, whose urls are returned by content.URL.
+func Get(w http.ResponseWriter, r *http.Request) {
+	switch api.RoleFromContext(r) {
+	case models.LeaderRole:
+		p, ok := find(w, r)
+
+		if !ok {
+			return
+		}
+
+		if err := p.FindContents(false); err != nil {
+			logging.FromContext(r.Context()).Error(err)
+			api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+			return
+		}
+
+		api.Send(w, http.StatusOK, p)
+	case models.MemberRole:
+		// Ignore error because pid is necessarily an int due to match param url
+		pid, _ := strconv.ParseInt(chi.URLParam(r, "playlistId"), 10, 64)
+		p := models.SharedPlaylist{Playlist: models.Playlist{ID: pid}}
+
+		err := p.Find(api.UserIdFromContext(r))
+
+		if err == sql.ErrNoRows {
+			api.SendError(w, http.StatusNotFound, messages.InvalidPlaylistId)
+			return
+		}
+
+		if err == nil {
+			err = p.FindContents(true)
+		}
+
+		if err != nil {
+			logging.FromContext(r.Context()).Error(err)
+			api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+			return
+		}
+
+		api.Send(w, http.StatusOK, p)
+	default:
+		api.SendError(w, http.StatusForbidden, messages.ActionForbidden)
+	}
+}
+
+// Edit replaces the name, description and contents of a playlist of the leader.
+// Parameters are validated as in Create.
+func Edit(w http.ResponseWriter, r *http.Request) {
+	p, ok := find(w, r)
+
+	if !ok {
+		return
+	}
+
+	id, lid := p.ID, p.LeaderID
+
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
+		return
+	}
+
+	defer r.Body.Close()
+
+	p.ID, p.LeaderID = id, lid
+
+	if !valid(&p) {
+		api.SendError(w, http.StatusBadRequest, messages.InvalidPlaylist)
+		return
+	}
+
+	if err := p.Update(); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	api.Send(w, http.StatusOK, p)
+}
+
+// Delete removes a playlist of the leader and its shares, its contents are kept
+func Delete(w http.ResponseWriter, r *http.Request) {
+	p, ok := find(w, r)
+
+	if !ok {
+		return
+	}
+
+	if err := p.Delete(); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	api.Send(w, http.StatusNoContent, nil)
+}
+
+// Share shares a playlist of the leader with each member of the ids,
+// with one message and expiration date. Each member gets a single mail
+// whatever the number of contents, the ones ready later are shared too.
+// The members out of the tribe or whose share failed are sent back.
+func Share(w http.ResponseWriter, r *http.Request) {
+	p, ok := find(w, r)
+
+	if !ok {
+		return
+	}
+
+	v := sharingInfo{}
+
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
+		return
+	}
+
+	defer r.Body.Close()
+
+	t, err := time.Parse(time.RFC3339, v.ExpirationDate)
+	if err != nil {
+		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
+		return
+	}
+
+	if err := p.FindContents(false); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	var ids []int64
+
+	for _, uid := range v.Ids {
+		m := models.Member{User: &models.User{ID: uid}}
+
+		if b := m.InTribe(p.LeaderID); !b {
+			ids = append(ids, uid)
+			continue
+		}
+
+		s := models.PlaylistShare{
+			PlaylistID:     p.ID,
+			UserID:         uid,
+			Token:          random.String(*config.TokenSize),
+			Message:        v.Message,
+			ExpirationDate: models.JSONTime(t),
+		}
+
+		if err := s.New(); err != nil {
+			logging.FromContext(r.Context()).Error(err)
+			ids = append(ids, uid)
+			continue
+		}
+
+		audit.Record(r, models.AuditPlaylistShare, models.PlaylistTarget, p.ID, nil, s)
+
+		background.Go(r.Context(), "mail.playlist", func() { sendShareURL(r.Context(), &s, &p) })
+	}
+
+	if len(ids) > 0 {
+		api.Send(w, http.StatusNotFound, shareError{
+			Code:    404,
+			Message: messages.InvalidUserIdOrContentId,
+			Ids:     ids,
+		})
+		return
+	}
+
+	api.Send(w, http.StatusNoContent, nil)
+}
+
+// Shares returns the shares of a playlist of the leader
+func Shares(w http.ResponseWriter, r *http.Request) {
+	p, ok := find(w, r)
+
+	if !ok {
+		return
+	}
+
+	l, err := models.ListPlaylistShares(p.ID)
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	api.Send(w, http.StatusOK, l)
+}
+
+// SharedURL returns the playlist of the share url mailed to a member,
+// without authentication. Its ready contents are returned in their order
+// with short-lived signed urls of their renditions, never valid after the share expiration.
+func SharedURL(w http.ResponseWriter, r *http.Request) {
+	// Ignore error because mid is necessarily an int due to match param url
+	mid, _ := strconv.ParseInt(chi.URLParam(r, "memberId"), 10, 64)
+
+	v := viewer{Videos: []video{}}
+	err := v.FindByToken(mid, chi.URLParam(r, "token"))
+
+	if err == sql.ErrNoRows {
+		api.SendError(w, http.StatusNotFound, messages.InvalidShare)
+		return
+	}
+
+	if err == nil {
+		err = v.FindContents(true)
+	}
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	for _, c := range v.Contents {
+		l, err := models.FindRenditions(c.ID, c.Path)
+
+		if err != nil {
+			logging.FromContext(r.Context()).Error(err)
+			api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+			return
+		}
+
+		pb, err := asset.Video(c.Path, l, time.Time(v.ExpirationDate))
+
+		if err != nil {
+			logging.FromContext(r.Context()).Error(err)
+			api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+			return
+		}
+
+		v.Videos = append(v.Videos, video{Content: c, Playback: pb})
+	}
+
+	api.Send(w, http.StatusOK, v)
+}
+
+// sendShareURL mails the url of the playlist share to the member.
+// It runs as background work, ctx only carries the request logger.
+func sendShareURL(ctx context.Context, s *models.PlaylistShare, p *models.Playlist) {
+	u := new(models.User)
+
+	if err := u.Find(s.UserID); err != nil {
+		logging.FromContext(ctx).Error(err)
+		return
+	}
+
+	l := new(models.User)
+
+	if err := l.Find(p.LeaderID); err != nil {
+		logging.FromContext(ctx).Error(err)
+		return
+	}
+
+	mail.Playlist(ctx, u, s, p, l)
+}
+
+// find loads the playlist of the url owned by the leader,
+// an error is sent when it fails
+func find(w http.ResponseWriter, r *http.Request) (models.Playlist, bool) {
+	// Ignore error because pid is necessarily an int due to match param url
+	pid, _ := strconv.ParseInt(chi.URLParam(r, "playlistId"), 10, 64)
+	p := models.Playlist{ID: pid, LeaderID: api.UserIdFromContext(r)}
+
+	err := p.Find()
+
+	if err == sql.ErrNoRows {
+		api.SendError(w, http.StatusNotFound, messages.InvalidPlaylistId)
+		return p, false
+	}
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return p, false
+	}
+
+	return p, true
+}
+
+// valid trims the name and description of the playlist and checks
+// the name is not empty nor too long and its contents are distinct
+// and owned by its leader
+func valid(p *models.Playlist) bool {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Description = strings.TrimSpace(p.Description)
+
+	if p.Name == "" || utf8.RuneCountInString(p.Name) > maxNameSize {
+		return false
+	}
+
+	if p.ContentIDs == nil {
+		p.ContentIDs = []int64{}
+	}
+
+	l := models.Leader{User: &models.User{ID: p.LeaderID}}
+	seen := map[int64]bool{}
+
+	for _, cid := range p.ContentIDs {
+		if seen[cid] || !l.IsOwner(cid) {
+			return false
+		}
+
+		seen[cid] = true
+	}
+
+	return true
+}
@@ -0,0 +1,314 @@
+package playlist
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/icrowley/fake"
+	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/background"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/test/assert"
+	"gitlab.com/arnaud-web/neli-webservices/test/expect"
+	"gitlab.com/arnaud-web/neli-webservices/test/stub"
+)
+
+// as returns a request of the user with the role on the playlist, sending the body
+func as(uid int64, role string, pid int64, body interface{}) *http.Request {
+	_, r := stub.HttpWithRole(uid, role)
+	b, _ := json.Marshal(body)
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	return stub.AddUrlParam(r, "playlistId", fmt.Sprintf("%d", pid))
+}
+
+func TestCreate(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	p := models.Playlist{LeaderID: lid, Name: "Match 2026", Description: fake.Sentence()}
+	p.ContentIDs = []int64{expect.Content(lid, mock), expect.Content(lid, mock)}
+	id := expect.PlaylistInsert(p, mock)
+
+	rr, r := stub.PostHttpWithContext(lid, map[string]interface{}{
+		"name":        " Match 2026 ",
+		"description": p.Description,
+		"contentIds":  p.ContentIDs,
+	})
+
+	Create(rr, r)
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), fmt.Sprintf(`"id":%d`, id))
+	assert.Contains(t, rr.Body.String(), fmt.Sprintf(`"contentIds":[%d,%d]`, p.ContentIDs[0], p.ContentIDs[1]))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreate_Invalid(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+
+	rr, r := stub.PostHttpWithContext(lid, map[string]interface{}{"name": " "})
+
+	Create(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusBadRequest, messages.InvalidPlaylist)
+
+	cid := expect.Content(lid, mock)
+	rr, r = stub.PostHttpWithContext(lid, map[string]interface{}{"name": "twice", "contentIds": []int64{cid, cid}})
+
+	Create(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusBadRequest, messages.InvalidPlaylist)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreate_NotOwner(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	expect.ContentNotFound(lid, mock)
+
+	rr, r := stub.PostHttpWithContext(lid, map[string]interface{}{"name": "other", "contentIds": []int64{42}})
+
+	Create(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusBadRequest, messages.InvalidPlaylist)
+}
+
+func TestList(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	expect.Playlists(lid, mock,
+		models.Playlist{ID: 1, Name: "first", ContentIDs: []int64{3, 2}},
+		models.Playlist{ID: 2, Name: "empty"},
+	)
+
+	rr, r := stub.HttpWithContext(lid)
+
+	List(rr, r)
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), `"name":"first","description":"","contentIds":[3,2]`)
+	assert.Contains(t, rr.Body.String(), `"name":"empty","description":"","contentIds":[]`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGet_Leader(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	p := models.Playlist{ID: int64(rand.Uint32()), LeaderID: lid, Name: fake.Title(), ContentIDs: []int64{7, 8}}
+	expect.Playlist(p, mock)
+	expect.PlaylistContents(p.ID, models.ContentDeleted, mock,
+		models.Content{ID: 7, Status: models.ContentReady},
+		models.Content{ID: 8, Status: models.ContentProcessing},
+	)
+
+	rr, r := stub.HttpWithRole(lid, models.LeaderRole)
+	r = stub.AddUrlParam(r, "playlistId", fmt.Sprintf("%d", p.ID))
+
+	Get(rr, r)
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), `"videoContentId":8`)
+	assert.Contains(t, rr.Body.String(), `"status":"processing"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGet_Member(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	mid := int64(rand.Uint64())
+	p := models.Playlist{ID: int64(rand.Uint32()), LeaderID: int64(rand.Uint32()), Name: fake.Title()}
+	expect.SharedPlaylist(mid, p, time.Now().Add(time.Hour), mock).WithArgs(mid, p.ID)
+	expect.PlaylistContents(p.ID, models.ContentReady, mock, models.Content{ID: 7, Status: models.ContentReady})
+
+	rr, r := stub.HttpWithRole(mid, models.MemberRole)
+	r = stub.AddUrlParam(r, "playlistId", fmt.Sprintf("%d", p.ID))
+
+	Get(rr, r)
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), `"videoContentId":7`)
+	assert.Contains(t, rr.Body.String(), `"expirationDate":`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGet_MemberNotShared(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	expect.SharedPlaylistNotFound(mock)
+
+	rr, r := stub.HttpWithRole(int64(rand.Uint64()), models.MemberRole)
+	r = stub.AddUrlParam(r, "playlistId", "42")
+
+	Get(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusNotFound, messages.InvalidPlaylistId)
+}
+
+func TestEdit(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	p := models.Playlist{ID: int64(rand.Uint32()), LeaderID: lid, Name: "before", ContentIDs: []int64{7}}
+	expect.Playlist(p, mock)
+
+	p.Name = "after"
+	p.ContentIDs = []int64{expect.Content(lid, mock)}
+	expect.PlaylistUpdate(p, mock)
+
+	rr := httptest.NewRecorder()
+	Edit(rr, as(lid, models.LeaderRole, p.ID, map[string]interface{}{"name": "after", "contentIds": p.ContentIDs}))
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), `"name":"after"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEdit_NotFound(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	expect.PlaylistNotFound(mock)
+
+	rr := httptest.NewRecorder()
+	Edit(rr, as(int64(rand.Uint64()), models.LeaderRole, 42, map[string]interface{}{"name": "after"}))
+
+	assert.ResponseError(t, rr, http.StatusNotFound, messages.InvalidPlaylistId)
+}
+
+func TestDelete(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	p := models.Playlist{ID: int64(rand.Uint32()), LeaderID: lid, Name: fake.Title()}
+	expect.Playlist(p, mock)
+	expect.PlaylistDelete(p.ID, mock)
+
+	rr := httptest.NewRecorder()
+	Delete(rr, as(lid, models.LeaderRole, p.ID, nil))
+
+	assert.Response(t, rr, http.StatusNoContent, "")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShare(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	uid := int64(rand.Uint64())
+	p := models.Playlist{ID: int64(rand.Uint32()), LeaderID: lid, Name: fake.Title()}
+	expect.Playlist(p, mock)
+	expect.PlaylistContents(p.ID, models.ContentDeleted, mock)
+	expect.IsInTribe(lid, uid, mock)
+	expect.PlaylistShareInsert(p.ID, uid, mock)
+	expect.AuditInsert(models.AuditPlaylistShare, mock)
+
+	rr := httptest.NewRecorder()
+	Share(rr, as(lid, models.LeaderRole, p.ID, map[string]interface{}{
+		"ids":            []int64{uid},
+		"message":        fake.Sentence(),
+		"expirationDate": time.Now().Add(time.Hour).Format(time.RFC3339),
+	}))
+	background.Wait(context.Background())
+
+	assert.Response(t, rr, http.StatusNoContent, "")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShare_NotInTribe(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	uid := int64(rand.Uint64())
+	p := models.Playlist{ID: int64(rand.Uint32()), LeaderID: lid, Name: fake.Title()}
+	expect.Playlist(p, mock)
+	expect.PlaylistContents(p.ID, models.ContentDeleted, mock)
+	expect.IsInTribe(uid, uid, mock)
+
+	rr := httptest.NewRecorder()
+	Share(rr, as(lid, models.LeaderRole, p.ID, map[string]interface{}{
+		"ids":            []int64{uid},
+		"message":        fake.Sentence(),
+		"expirationDate": time.Now().Add(time.Hour).Format(time.RFC3339),
+	}))
+
+	assert.Response(t, rr, http.StatusNotFound, "")
+	assert.Contains(t, rr.Body.String(), fmt.Sprintf("%d", uid))
+}
+
+func TestShares(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	p := models.Playlist{ID: int64(rand.Uint32()), LeaderID: lid, Name: fake.Title()}
+	expect.Playlist(p, mock)
+	expect.PlaylistShares(p.ID, mock, models.PlaylistShare{ID: 1, UserID: 5, UserName: "Ada Lovelace", Message: "hi"})
+
+	rr := httptest.NewRecorder()
+	Shares(rr, as(lid, models.LeaderRole, p.ID, nil))
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), `"memberId":5,"name":"Ada Lovelace","message":"hi"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSharedURL(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	mid := int64(rand.Uint32())
+	p := models.Playlist{ID: int64(rand.Uint32()), Name: fake.Title()}
+	expect.SharedPlaylist(mid, p, time.Now().Add(time.Hour), mock).WithArgs(mid, "token")
+	expect.PlaylistContents(p.ID, models.ContentReady, mock, models.Content{ID: 7, Path: "abcdef", Status: models.ContentReady})
+	expect.Renditions(mock)
+
+	rr, r := stub.Http()
+	r = stub.AddUrlParam(r, "memberId", fmt.Sprintf("%d", mid))
+	r = stub.AddUrlParam(r, "token", "token")
+
+	SharedURL(rr, r)
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), `"videoContentId":7`)
+	assert.Contains(t, rr.Body.String(), `"url":"/assets/abcdef/abcdef.mp4?expires=`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSharedURL_Expired(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	expect.SharedPlaylistNotFound(mock)
+
+	rr, r := stub.Http()
+	r = stub.AddUrlParam(r, "memberId", "42")
+	r = stub.AddUrlParam(r, "token", "token")
+
+	SharedURL(rr, r)
+
+	assert.ResponseError(t, rr, http.StatusNotFound, messages.InvalidShare)
+}
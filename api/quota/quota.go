@@ -0,0 +1,206 @@
+package quota
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"gitlab.com/arnaud-web/neli-webservices/api"
+	"gitlab.com/arnaud-web/neli-webservices/api/audit"
+	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
+)
+
+// GetDefault returns the quota of the leaders without override
+func GetDefault(w http.ResponseWriter, r *http.Request) {
+	q, err := models.DefaultQuota()
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	api.Send(w, http.StatusOK, q)
+}
+
+// SetDefault sets the quota of the leaders without override.
+// Minutes and videos are both required, 0 is unlimited.
+func SetDefault(w http.ResponseWriter, r *http.Request) {
+	q := models.Quota{}
+
+	if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
+		return
+	}
+
+	defer r.Body.Close()
+
+	if q.Minutes == nil || q.Videos == nil || !valid(q) {
+		api.SendError(w, http.StatusBadRequest, messages.InvalidQuota)
+		return
+	}
+
+	// Keep previous value for audit log
+	before, err := models.DefaultQuota()
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+	}
+
+	if err := q.SetDefault(); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	audit.Record(r, models.AuditQuotaDefault, models.SettingsTarget, 0, before, q)
+
+	api.Send(w, http.StatusNoContent, nil)
+}
+
+// Get returns the override of an admin or a leader, null limits are inherited
+func Get(w http.ResponseWriter, r *http.Request) {
+	uid, ok := target(w, r)
+
+	if !ok {
+		return
+	}
+
+	q, err := models.FindQuota(uid)
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	api.Send(w, http.StatusOK, q)
+}
+
+// Set replaces the override of an admin or a leader.
+// A super admin overrides admins and leaders, an admin overrides leaders.
+// A null limit is inherited from the admin of the leader then from the defaults, 0 is unlimited.
+func Set(w http.ResponseWriter, r *http.Request) {
+	uid, ok := target(w, r)
+
+	if !ok {
+		return
+	}
+
+	q := models.Quota{}
+
+	if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
+		return
+	}
+
+	defer r.Body.Close()
+
+	if !valid(q) {
+		api.SendError(w, http.StatusBadRequest, messages.InvalidQuota)
+		return
+	}
+
+	// Keep previous value for audit log
+	before, err := models.FindQuota(uid)
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+	}
+
+	q.UserID = uid
+
+	if err := q.Set(); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	audit.Record(r, models.AuditQuotaEdit, models.QuotaTarget, uid, before, q)
+
+	api.Send(w, http.StatusNoContent, nil)
+}
+
+// Usage returns the consumption of the leader against their quota
+func Usage(w http.ResponseWriter, r *http.Request) {
+	usage(w, r, api.UserIdFromContext(r))
+}
+
+// LeaderUsage returns the consumption of a leader against their quota
+// to an admin or a super admin
+func LeaderUsage(w http.ResponseWriter, r *http.Request) {
+	if role := api.RoleFromContext(r); role != models.AdminRole && role != models.SuperAdminRole {
+		api.SendError(w, http.StatusForbidden, messages.ActionForbidden)
+		return
+	}
+
+	// Ignore error because uid is necessarily an int due to match param url
+	uid, _ := strconv.ParseInt(chi.URLParam(r, "userId"), 10, 64)
+	usage(w, r, uid)
+}
+
+func usage(w http.ResponseWriter, r *http.Request, lid int64) {
+	l := models.Leader{User: &models.User{ID: lid}}
+	u, err := l.Usage()
+
+	if err == sql.ErrNoRows {
+		api.SendError(w, http.StatusNotFound, messages.UserNotFound)
+		return
+	}
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return
+	}
+
+	api.Send(w, http.StatusOK, u)
+}
+
+// target returns the user of the url whose override is managed,
+// a leader by an admin or a super admin, an admin by a super admin.
+// An error is sent when it is not one of them.
+func target(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	role := api.RoleFromContext(r)
+
+	if role != models.AdminRole && role != models.SuperAdminRole {
+		api.SendError(w, http.StatusForbidden, messages.ActionForbidden)
+		return 0, false
+	}
+
+	// Ignore error because uid is necessarily an int due to match param url
+	uid, _ := strconv.ParseInt(chi.URLParam(r, "userId"), 10, 64)
+	u := models.User{}
+
+	err := u.Find(uid)
+
+	if err == sql.ErrNoRows {
+		api.SendError(w, http.StatusNotFound, messages.UserNotFound)
+		return 0, false
+	}
+
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
+		return 0, false
+	}
+
+	switch {
+	case u.Role == models.LeaderRole:
+	case u.Role == models.AdminRole && role == models.SuperAdminRole:
+	default:
+		api.SendError(w, http.StatusForbidden, messages.ActionForbidden)
+		return 0, false
+	}
+
+	return uid, true
+}
+
+// valid checks the limits set are not negative
+func valid(q models.Quota) bool {
+	return (q.Minutes == nil || *q.Minutes >= 0) && (q.Videos == nil || *q.Videos >= 0)
+}
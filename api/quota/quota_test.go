@@ -0,0 +1,210 @@
+package quota
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/test/assert"
+	"gitlab.com/arnaud-web/neli-webservices/test/expect"
+	"gitlab.com/arnaud-web/neli-webservices/test/stub"
+)
+
+// as returns a request of the user with the role about the user of the url
+func as(uid int64, role string, target int64, body interface{}) *http.Request {
+	_, r := stub.HttpWithRole(uid, role)
+	b, _ := json.Marshal(body)
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	return stub.AddUrlParam(r, "userId", fmt.Sprintf("%d", target))
+}
+
+func TestGetDefault(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	expect.QuotaDefault(600, 20, mock)
+
+	rr := httptest.NewRecorder()
+	GetDefault(rr, as(int64(rand.Uint64()), models.SuperAdminRole, 0, nil))
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), `{"minutes":600,"videos":20}`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetDefault(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	expect.QuotaDefault(0, 0, mock)
+	expect.QuotaDefaultUpdate(600, 20, mock)
+	expect.AuditInsert(models.AuditQuotaDefault, mock)
+
+	rr := httptest.NewRecorder()
+	SetDefault(rr, as(int64(rand.Uint64()), models.SuperAdminRole, 0, map[string]int{"minutes": 600, "videos": 20}))
+
+	assert.Response(t, rr, http.StatusNoContent, "")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetDefault_Invalid(t *testing.T) {
+	for _, p := range []map[string]int{{"minutes": 600}, {"minutes": -1, "videos": 20}} {
+		rr := httptest.NewRecorder()
+		SetDefault(rr, as(int64(rand.Uint64()), models.SuperAdminRole, 0, p))
+
+		assert.ResponseError(t, rr, http.StatusBadRequest, messages.InvalidQuota)
+	}
+}
+
+func TestGet(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	videos := 5
+
+	expect.UserRole(lid, models.LeaderRole, mock)
+	expect.Quota(lid, models.Quota{Videos: &videos}, mock)
+
+	rr := httptest.NewRecorder()
+	Get(rr, as(int64(rand.Uint64()), models.AdminRole, lid, nil))
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), `"minutes":null,"videos":5}`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGet_NoOverride(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	aid := int64(rand.Uint64())
+
+	expect.UserRole(aid, models.AdminRole, mock)
+	expect.QuotaNotFound(aid, mock)
+
+	rr := httptest.NewRecorder()
+	Get(rr, as(int64(rand.Uint64()), models.SuperAdminRole, aid, nil))
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), fmt.Sprintf(`{"userId":%d,"minutes":null,"videos":null}`, aid))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSet(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+
+	expect.UserRole(lid, models.LeaderRole, mock)
+	expect.QuotaNotFound(lid, mock)
+	expect.QuotaSet(lid, 120, nil, mock)
+	expect.AuditInsert(models.AuditQuotaEdit, mock)
+
+	rr := httptest.NewRecorder()
+	Set(rr, as(int64(rand.Uint64()), models.AdminRole, lid, map[string]interface{}{"minutes": 120, "videos": nil}))
+
+	assert.Response(t, rr, http.StatusNoContent, "")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSet_Invalid(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	expect.UserRole(lid, models.LeaderRole, mock)
+
+	rr := httptest.NewRecorder()
+	Set(rr, as(int64(rand.Uint64()), models.AdminRole, lid, map[string]int{"videos": -3}))
+
+	assert.ResponseError(t, rr, http.StatusBadRequest, messages.InvalidQuota)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSet_AdminByAdmin(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	aid := int64(rand.Uint64())
+	expect.UserRole(aid, models.AdminRole, mock)
+
+	rr := httptest.NewRecorder()
+	Set(rr, as(int64(rand.Uint64()), models.AdminRole, aid, map[string]int{"videos": 3}))
+
+	assert.ResponseError(t, rr, http.StatusForbidden, messages.ActionForbidden)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSet_ByLeader(t *testing.T) {
+	rr := httptest.NewRecorder()
+	Set(rr, as(int64(rand.Uint64()), models.LeaderRole, int64(rand.Uint64()), map[string]int{"videos": 3}))
+
+	assert.ResponseError(t, rr, http.StatusForbidden, messages.ActionForbidden)
+}
+
+func TestSet_UserNotFound(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	uid := int64(rand.Uint64())
+	expect.UserNotFound(uid, mock)
+
+	rr := httptest.NewRecorder()
+	Set(rr, as(int64(rand.Uint64()), models.SuperAdminRole, uid, map[string]int{"videos": 3}))
+
+	assert.ResponseError(t, rr, http.StatusNotFound, messages.UserNotFound)
+}
+
+func TestUsage(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	expect.Usage(lid, models.Usage{Videos: 4, Seconds: 750, MaxVideos: 10, MaxMinutes: 60}, mock)
+
+	rr, r := stub.HttpWithContext(lid)
+
+	Usage(rr, r)
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), `{"videos":4,"seconds":750,"minutes":12,"maxVideos":10,"maxMinutes":60}`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLeaderUsage(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	lid := int64(rand.Uint64())
+	expect.Usage(lid, models.Usage{Videos: 1, Seconds: 60}, mock)
+
+	rr := httptest.NewRecorder()
+	LeaderUsage(rr, as(int64(rand.Uint64()), models.AdminRole, lid, nil))
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), `"minutes":1,"maxVideos":0,"maxMinutes":0}`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLeaderUsage_NotLeader(t *testing.T) {
+	mock, mockDB := stub.DB()
+	defer mockDB.Close()
+
+	uid := int64(rand.Uint64())
+	expect.UsageNotFound(uid, mock)
+
+	rr := httptest.NewRecorder()
+	LeaderUsage(rr, as(int64(rand.Uint64()), models.SuperAdminRole, uid, nil))
+
+	assert.ResponseError(t, rr, http.StatusNotFound, messages.UserNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
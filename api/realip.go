@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/middleware"
+	"gitlab.com/arnaud-web/neli-webservices/config"
+)
+
+// RealIP sets the remote address of the request to the client ip sent
+// in X-Forwarded-For or X-Real-IP headers, only when the request comes from
+// one of the trusted proxies. Other clients keep their own address
+// so they can't forge the ip recorded in logs and audit.
+func RealIP(next http.Handler) http.Handler {
+	proxies := trustedProxies(*config.TrustedProxies)
+	real := middleware.RealIP(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, _, err := net.SplitHostPort(r.RemoteAddr)
+
+		if err != nil {
+			h = r.RemoteAddr
+		}
+
+		ip := net.ParseIP(h)
+
+		for _, n := range proxies {
+			if ip != nil && n.Contains(ip) {
+				real.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// trustedProxies parses the comma separated ips and cidrs of the proxies,
+// invalid entries are ignored
+func trustedProxies(s string) []*net.IPNet {
+	var l []*net.IPNet
+
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+
+		if p == "" {
+			continue
+		}
+
+		if !strings.Contains(p, "/") {
+			if strings.Contains(p, ":") {
+				p += "/128"
+			} else {
+				p += "/32"
+			}
+		}
+
+		if _, n, err := net.ParseCIDR(p); err == nil {
+			l = append(l, n)
+		}
+	}
+
+	return l
+}
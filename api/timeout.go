@@ -0,0 +1,22 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/config"
+)
+
+// Timeout bounds the duration of a request, from reading its body
+// to writing its response, to the read and write timeouts.
+// A request running longer gets a 503 error.
+// Long-lived requests like streams, asset downloads and upload chunks
+// are not wrapped, the server only bounds their headers and idle time.
+func Timeout(next http.Handler) http.Handler {
+	d := time.Duration(*config.ReadTimeout+*config.WriteTimeout) * time.Second
+	b, _ := json.Marshal(JSONError{http.StatusServiceUnavailable, messages.RequestTimeout})
+
+	return http.TimeoutHandler(next, d, string(b))
+}
@@ -2,22 +2,21 @@ package admin
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
-	"os"
 
 	"gitlab.com/arnaud-web/neli-webservices/api"
+	"gitlab.com/arnaud-web/neli-webservices/api/audit"
 	"gitlab.com/arnaud-web/neli-webservices/api/messages"
 	"gitlab.com/arnaud-web/neli-webservices/api/user"
+	"gitlab.com/arnaud-web/neli-webservices/background"
 	"gitlab.com/arnaud-web/neli-webservices/config"
 	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
 	"gitlab.com/arnaud-web/neli-webservices/mail"
 	"gitlab.com/arnaud-web/neli-webservices/random"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var logger = log.New(os.Stdout, "", log.LstdFlags|log.Lshortfile)
-
 // Create create a new user with administrator role.
 // Password and login are random values.
 // Email has to be valid, firstname and lastname not empty.
@@ -25,7 +24,7 @@ func Create(w http.ResponseWriter, r *http.Request) {
 	u := models.Admin{User: new(models.User)}
 
 	if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
 		return
 	}
@@ -46,7 +45,7 @@ func Create(w http.ResponseWriter, r *http.Request) {
 	h, err := bcrypt.GenerateFromPassword([]byte(pw), bcrypt.DefaultCost)
 
 	if err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusInternalServerError, messages.TechnicalError)
 		return
 	}
@@ -55,12 +54,14 @@ func Create(w http.ResponseWriter, r *http.Request) {
 	u.Role = models.AdminRole
 
 	if err = u.New(); err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusBadRequest, messages.InvalidUserInfo)
 		return
 	}
 
-	go mail.Admin(pw, u.Email)
+	background.Go(r.Context(), "mail.admin", func() { mail.Admin(r.Context(), pw, u.Email) })
+
+	audit.Record(r, models.AuditAdminCreate, models.UserTarget, u.ID, nil, u.User.ToJSON())
 
 	res := user.Resource{UserInfo: &user.UserInfo{}}
 	res.UserID = u.ID
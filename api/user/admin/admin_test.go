@@ -22,6 +22,7 @@ func TestCreate(t *testing.T) {
 	p := stub.UserParam(u.Email, u.Firstname, u.Lastname)
 
 	expect.AdminInsert(u, mock)
+	expect.AuditInsert(models.AuditAdminCreate, mock)
 
 	rr, r := stub.PostHttp(p)
 
@@ -2,29 +2,28 @@ package leader
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
-	"os"
 
 	"gitlab.com/arnaud-web/neli-webservices/api"
+	"gitlab.com/arnaud-web/neli-webservices/api/audit"
 	"gitlab.com/arnaud-web/neli-webservices/api/messages"
 	"gitlab.com/arnaud-web/neli-webservices/api/user"
+	"gitlab.com/arnaud-web/neli-webservices/background"
 	"gitlab.com/arnaud-web/neli-webservices/config"
 	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
 	"gitlab.com/arnaud-web/neli-webservices/mail"
 	"gitlab.com/arnaud-web/neli-webservices/random"
 )
 
-var logger = log.New(os.Stdout, "", log.LstdFlags|log.Lshortfile)
-
 // Create creates a new user with role leader.
-// Only an administrator can do that.
+// Only an administrator can do that, they manage the quota of the leader.
 // Email has to be valid, firstname and lastname not empty.
 func Create(w http.ResponseWriter, r *http.Request) {
 	u := models.Leader{User: new(models.User)}
 
 	if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
 		return
 	}
@@ -45,12 +44,19 @@ func Create(w http.ResponseWriter, r *http.Request) {
 	u.PasswordResetToken = random.String(*config.TokenSize)
 
 	if err := u.New(); err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	go mail.Leader(u.PasswordResetToken, u.Email)
+	// The quota override of the admin applies to the leader
+	if err := u.SetAdmin(api.UserIdFromContext(r)); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+	}
+
+	background.Go(r.Context(), "mail.leader", func() { mail.Leader(r.Context(), u.PasswordResetToken, u.Email) })
+
+	audit.Record(r, models.AuditLeaderCreate, models.UserTarget, u.ID, nil, u.User.ToJSON())
 
 	res := user.Resource{UserInfo: &user.UserInfo{}}
 	res.UserID = u.ID
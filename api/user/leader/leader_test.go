@@ -20,6 +20,7 @@ func TestCreate(t *testing.T) {
 	p := stub.UserParam(u.Email, u.Firstname, u.Lastname)
 
 	expect.LeaderInsert(u, mock)
+	expect.AuditInsert(models.AuditLeaderCreate, mock)
 
 	rr, r := stub.PostHttp(p)
 
@@ -2,25 +2,23 @@ package member
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
-	"os"
 
 	"gitlab.com/arnaud-web/neli-webservices/api"
+	"gitlab.com/arnaud-web/neli-webservices/api/audit"
 	"gitlab.com/arnaud-web/neli-webservices/api/messages"
 	"gitlab.com/arnaud-web/neli-webservices/api/user"
 	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
 )
 
-var logger = log.New(os.Stdout, "", log.LstdFlags|log.Lshortfile)
-
 // Create create an user and attach it to leader tribe
 // Email has to be valid, firstname and lastname not empty.
 func Create(w http.ResponseWriter, r *http.Request) {
 	m := models.Member{User: &models.User{}}
 
 	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
 		return
 	}
@@ -42,7 +40,7 @@ func Create(w http.ResponseWriter, r *http.Request) {
 	m.Role = models.MemberRole
 
 	if err := m.New(); err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -50,11 +48,13 @@ func Create(w http.ResponseWriter, r *http.Request) {
 	t := models.Tribe{LeaderID: lid, UserID: m.ID}
 
 	if _, err := t.New(); err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	audit.Record(r, models.AuditMemberCreate, models.UserTarget, m.ID, nil, m.User.ToJSON())
+
 	u := models.User{ID: m.ID, Role: m.Role}
 
 	res := user.Resource{UserInfo: &user.UserInfo{}}
@@ -20,6 +20,7 @@ func TestCreate(t *testing.T) {
 
 	expect.MemberInsert(u, mock)
 	expect.TribeInsert(u, mock)
+	expect.AuditInsert(models.AuditMemberCreate, mock)
 
 	p := stub.UserParam(u.Email, u.Firstname, u.Lastname)
 
@@ -39,6 +40,7 @@ func TestCreate_LastnameMissing(t *testing.T) {
 
 	expect.MemberInsert(u, mock)
 	expect.TribeInsert(u, mock)
+	expect.AuditInsert(models.AuditMemberCreate, mock)
 
 	p := stub.UserParam(u.Email, u.Firstname, "")
 
@@ -58,6 +60,7 @@ func TestCreate_FirstnameMissing(t *testing.T) {
 
 	expect.MemberInsert(u, mock)
 	expect.TribeInsert(u, mock)
+	expect.AuditInsert(models.AuditMemberCreate, mock)
 
 	p := stub.UserParam(u.Email, "", u.Lastname)
 
@@ -2,22 +2,20 @@ package user
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
-	"os"
 	"strconv"
 
 	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
 	"golang.org/x/crypto/bcrypt"
 
 	"gitlab.com/arnaud-web/neli-webservices/api"
+	"gitlab.com/arnaud-web/neli-webservices/api/audit"
 	"gitlab.com/arnaud-web/neli-webservices/db/models"
 
 	"github.com/go-chi/chi"
 )
 
-var logger = log.New(os.Stdout, "", log.LstdFlags|log.Lshortfile)
-
 // Resource to be stick with specification
 type Resource struct {
 	*UserInfo `json:"resource,omitempty"`
@@ -56,7 +54,7 @@ func Edit(w http.ResponseWriter, r *http.Request) {
 	u := models.User{ID: uid}
 
 	if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
 		return
 	}
@@ -87,10 +85,17 @@ func Edit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Keep previous informations for audit log
+	before := models.User{}
+
+	if err := before.Find(uid); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+	}
+
 	sqlr, err := j.Save()
 
 	if err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusNotFound, err.Error())
 		return
 	}
@@ -100,6 +105,15 @@ func Edit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Record the saved state, the payload may only hold some fields
+	after := models.User{}
+
+	if err := after.Find(uid); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+	}
+
+	audit.Record(r, models.AuditUserEdit, models.UserTarget, uid, before.ToJSON(), after.ToJSON())
+
 	api.Send(w, http.StatusNoContent, nil)
 }
 
@@ -123,11 +137,20 @@ func Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Keep informations for audit log
+	before := models.User{}
+
+	if err := before.Find(uid); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+	}
+
 	if err := j.Delete(); err != nil {
 		api.SendError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	audit.Record(r, models.AuditUserDelete, models.UserTarget, uid, before.ToJSON(), nil)
+
 	api.Send(w, http.StatusNoContent, nil)
 }
 
@@ -139,7 +162,7 @@ func Password(w http.ResponseWriter, r *http.Request) {
 	c := changePasswordInfo{}
 
 	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusBadRequest, messages.InvalidParameters)
 		return
 	}
@@ -167,13 +190,13 @@ func Password(w http.ResponseWriter, r *http.Request) {
 	h, err := bcrypt.GenerateFromPassword([]byte(c.NewPassword), bcrypt.DefaultCost)
 
 	if err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.SendError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	if err := u.UpdatePassword(string(h)); err != nil {
-		logger.Println(err)
+		logging.FromContext(r.Context()).Error(err)
 		api.Send(w, http.StatusBadRequest, "")
 		return
 	}
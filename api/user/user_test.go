@@ -44,11 +44,15 @@ func TestEdit_Admin(t *testing.T) {
 	r = stub.AddUrlParam(r, "userId", fmt.Sprintf("%d", u.ID))
 
 	expect.AdminOwner(uid, models.AdminRole, mock)
+	expect.FindByIdInParameter(u.ID, mock)
 	expect.UserUpdate(1, mock)
+	expect.FindByIdInParameter(u.ID, mock)
+	expect.AuditInsert(models.AuditUserEdit, mock)
 
 	Edit(rr, r)
 
 	assert.Response(t, rr, http.StatusNoContent, "")
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestEdit_Member(t *testing.T) {
@@ -64,11 +68,15 @@ func TestEdit_Member(t *testing.T) {
 	r = stub.AddUrlParam(r, "userId", fmt.Sprintf("%d", u.ID))
 
 	expect.LeaderOwner(u.ID, uid, mock)
+	expect.FindByIdInParameter(u.ID, mock)
 	expect.UserUpdate(1, mock)
+	expect.FindByIdInParameter(u.ID, mock)
+	expect.AuditInsert(models.AuditUserEdit, mock)
 
 	Edit(rr, r)
 
 	assert.Response(t, rr, http.StatusNoContent, "")
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestEdit_LastnameMissing(t *testing.T) {
@@ -84,11 +92,15 @@ func TestEdit_LastnameMissing(t *testing.T) {
 	r = stub.AddUrlParam(r, "userId", fmt.Sprintf("%d", u.ID))
 
 	expect.LeaderOwner(u.ID, uid, mock)
+	expect.FindByIdInParameter(u.ID, mock)
 	expect.UserUpdate(1, mock)
+	expect.FindByIdInParameter(u.ID, mock)
+	expect.AuditInsert(models.AuditUserEdit, mock)
 
 	Edit(rr, r)
 
 	assert.Response(t, rr, http.StatusNoContent, "")
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestEdit_FirstnameMissing(t *testing.T) {
@@ -104,11 +116,15 @@ func TestEdit_FirstnameMissing(t *testing.T) {
 	r = stub.AddUrlParam(r, "userId", fmt.Sprintf("%d", u.ID))
 
 	expect.LeaderOwner(u.ID, uid, mock)
+	expect.FindByIdInParameter(u.ID, mock)
 	expect.UserUpdate(1, mock)
+	expect.FindByIdInParameter(u.ID, mock)
+	expect.AuditInsert(models.AuditUserEdit, mock)
 
 	Edit(rr, r)
 
 	assert.Response(t, rr, http.StatusNoContent, "")
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestEdit_EmailMissing(t *testing.T) {
@@ -226,6 +242,7 @@ func TestEdit_MemberNotModified(t *testing.T) {
 	r = stub.AddUrlParam(r, "userId", fmt.Sprintf("%d", u.ID))
 
 	expect.LeaderOwner(u.ID, uid, mock)
+	expect.FindByIdInParameter(u.ID, mock)
 	expect.UserUpdate(0, mock)
 
 	Edit(rr, r)
@@ -244,12 +261,14 @@ func TestDelete_Admin(t *testing.T) {
 	r = stub.AddUrlParam(r, "userId", fmt.Sprintf("%d", u.ID))
 
 	expect.AdminOwner(uid, models.AdminRole, mock)
-	expect.ContentUpdate(mock)
-	expect.UserDelete(u, mock)
+	expect.FindByIdInParameter(u.ID, mock)
+	expect.LeaderDelete(u, mock, int64(rand.Uint64()))
+	expect.AuditInsert(models.AuditUserDelete, mock)
 
 	Delete(rr, r)
 
 	assert.Response(t, rr, http.StatusNoContent, "")
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestDelete_Member(t *testing.T) {
@@ -263,7 +282,9 @@ func TestDelete_Member(t *testing.T) {
 	r = stub.AddUrlParam(r, "userId", fmt.Sprintf("%d", u.ID))
 
 	expect.LeaderOwner(u.ID, uid, mock)
+	expect.FindByIdInParameter(u.ID, mock)
 	expect.UserDelete(u, mock)
+	expect.AuditInsert(models.AuditUserDelete, mock)
 
 	Delete(rr, r)
 
@@ -0,0 +1,78 @@
+package background
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gitlab.com/arnaud-web/neli-webservices/logging"
+)
+
+// Interval between two checks of pending work while waiting
+const interval = 50 * time.Millisecond
+
+var (
+	mu      sync.Mutex
+	pending = map[string]int{}
+)
+
+// Go runs f in a go routine tracked until the server exits.
+// Name identifies the kind of work in logs when it's abandoned on shutdown.
+// ctx is only used for logging, the work is never cancelled by it
+// because the request is usually done before the work.
+func Go(ctx context.Context, name string, f func()) {
+	track(name, 1)
+
+	go func() {
+		defer track(name, -1)
+
+		defer func() {
+			if err := recover(); err != nil {
+				logging.FromContext(ctx).Errorf("background %s panicked: %v", name, err)
+			}
+		}()
+
+		f()
+	}()
+}
+
+// Wait blocks until all background work is done or ctx expires.
+// The pending work is logged then ctx error is returned in the latter case.
+func Wait(ctx context.Context) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		mu.Lock()
+		n := len(pending)
+		mu.Unlock()
+
+		if n == 0 {
+			return nil
+		}
+
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			mu.Lock()
+			defer mu.Unlock()
+
+			for n, c := range pending {
+				logging.FromContext(ctx).Errorf("background %s abandoned: %d pending", n, c)
+			}
+
+			return ctx.Err()
+		}
+	}
+}
+
+func track(name string, d int) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	pending[name] += d
+
+	if pending[name] == 0 {
+		delete(pending, name)
+	}
+}
@@ -0,0 +1,42 @@
+package background
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gitlab.com/arnaud-web/neli-webservices/test/assert"
+)
+
+func TestWait(t *testing.T) {
+	done := false
+
+	Go(context.Background(), "test", func() {
+		time.Sleep(10 * time.Millisecond)
+		done = true
+	})
+
+	assert.NoError(t, Wait(context.Background()))
+
+	if !done {
+		t.Fatal("Expected background work to be done")
+	}
+}
+
+func TestWait_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	Go(context.Background(), "test", func() { <-release })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.Error(t, Wait(ctx))
+}
+
+func TestGo_Panic(t *testing.T) {
+	Go(context.Background(), "test", func() { panic("mail") })
+
+	assert.NoError(t, Wait(context.Background()))
+}
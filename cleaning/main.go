@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"gitlab.com/arnaud-web/neli-webservices/api/content"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
+)
+
+// The cleaning removes the expired uploads with their partial files,
+// then removes for good the contents deleted until the date
+// of the cleanings requested by the super admin, from the storage
+// and from database. Run it periodically:
+//
+//	cleaning --config ./docker.ini
+func main() {
+	logger := logging.Default()
+
+	if err := content.Clean(logging.NewContext(context.Background(), logger)); err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+}
@@ -8,22 +8,54 @@ import (
 
 var (
 	AssetsPath 	  = flag.String("assets", "./assets", "Assets path for images")
+	AssetSecret   = flag.String("asset_secret", "", "Secret used for signing asset urls, required")
+	AssetURLLife  = flag.Int("asset_url_life", 900, "Seconds during which a signed asset url is valid")
+	DeviceOnline  = flag.Int("device_online", 60, "Seconds after the last answer of a device before it is shown offline")
 	Env           = flag.String("environment", "local", "Environment")
 	DB            = flag.String("db", "", "Database connection url")
+	HealthProxy   = flag.Bool("health_proxy", false, "Check the bluetooth proxy in readiness probe")
+	HealthSMTP    = flag.Bool("health_smtp", false, "Check the SMTP host in readiness probe")
 	LoginSize     = flag.Int("login_size", 6, "Login size")
+	LogLevel      = flag.String("log_level", "info", "Minimum log level: debug, info, warn or error")
 	Port          = flag.Int("port", 8082, "Port for running application")
+	ReadyTimeout  = flag.Int("ready_timeout", 30, "Minutes after a capture stop before a content not ready is flagged as stalled")
 	RefreshSecret = flag.String("refresh_secret", "§1puR?*1f", "Secret used for refresh jwt")
+	TrustedProxies = flag.String("trusted_proxies", "", "Comma separated ips or cidrs of the reverse proxies allowed to set the client ip with X-Forwarded-For or X-Real-IP")
 	TokenSecret   = flag.String("token_secret", "}Sn+#u||j?9n", "Secret used for access jwt")
 	TokenLife     = flag.Int64("token_life", 3600, "Token life jwt")
 	TokenSize     = flag.Int("token_size", 30, "Token size jwt")
+	MetricsToken  = flag.String("metrics_token", "", "Bearer token of the scrapers allowed on /metrics, empty to disable metrics")
 	PasswordSize  = flag.Int("password_size", 6, "Password size")
+	IdleTimeout   = flag.Int("idle_timeout", 120, "Max duration in seconds of an idle keep-alive connection")
+	ReadTimeout   = flag.Int("read_timeout", 15, "Max duration in seconds for reading request headers, and with write timeout for a request not long-lived")
+	ShutdownTimeout = flag.Int("shutdown_timeout", 30, "Max duration in seconds for draining requests and background work on shutdown")
+	WriteTimeout  = flag.Int("write_timeout", 30, "Max duration in seconds for writing a response, and with read timeout for a request not long-lived")
+	PosterMaxSize = flag.Int64("poster_max_size", 5, "Max size in megabytes of an uploaded poster")
 	ProxyBluetooth = flag.String("proxy", "192.168.1.17:2000", "Proxy bluetooth")
+	ProxyRetries  = flag.Int("proxy_retries", 0, "Number of retries of a status order when the proxy doesn't answer")
+	ProxyTimeout  = flag.Int("proxy_timeout", 1000, "Timeout in milliseconds of an order sent to the proxy")
+	ProxyTransport = flag.String("proxy_transport", "udp", "Transport used by the proxy: udp, tcp or http")
+	S3AccessKey   = flag.String("s3_access_key", "", "Access key of the S3 storage")
+	S3Bucket      = flag.String("s3_bucket", "neli", "Bucket of the S3 storage")
+	S3Endpoint    = flag.String("s3_endpoint", "http://localhost:9000", "Endpoint of the S3 storage, like a MinIO server")
+	S3Region      = flag.String("s3_region", "us-east-1", "Region of the S3 storage")
+	S3SecretKey   = flag.String("s3_secret_key", "", "Secret key of the S3 storage")
+	S3Timeout     = flag.Int("s3_timeout", 30, "Max duration in seconds of a request to the S3 storage, uploads get one more second per MiB")
+	SimulatorAddr = flag.String("simulator_addr", ":2000", "UDP address listened by the proxy simulator")
+	SimulatorControl = flag.String("simulator_control", ":2001", "HTTP address of the proxy simulator control, empty to disable")
+	SimulatorFault = flag.String("simulator_fault", "", "Fault mode of the proxy simulator: nobt, nosource, noserver, authfailure, timeout or garbage")
 	SMTPFrom      = flag.String("email_from", "admin@neli.com", "Sender address for neli email")
 	SMTPLogin     = flag.String("email_login", "", "SMTP login for email authentication")
 	SMTPPassword  = flag.String("email_password", "", "SMTP password for email authentication")
 	SMTPHost      = flag.String("email_host", "localhost", "SMTP host for email authentication")
 	SMTPPort      = flag.Int("email_port", 1025, "SMTP port for email authentication")
+	StreamInterval = flag.Int("stream_interval", 1, "Seconds between two capture status polls of a status stream")
+	StreamLifetime = flag.Int("stream_lifetime", 3600, "Seconds after which a status stream is closed for browsers to reconnect it, 0 to keep it open")
+	Storage       = flag.String("storage", "local", "Storage of the assets: local in the assets path or s3")
 	Stub          = flag.Int("stub", 0, "Stub in case of hub unavailable. Automatically assign a string to a content.")
+	UploadDir     = flag.String("upload_dir", "./uploads", "Directory of the videos being uploaded")
+	UploadMaxSize = flag.Int64("upload_max_size", 2048, "Max size in megabytes of an uploaded video")
+	UploadLife    = flag.Int("upload_life", 86400, "Seconds during which an unfinished upload can be resumed")
 	URL           = flag.String("url", "http://neli", "Base url for forms")
 	URLContent    = flag.String("url_content", "http://neli", "Base url for content (video and image)")
 	ZombieID      = flag.Int64("zombie", 99, "Zombie id")
@@ -1,5 +1,11 @@
 package models
 
+import (
+	"database/sql"
+
+	"gitlab.com/arnaud-web/neli-webservices/config"
+)
+
 // Admin represents an admin user
 type Admin struct {
 	*User
@@ -13,10 +19,13 @@ func (a Admin) ToJSON() interface{} {
 	return a
 }
 
-// Content returns all content created.
+// Content returns all content created having one of the statuses,
+// all but deleted ones when no status is given, and all the tags.
 // Content needs to have a name and description.
-func (a Admin) Content() ([]Content, error) {
+func (a Admin) Content(statuses []string, tags []string) ([]Content, error) {
 	var c []Content
+	cond, args := inStatus(statuses)
+	tcond, targs := withTags(tags)
 	err := db.Select(&c, `
 		SELECT 
 			id, 
@@ -26,12 +35,84 @@ func (a Admin) Content() ([]Content, error) {
 			IFNULL (duration, 0) AS duration,
 			leader_id,
 			(SELECT COUNT(*) > 0 FROM share WHERE video_content.id = content_id) as sharing_status,
-			IFNULL (duration, 0) != 0 AS ready
-		FROM video_content`)
+			status,
+			IFNULL(failure_reason, '') AS failure_reason,
+			IFNULL(path, '') AS path,
+			IFNULL(poster, '') AS poster,`+renditionsColumn+`,`+tagsColumn+`,
+			status = 'ready' AS ready,`+stalled+`
+		FROM video_content
+		WHERE 1 = 1`+cond+tcond, append(append([]interface{}{*config.ReadyTimeout}, args...), targs...)...)
+
+	thumbnails(c)
+	sources(c)
+	return c, err
+}
+
+// restoredStatus is the status a deleted content gets back once restored,
+// ready when its video was processed, created otherwise
+const restoredStatus = `IF(IFNULL(video_content.path, '') != '', ?, ?)`
+
+// Trash returns all content attached to zombie and waiting for cleaning.
+// Each content is annotated with the leader who owned it before deletion
+// and is ready when it would be restored ready.
+func (a Admin) Trash() ([]Content, error) {
+	c := []Content{}
+	err := db.Select(&c, `
+		SELECT
+			video_content.id,
+			IFNULL (name, '') AS name,
+			IFNULL (description, '') AS description,
+			DATE_FORMAT(video_content.created_at,'%Y-%m-%d') as creation_date,
+			IFNULL (duration, 0) AS duration,
+			IFNULL (previous_leader_id, 0) AS previous_leader_id,
+			IFNULL (CONCAT_WS(' ', user.firstname, user.lastname), '') AS previous_leader_name,
+			IFNULL (poster, '') AS poster,
+			status,
+			`+restoredStatus+` = ? AS ready
+		FROM video_content
+		LEFT JOIN user ON user.id = video_content.previous_leader_id
+		WHERE leader_id = ?
+		ORDER BY video_content.created_at DESC`, ContentReady, ContentCreated, ContentReady, *config.ZombieID)
 
+	thumbnails(c)
 	return c, err
 }
 
+// Restore attaches a content from zombie back to its previous leader.
+// The content is ready again when it was, created otherwise.
+// It returns sql.ErrNoRows if the content is not in the trash
+// or if the previous leader does not exist anymore.
+func (a Admin) Restore(cid int64) error {
+	r, err := db.Exec(`
+		UPDATE video_content
+		JOIN user ON user.id = video_content.previous_leader_id AND user.role = ?
+		SET video_content.leader_id = video_content.previous_leader_id, video_content.previous_leader_id = NULL,
+			video_content.status = `+restoredStatus+`
+		WHERE video_content.id = ? AND video_content.leader_id = ?`,
+		LeaderRole, ContentReady, ContentCreated, cid, *config.ZombieID)
+
+	if err != nil {
+		return err
+	}
+
+	if n, _ := r.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// MarkTrashDeleted marks as deleted the contents attached to zombie
+// before statuses existed, whose status was inferred from their path
+func MarkTrashDeleted() error {
+	_, err := db.Exec(`
+		UPDATE video_content
+		SET status = ?
+		WHERE leader_id = ? AND status != ?`,
+		ContentDeleted, *config.ZombieID, ContentDeleted)
+	return err
+}
+
 // IsOwned checks if user id passed in parameter is super admin
 func (a Admin) IsOwned(uid int64) bool {
 	return a.ID == uid || db.Get(&User{}, `
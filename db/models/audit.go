@@ -0,0 +1,147 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx/types"
+)
+
+const (
+	// AuditAdminCreate is recorded when a super admin creates an admin
+	AuditAdminCreate = "admin.create"
+	// AuditLeaderCreate is recorded when an admin creates a leader
+	AuditLeaderCreate = "leader.create"
+	// AuditMemberCreate is recorded when a leader creates a member
+	AuditMemberCreate = "member.create"
+	// AuditUserEdit is recorded when an user is edited by his owner
+	AuditUserEdit = "user.edit"
+	// AuditUserDelete is recorded when an user is deleted by his owner
+	AuditUserDelete = "user.delete"
+	// AuditMaxDuration is recorded when the max duration setting changes
+	AuditMaxDuration = "settings.max_duration"
+	// AuditCleaning is recorded when a cleaning is triggered
+	AuditCleaning = "content.cleaning"
+	// AuditContentShare is recorded when a content is shared with a member
+	AuditContentShare = "content.share"
+	// AuditContentDelete is recorded when a content is attached to zombie
+	AuditContentDelete = "content.delete"
+	// AuditContentRestore is recorded when a content is restored from zombie
+	AuditContentRestore = "content.restore"
+	// AuditContentReprocess is recorded when a leader asks to process a failed content again
+	AuditContentReprocess = "content.reprocess"
+	// AuditDeviceCreate is recorded when an admin creates a device
+	AuditDeviceCreate = "device.create"
+	// AuditDeviceEdit is recorded when an admin edits a device or its leaders
+	AuditDeviceEdit = "device.edit"
+	// AuditDeviceDelete is recorded when an admin deletes a device
+	AuditDeviceDelete = "device.delete"
+	// AuditPlaylistShare is recorded when a playlist is shared with a member
+	AuditPlaylistShare = "playlist.share"
+	// AuditQuotaDefault is recorded when the default quota setting changes
+	AuditQuotaDefault = "settings.quota"
+	// AuditQuotaEdit is recorded when the quota override of an admin or a leader changes
+	AuditQuotaEdit = "quota.edit"
+)
+
+// Targets of audited actions, named after their table
+const (
+	UserTarget     = "user"
+	ContentTarget  = "video_content"
+	SettingsTarget = "settings"
+	CleaningTarget = "cleaning"
+	DeviceTarget   = "device"
+	PlaylistTarget = "playlist"
+	QuotaTarget    = "quota"
+)
+
+// Audit is an entry of the audit log.
+// Diff contains a json object with before and after values of each changed field.
+type Audit struct {
+	ID         int64          `db:"id" json:"id"`
+	ActorID    int64          `db:"actor_id" json:"actorId"`
+	Role       string         `db:"role" json:"role"`
+	Action     string         `db:"action" json:"action"`
+	TargetType string         `db:"target_type" json:"targetType"`
+	TargetID   int64          `db:"target_id" json:"targetId,omitempty"`
+	Diff       types.JSONText `db:"diff" json:"diff"`
+	IP         string         `db:"ip" json:"ip,omitempty"`
+	CreatedAt  time.Time      `db:"created_at" json:"createdAt"`
+}
+
+// AuditFilter restricts the entries returned by ListAudit.
+// Zero values are ignored.
+type AuditFilter struct {
+	ActorID    int64
+	Action     string
+	TargetType string
+	TargetID   int64
+	From       time.Time
+	To         time.Time
+	Limit      int
+	Offset     int
+}
+
+// New records an entry in audit log
+func (a *Audit) New() error {
+	r, err := db.Exec(`
+		INSERT INTO audit_log (actor_id, role, action, target_type, target_id, diff, ip, created_at)
+		VALUES (?, ?, ?, ?, NULLIF(?, 0), ?, ?, NOW())`,
+		a.ActorID, a.Role, a.Action, a.TargetType, a.TargetID, a.Diff, a.IP)
+
+	if err != nil {
+		return err
+	}
+
+	a.ID, err = r.LastInsertId()
+	return err
+}
+
+// ListAudit returns audit log entries matching the filter, most recent first
+func ListAudit(f AuditFilter) ([]Audit, error) {
+	l := []Audit{}
+	q := `
+		SELECT id, actor_id, role, action, target_type,
+			IFNULL(target_id, 0) AS target_id,
+			IFNULL(diff, '{}') AS diff,
+			IFNULL(ip, '') AS ip,
+			created_at
+		FROM audit_log
+		WHERE 1 = 1`
+	args := []interface{}{}
+
+	if f.ActorID > 0 {
+		q += " AND actor_id = ?"
+		args = append(args, f.ActorID)
+	}
+
+	if f.Action != "" {
+		q += " AND action = ?"
+		args = append(args, f.Action)
+	}
+
+	if f.TargetType != "" {
+		q += " AND target_type = ?"
+		args = append(args, f.TargetType)
+	}
+
+	if f.TargetID > 0 {
+		q += " AND target_id = ?"
+		args = append(args, f.TargetID)
+	}
+
+	if !f.From.IsZero() {
+		q += " AND created_at >= ?"
+		args = append(args, f.From)
+	}
+
+	if !f.To.IsZero() {
+		q += " AND created_at <= ?"
+		args = append(args, f.To)
+	}
+
+	q += " ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?"
+	args = append(args, f.Limit, f.Offset)
+
+	err := db.Select(&l, q, args...)
+	return l, err
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"time"
+)
+
+// CaptureSession records a capture ordered by a leader.
+// DeviceID is the device which received the order, 0 for the configured proxy.
+// StoppedAt and Duration are set when the leader stops the capture.
+type CaptureSession struct {
+	ID          int64     `db:"id" json:"id"`
+	ContentID   int64     `db:"video_content_id" json:"videoContentId"`
+	LeaderID    int64     `db:"leader_id" json:"leaderId"`
+	DeviceID    int64     `db:"device_id" json:"deviceId,omitempty"`
+	MaxDuration int64     `db:"max_duration" json:"maxDuration"`
+	StartedAt   time.Time `db:"started_at" json:"startedAt"`
+	Duration    int64     `db:"duration" json:"duration,omitempty"`
+	ElapsedTime int64     `db:"elapsed" json:"-"`
+}
+
+// New records a started capture.
+// Sessions of the leader never stopped are closed first,
+// they ended by themselves when their max duration was reached.
+func (s *CaptureSession) New() error {
+	_, err := db.Exec(`
+		UPDATE capture_session
+		SET stopped_at = LEAST(NOW(), started_at + INTERVAL max_duration SECOND)
+		WHERE leader_id = ? AND stopped_at IS NULL`,
+		s.LeaderID)
+
+	if err != nil {
+		return err
+	}
+
+	r, err := db.Exec(`
+		INSERT INTO capture_session (video_content_id, leader_id, device_id, max_duration, started_at)
+		VALUES (?, ?, NULLIF(?, 0), ?, NOW())`,
+		s.ContentID, s.LeaderID, s.DeviceID, s.MaxDuration)
+
+	if err != nil {
+		return err
+	}
+
+	s.ID, err = r.LastInsertId()
+	return err
+}
+
+// StopCapture records the duration returned by the device
+// on the running session of the leader
+func StopCapture(lid, duration int64) error {
+	_, err := db.Exec(`
+		UPDATE capture_session
+		SET stopped_at = NOW(), duration = ?
+		WHERE leader_id = ? AND stopped_at IS NULL`,
+		duration, lid)
+
+	return err
+}
+
+// Running finds the session of the leader neither stopped nor expired.
+// Its elapsed time is computed by the database which wrote its start.
+func (s *CaptureSession) Running(lid int64) error {
+	return db.Get(s, `
+		SELECT
+			id, video_content_id, leader_id, IFNULL(device_id, 0) AS device_id,
+			max_duration, started_at, IFNULL(duration, 0) AS duration,
+			TIMESTAMPDIFF(SECOND, started_at, NOW()) AS elapsed
+		FROM capture_session
+		WHERE leader_id = ? AND stopped_at IS NULL
+			AND (max_duration = 0 OR started_at + INTERVAL max_duration SECOND > NOW())
+		ORDER BY started_at DESC
+		LIMIT 1`,
+		lid)
+}
+
+// Elapsed returns the duration in seconds since the capture started,
+// as read by Running
+func (s CaptureSession) Elapsed() int64 {
+	return s.ElapsedTime
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"time"
+
+	"gitlab.com/arnaud-web/neli-webservices/config"
+)
+
+// Cleaning is a request of the super admin to remove for good
+// the deleted contents created until its date
+type Cleaning struct {
+	ID   int64     `db:"id"`
+	Date time.Time `db:"date"`
+}
+
+// PendingCleanings returns the cleanings not done yet, oldest first
+func PendingCleanings() ([]Cleaning, error) {
+	l := []Cleaning{}
+	err := db.Select(&l, `
+		SELECT id, date
+		FROM cleaning
+		WHERE done = 0
+		ORDER BY date`)
+	return l, err
+}
+
+// Contents returns the contents attached to zombie
+// created until the end of the day of the cleaning
+func (c Cleaning) Contents() ([]Content, error) {
+	l := []Content{}
+	err := db.Select(&l, `
+		SELECT id, IFNULL(path, '') AS path
+		FROM video_content
+		WHERE leader_id = ? AND created_at < DATE(?) + INTERVAL 1 DAY`,
+		*config.ZombieID, c.Date)
+	return l, err
+}
+
+// Done marks the cleaning as done
+func (c Cleaning) Done() error {
+	_, err := db.Exec(`
+		UPDATE cleaning
+		SET done = 1
+		WHERE id = ?`,
+		c.ID)
+	return err
+}
+
+// Delete removes the content with its uploads, renditions, markers, comments,
+// tags, playlist entries, capture sessions and shares from database
+// in one transaction. Its assets have to be removed from the storage first.
+func (c Content) Delete() error {
+	tx, err := db.Beginx()
+
+	if err != nil {
+		return err
+	}
+
+	// Rollback does nothing once the transaction is committed
+	defer tx.Rollback()
+
+	for _, t := range []string{"upload", "rendition", "marker", "comment", "video_content_tag", "playlist_content", "capture_session"} {
+		if _, err := tx.Exec(`
+			DELETE FROM `+t+`
+			WHERE video_content_id = ?`,
+			c.ID); err != nil {
+			return err
+		}
+	}
+
+	// Shares have no foreign key on the content
+	if _, err := tx.Exec(`
+		DELETE FROM share
+		WHERE content_id = ?`,
+		c.ID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		DELETE FROM video_content
+		WHERE id = ?`,
+		c.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
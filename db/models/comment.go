@@ -0,0 +1,66 @@
+package models
+
+import "time"
+
+// Comment is posted on a content at a playback offset in seconds,
+// by its leader or a member it is shared with
+type Comment struct {
+	ID        int64     `db:"id" json:"id"`
+	ContentID int64     `db:"video_content_id" json:"videoContentId"`
+	UserID    int64     `db:"user_id" json:"userId"`
+	Author    string    `db:"author" json:"author"`
+	Offset    int       `db:"time_offset" json:"offset"`
+	Body      string    `db:"body" json:"body"`
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+}
+
+// ListComments returns the comments of the content with their author,
+// ordered by offset then posting
+func ListComments(cid int64) ([]Comment, error) {
+	l := []Comment{}
+	err := db.Select(&l, `
+		SELECT
+			comment.id, video_content_id, user_id,
+			IFNULL(CONCAT_WS(' ', user.firstname, user.lastname), '') AS author,
+			time_offset, body, comment.created_at
+		FROM comment
+		JOIN user ON user.id = comment.user_id
+		WHERE video_content_id = ?
+		ORDER BY time_offset, comment.id`,
+		cid)
+	return l, err
+}
+
+// New records the comment
+func (c *Comment) New() error {
+	r, err := db.Exec(`
+		INSERT INTO comment (video_content_id, user_id, time_offset, body, created_at)
+		VALUES (?, ?, ?, ?, NOW())`,
+		c.ContentID, c.UserID, c.Offset, c.Body)
+
+	if err != nil {
+		return err
+	}
+
+	c.ID, err = r.LastInsertId()
+	c.CreatedAt = time.Now()
+	return err
+}
+
+// Find loads the comment of the content
+func (c *Comment) Find() error {
+	return db.Get(c, `
+		SELECT id, video_content_id, user_id, time_offset, body, created_at
+		FROM comment
+		WHERE id = ? AND video_content_id = ?`,
+		c.ID, c.ContentID)
+}
+
+// Delete removes the comment
+func (c Comment) Delete() error {
+	_, err := db.Exec(`
+		DELETE FROM comment
+		WHERE id = ?`,
+		c.ID)
+	return err
+}
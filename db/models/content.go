@@ -1,25 +1,192 @@
 package models
 
 import (
+	"database/sql"
+	"errors"
+	"strings"
 	"time"
 
+	"github.com/jmoiron/sqlx"
 	"gitlab.com/arnaud-web/neli-webservices/config"
 )
 
 // Content represents a video content
 type Content struct {
-	ID            int64     `db:"id" json:"videoContentId"`
-	Name          string    `db:"name" json:"name"`
-	Description   string    `db:"description" json:"description"`
-	Duration      int       `db:"duration" json:"duration"`
-	LeaderID      int64     `db:"leader_id" json:"leaderId,omitempty"`
-	MaxDuration   int       `json:"maxDuration,omitempty"`
-	Path          string    `db:"path" json:"-"`
-	Ready         bool      `db:"ready" json:"ready"`
-	SharingStatus bool      `db:"sharing_status" json:"sharingStatus,omitempty"`
-	CreationDate  string    `db:"creation_date" json:"creationDate,omitempty"`
-	CreatedAt     time.Time `db:"created_at" json:"-"`
-	UpdatedAt     time.Time `db:"updated_at" json:"-"`
+	ID            int64      `db:"id" json:"videoContentId"`
+	Name          string     `db:"name" json:"name"`
+	Description   string     `db:"description" json:"description"`
+	Duration      int        `db:"duration" json:"duration"`
+	LeaderID      int64      `db:"leader_id" json:"leaderId,omitempty"`
+	PreviousID    int64      `db:"previous_leader_id" json:"previousLeaderId,omitempty"`
+	PreviousName  string     `db:"previous_leader_name" json:"previousLeaderName,omitempty"`
+	MaxDuration   int        `json:"maxDuration,omitempty"`
+	Path          string     `db:"path" json:"-"`
+	Poster        string     `db:"poster" json:"-"`
+	Ready         bool       `db:"ready" json:"ready"`
+	Status        string     `db:"status" json:"status"`
+	FailureReason string     `db:"failure_reason" json:"failureReason,omitempty"`
+	ThumbnailURL  string     `db:"-" json:"thumbnailUrl"`
+	Renditions    Renditions `db:"renditions" json:"renditions,omitempty"`
+	Tags          Tags       `db:"tags" json:"tags,omitempty"`
+	Stalled       bool       `db:"stalled" json:"stalled,omitempty"`
+	SharingStatus bool       `db:"sharing_status" json:"sharingStatus,omitempty"`
+	CreationDate  string     `db:"creation_date" json:"creationDate,omitempty"`
+	CreatedAt     time.Time  `db:"created_at" json:"-"`
+	UpdatedAt     time.Time  `db:"updated_at" json:"-"`
+}
+
+// stalled is the column flagging a recording or processing content
+// left unchanged, or whose reprocess was requested, for ready_timeout minutes
+// without the hub calling ready. A captured content is left once its capture
+// stopped or ended by itself at its max duration, never while it runs.
+const stalled = `
+	status IN ('recording', 'processing') AND GREATEST(
+		video_content.update_at,
+		IFNULL(video_content.reprocess_at, video_content.update_at),
+		IFNULL((
+			SELECT MAX(COALESCE(stopped_at, IF(max_duration > 0, started_at + INTERVAL max_duration SECOND, NOW())))
+			FROM capture_session
+			WHERE video_content_id = video_content.id
+		), video_content.update_at)
+	) < NOW() - INTERVAL ? MINUTE AS stalled`
+
+// Statuses of a content.
+// A content is created empty, recording while the device captures it
+// and processing from the capture stop until the hub calls ready.
+// It is failed when the capture or the processing fails
+// and deleted while attached to zombie.
+const (
+	ContentCreated    = "created"
+	ContentRecording  = "recording"
+	ContentProcessing = "processing"
+	ContentReady      = "ready"
+	ContentFailed     = "failed"
+	ContentDeleted    = "deleted"
+)
+
+// transitions lists for each status the statuses a content can leave to reach it.
+// A capture reaching its max duration is never stopped so the hub can make
+// a recording content ready, and it can send ready again for a ready content.
+// A content never captured is made ready by the upload of its video.
+// A leader can capture again a content whose capture never started
+// and ask for processing again a failed content.
+var transitions = map[string][]string{
+	ContentRecording:  {ContentCreated, ContentRecording, ContentFailed},
+	ContentProcessing: {ContentRecording, ContentFailed},
+	ContentReady:      {ContentCreated, ContentRecording, ContentProcessing, ContentFailed, ContentReady},
+	ContentFailed:     {ContentRecording, ContentProcessing},
+	ContentDeleted:    {ContentCreated, ContentRecording, ContentProcessing, ContentReady, ContentFailed},
+}
+
+// ErrTransition is returned when a content can't reach a status from its current one
+var ErrTransition = errors.New("invalid content status transition")
+
+// IsStatus checks s is a content status
+func IsStatus(s string) bool {
+	_, ok := transitions[s]
+	return ok || s == ContentCreated
+}
+
+// CanTransition checks the content can reach the status from its current one
+func (c Content) CanTransition(to string) bool {
+	for _, s := range transitions[to] {
+		if s == c.Status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// from returns the condition on the current status allowing to reach to
+func from(to string) (string, []interface{}) {
+	l := transitions[to]
+	args := make([]interface{}, len(l))
+
+	for i, s := range l {
+		args[i] = s
+	}
+
+	return "status IN (?" + strings.Repeat(", ?", len(l)-1) + ")", args
+}
+
+// transited checks the update of the content status matched a row.
+// MySQL doesn't count unchanged rows so the status is checked
+// when no row is affected.
+func transited(e sqlx.Ext, r sql.Result, cid int64, to string) error {
+	if n, err := r.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+
+	var s string
+
+	if err := sqlx.Get(e, &s, `
+		SELECT status
+		FROM video_content
+		WHERE id = ?`,
+		cid); err != nil || s != to {
+		return ErrTransition
+	}
+
+	return nil
+}
+
+// Transition changes the content status.
+// ErrTransition is returned when the current status doesn't allow it.
+func (c *Content) Transition(to string) error {
+	return c.transition(db, to)
+}
+
+// transition changes the content status with the database or the transaction
+func (c *Content) transition(e sqlx.Ext, to string) error {
+	cond, args := from(to)
+	r, err := e.Exec(`
+		UPDATE video_content
+		SET status = ?
+		WHERE id = ? AND `+cond,
+		append([]interface{}{to, c.ID}, args...)...)
+
+	if err != nil {
+		return err
+	}
+
+	if err := transited(e, r, c.ID, to); err != nil {
+		return err
+	}
+
+	c.Status = to
+	return nil
+}
+
+// inStatus returns the condition restricting contents to the statuses.
+// No status means all of them but deleted ones.
+func inStatus(l []string) (string, []interface{}) {
+	if len(l) == 0 {
+		return " AND status != ?", []interface{}{ContentDeleted}
+	}
+
+	args := make([]interface{}, len(l))
+
+	for i, s := range l {
+		args[i] = s
+	}
+
+	return " AND status IN (?" + strings.Repeat(", ?", len(l)-1) + ")", args
+}
+
+// ProcessCapture marks the content of the running capture of the leader
+// as processing. It has to be called before the capture session is stopped.
+func ProcessCapture(lid int64) error {
+	_, err := db.Exec(`
+		UPDATE video_content
+		SET status = ?
+		WHERE id IN (
+			SELECT video_content_id FROM capture_session
+			WHERE leader_id = ? AND stopped_at IS NULL
+		) AND status = ?`,
+		ContentProcessing, lid, ContentRecording)
+
+	return err
 }
 
 // New create a new content in database
@@ -38,9 +205,9 @@ func (c *Content) New() error {
 	if *config.Stub == 1 {
 		_, err = db.Exec(`
 			UPDATE video_content
-			SET path = ?
+			SET path = ?, status = ?
 			WHERE id = ? `,
-			config.StubPath, c.ID)
+			config.StubPath, ContentReady, c.ID)
 
 		if err != nil {
 			return err
@@ -57,7 +224,9 @@ func (c *Content) Find() error {
 			id, IFNULL(name,'') AS name, 
 			IFNULL(description,'') AS description, 
 			IFNULL(path,'') AS path,
-			IFNULL(duration, 0) AS duration 
+			IFNULL(poster, '') AS poster,
+			IFNULL(duration, 0) AS duration,
+			status
 		FROM video_content
 		WHERE id = ? AND leader_id = ?`,
 		c.ID, c.LeaderID)
@@ -74,31 +243,173 @@ func (c *Content) Update() error {
 	return err
 }
 
+// VideoKey returns the storage key of the video stored under path by the hub
+func VideoKey(path string) string {
+	return path + "/" + path + ".mp4"
+}
+
 // IsNotReady return true if the video path has not been updated.
 // This update can be done from hub or by stube mode.
 func (c *Content) IsNotReady() bool {
 	return c.Path == ""
 }
 
-// UpdatePath update only path field for content
-func (c Content) UpdatePath() error {
-	_, err := db.Exec(`
+// MakeReady makes the content ready with the path, duration and renditions
+// sent by the hub and generates the url of its pending shares, in one transaction.
+// The shares to mail are returned, none when the content is already ready
+// with this path so a repeated callback changes nothing.
+// ErrTransition is returned when the content status doesn't allow it.
+func (c *Content) MakeReady() ([]Share, error) {
+	tx, err := db.Beginx()
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Rollback does nothing once the transaction is committed
+	defer tx.Rollback()
+
+	cur := Content{}
+
+	if err := tx.Get(&cur, `
+		SELECT
+			IFNULL(name,'') AS name,
+			IFNULL(description,'') AS description,
+			leader_id,
+			IFNULL(path,'') AS path,
+			status
+		FROM video_content
+		WHERE id = ?
+		FOR UPDATE`,
+		c.ID); err != nil {
+		return nil, err
+	}
+
+	if cur.Status == ContentReady && cur.Path == c.Path {
+		return nil, nil
+	}
+
+	if !cur.CanTransition(ContentReady) {
+		return nil, ErrTransition
+	}
+
+	if _, err := tx.Exec(`
 		UPDATE video_content
-		SET path = ?, duration = ?
-		WHERE id = ? `,
-		c.Path, c.Duration, c.ID)
+		SET path = ?, duration = ?, status = ?, failure_reason = NULL, reprocess_at = NULL
+		WHERE id = ?`,
+		c.Path, c.Duration, ContentReady, c.ID); err != nil {
+		return nil, err
+	}
 
-	return err
-}
+	if err := replaceRenditions(tx, c.ID, c.Renditions); err != nil {
+		return nil, err
+	}
+
+	c.Name = cur.Name
+	c.Description = cur.Description
+	c.LeaderID = cur.LeaderID
+	c.Status = ContentReady
 
-func (c *Content) Shares() ([]Share, error) {
 	var l []Share
-	err := db.Select(&l, `
-			SELECT share.id, user_id, expiration_date, message, CONCAT(firstname, " ", lastname) as name
-			FROM share
-			JOIN user ON user.id = user_id
-			WHERE share.content_id = ? and url = ""`, c.ID)
 
+	if err := tx.Select(&l, `
+		SELECT share.id, user_id, expiration_date, message, CONCAT(firstname, " ", lastname) as name
+		FROM share
+		JOIN user ON user.id = user_id
+		WHERE share.content_id = ? and url = ""
+		FOR UPDATE`,
+		c.ID); err != nil {
+		return nil, err
+	}
+
+	for i := range l {
+		if err := l[i].updateURL(tx, c); err != nil {
+			return nil, err
+		}
+	}
+
+	return l, tx.Commit()
+}
+
+// Fail records the processing failure reported by the hub.
+// ErrTransition is returned when the content is not recording or processing.
+func (c *Content) Fail(reason string) error {
+	cond, args := from(ContentFailed)
+	r, err := db.Exec(`
+		UPDATE video_content
+		SET status = ?, failure_reason = ?, reprocess_at = NULL
+		WHERE id = ? AND `+cond,
+		append([]interface{}{ContentFailed, reason, c.ID}, args...)...)
+
+	if err != nil {
+		return err
+	}
+
+	if err := transited(db, r, c.ID, ContentFailed); err != nil {
+		return err
+	}
+
+	c.Status = ContentFailed
+	c.FailureReason = reason
+	return nil
+}
+
+// Reprocess makes a failed content processing again
+// until the hub pulls it from reprocess requests.
+// ErrTransition is returned when the content is not failed.
+func (c *Content) Reprocess() error {
+	cond, args := from(ContentProcessing)
+	r, err := db.Exec(`
+		UPDATE video_content
+		SET status = ?, failure_reason = NULL, reprocess_at = NOW()
+		WHERE id = ? AND status = ? AND `+cond,
+		append([]interface{}{ContentProcessing, c.ID, ContentFailed}, args...)...)
+
+	if err != nil {
+		return err
+	}
+
+	if n, err := r.RowsAffected(); err != nil || n == 0 {
+		return ErrTransition
+	}
+
+	c.Status = ContentProcessing
+	return nil
+}
+
+// Load finds a content by id whatever its leader
+func (c *Content) Load() error {
+	return db.Get(c, `
+		SELECT 
+			id, IFNULL(name,'') AS name, 
+			IFNULL(description,'') AS description, 
+			IFNULL(path,'') AS path,
+			IFNULL(poster, '') AS poster,
+			IFNULL(duration, 0) AS duration,
+			leader_id,
+			status,
+			IFNULL(failure_reason, '') AS failure_reason
+		FROM video_content
+		WHERE id = ?`,
+		c.ID)
+}
+
+// Reprocess is a request of the leader to process a failed content again
+type Reprocess struct {
+	ContentID   int64     `db:"id" json:"videoContentId"`
+	RequestedAt time.Time `db:"reprocess_at" json:"requestedAt"`
+}
+
+// ListReprocess returns the contents waiting for the hub to process them again,
+// oldest request first
+func ListReprocess() ([]Reprocess, error) {
+	l := []Reprocess{}
+	err := db.Select(&l, `
+		SELECT id, reprocess_at
+		FROM video_content
+		WHERE status = ? AND reprocess_at IS NOT NULL
+		ORDER BY reprocess_at`,
+		ContentProcessing)
 	return l, err
 }
 
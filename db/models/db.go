@@ -4,6 +4,8 @@ import (
 
 	// Used to load mysql driver
 
+	"database/sql"
+	"errors"
 	"log"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -31,3 +33,22 @@ func init() {
 func SetDB(d *sqlx.DB) {
 	db = d
 }
+
+// Stats returns the connection pool statistics.
+// Zero values are returned when no database is configured.
+func Stats() sql.DBStats {
+	if db == nil {
+		return sql.DBStats{}
+	}
+
+	return db.Stats()
+}
+
+// Ping checks that the database is reachable
+func Ping() error {
+	if db == nil {
+		return errors.New("database not configured")
+	}
+
+	return db.Ping()
+}
@@ -0,0 +1,237 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.com/arnaud-web/neli-webservices/api/messages"
+	"gitlab.com/arnaud-web/neli-webservices/config"
+)
+
+// Device is a capture proxy.
+// Orders of the leaders assigned to it are sent to its address.
+// Online is set when the device answered for device_online seconds.
+type Device struct {
+	ID         int64      `db:"id" json:"id"`
+	Name       string     `db:"name" json:"name"`
+	Address    string     `db:"address" json:"address"`
+	Transport  string     `db:"transport" json:"transport"`
+	LastSeenAt *time.Time `db:"last_seen_at" json:"lastSeenAt,omitempty"`
+	Online     bool       `db:"online" json:"online"`
+	Leaders    []int64    `db:"-" json:"leaders"`
+}
+
+const deviceColumns = `
+	id, name, address, transport, last_seen_at,
+	IFNULL(last_seen_at > NOW() - INTERVAL ? SECOND, FALSE) AS online`
+
+// Validate checks name and address are not empty
+func (d Device) Validate() error {
+	if d.Name == "" || d.Address == "" {
+		return errors.New(messages.InvalidDeviceInfo)
+	}
+
+	return nil
+}
+
+// New creates the device then assigns its leaders in one transaction
+func (d *Device) New() error {
+	tx, err := db.Beginx()
+
+	if err != nil {
+		return err
+	}
+
+	// Rollback does nothing once the transaction is committed
+	defer tx.Rollback()
+
+	r, err := tx.Exec(`
+		INSERT INTO device (name, address, transport, created_at)
+		VALUES (?, ?, ?, NOW())`,
+		d.Name, d.Address, d.Transport)
+
+	if err != nil {
+		return err
+	}
+
+	if d.ID, err = r.LastInsertId(); err != nil {
+		return err
+	}
+
+	if err := d.assign(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Update saves the device then replaces its leaders in one transaction
+func (d *Device) Update() error {
+	tx, err := db.Beginx()
+
+	if err != nil {
+		return err
+	}
+
+	// Rollback does nothing once the transaction is committed
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		UPDATE device
+		SET name = ?, address = ?, transport = ?
+		WHERE id = ?`,
+		d.Name, d.Address, d.Transport, d.ID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		DELETE FROM device_leader
+		WHERE device_id = ?`,
+		d.ID); err != nil {
+		return err
+	}
+
+	if err := d.assign(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// assign records the leaders of the device within the transaction
+func (d Device) assign(tx *sqlx.Tx) error {
+	for _, lid := range d.Leaders {
+		if _, err := tx.Exec(`
+			INSERT INTO device_leader (device_id, leader_id)
+			VALUES (?, ?)`,
+			d.ID, lid); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes the device and its leaders in one transaction,
+// its leaders use the default proxy again
+func (d Device) Delete() error {
+	tx, err := db.Beginx()
+
+	if err != nil {
+		return err
+	}
+
+	// Rollback does nothing once the transaction is committed
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		DELETE FROM device_leader
+		WHERE device_id = ?`,
+		d.ID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		DELETE FROM device
+		WHERE id = ?`,
+		d.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Find loads the device and its leaders by id
+func (d *Device) Find() error {
+	if err := db.Get(d, `
+		SELECT`+deviceColumns+`
+		FROM device
+		WHERE id = ?`,
+		*config.DeviceOnline, d.ID); err != nil {
+		return err
+	}
+
+	d.Leaders = []int64{}
+
+	return db.Select(&d.Leaders, `
+		SELECT leader_id
+		FROM device_leader
+		WHERE device_id = ?
+		ORDER BY leader_id`,
+		d.ID)
+}
+
+// ListDevices returns all devices with their leaders
+func ListDevices() ([]Device, error) {
+	l := []Device{}
+
+	if err := db.Select(&l, `
+		SELECT`+deviceColumns+`
+		FROM device
+		ORDER BY name`,
+		*config.DeviceOnline); err != nil {
+		return nil, err
+	}
+
+	a := []struct {
+		DeviceID int64 `db:"device_id"`
+		LeaderID int64 `db:"leader_id"`
+	}{}
+
+	if err := db.Select(&a, `
+		SELECT device_id, leader_id
+		FROM device_leader
+		ORDER BY leader_id`); err != nil {
+		return nil, err
+	}
+
+	for i := range l {
+		l[i].Leaders = []int64{}
+
+		for _, e := range a {
+			if e.DeviceID == l[i].ID {
+				l[i].Leaders = append(l[i].Leaders, e.LeaderID)
+			}
+		}
+	}
+
+	return l, nil
+}
+
+// LeaderDevices returns the devices assigned to the leader, oldest first
+func LeaderDevices(lid int64) ([]Device, error) {
+	l := []Device{}
+	err := db.Select(&l, `
+		SELECT`+deviceColumns+`
+		FROM device
+		WHERE id IN (SELECT device_id FROM device_leader WHERE leader_id = ?)
+		ORDER BY id`,
+		*config.DeviceOnline, lid)
+	return l, err
+}
+
+// DeviceSeen records that the device answered an order
+func DeviceSeen(id int64) error {
+	_, err := db.Exec(`
+		UPDATE device
+		SET last_seen_at = NOW()
+		WHERE id = ?`,
+		id)
+	return err
+}
+
+// AreLeaders checks that all ids are leaders
+func AreLeaders(ids []int64) bool {
+	for _, id := range ids {
+		if err := db.Get(&User{}, `
+			SELECT id
+			FROM user
+			WHERE id = ? AND role = ?`,
+			id, LeaderRole); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
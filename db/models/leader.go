@@ -1,14 +1,10 @@
 package models
 
 import (
-	"log"
-	"os"
-
+	"github.com/jmoiron/sqlx"
 	"gitlab.com/arnaud-web/neli-webservices/config"
 )
 
-var logger = log.New(os.Stdout, "", log.LstdFlags|log.Lshortfile)
-
 // Leader represents a leader user
 type Leader struct {
 	*User
@@ -32,9 +28,12 @@ func (l Leader) ToJSON() interface{} {
 	return l
 }
 
-// Content get all content created by user
-func (l Leader) Content() ([]Content, error) {
+// Content get all content created by user having one of the statuses,
+// all but deleted ones when no status is given, and all the tags
+func (l Leader) Content(statuses []string, tags []string) ([]Content, error) {
 	c := []Content{}
+	cond, args := inStatus(statuses)
+	tcond, targs := withTags(tags)
 	err := db.Select(&c, `
 		SELECT 
 			id, 
@@ -43,30 +42,89 @@ func (l Leader) Content() ([]Content, error) {
 			DATE_FORMAT(created_at,'%Y-%m-%d') as creation_date,
 			IFNULL (duration, 0) AS duration, 
 			(SELECT COUNT(*) > 0 FROM share WHERE video_content.id = content_id) as sharing_status,
-			IFNULL (duration, 0) != 0 AS ready
+			status,
+			IFNULL(failure_reason, '') AS failure_reason,
+			IFNULL(path, '') AS path,
+			IFNULL(poster, '') AS poster,`+renditionsColumn+`,`+tagsColumn+`,
+			status = 'ready' AS ready,`+stalled+`
 		FROM video_content 
-		WHERE leader_id = ?
-		ORDER BY created_at DESC`, l.ID)
+		WHERE leader_id = ?`+cond+tcond+`
+		ORDER BY created_at DESC`, append(append([]interface{}{*config.ReadyTimeout, l.ID}, args...), targs...)...)
+
+	thumbnails(c)
+	sources(c)
 	return c, err
 }
 
-// DeleteContent attach video content to zombie
+// DeleteContent attach video content to zombie once deleted.
+// The original leader is kept so an admin can restore the content before cleaning.
 func (l Leader) DeleteContent(cid int64) error {
-	_, err := db.Exec("UPDATE video_content SET leader_id = ? WHERE id = ?", *config.ZombieID, cid)
-	return err
+	tx, err := db.Beginx()
+
+	if err != nil {
+		return err
+	}
+
+	// Rollback does nothing once the transaction is committed
+	defer tx.Rollback()
+
+	if err := trash(tx, cid); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-// DeleteContent a leader and attach all videos to zombie
+// DeleteContent a leader and attach all videos to zombie once deleted
 func (l Leader) Delete() error {
-	if _, err := db.Exec("UPDATE video_content SET leader_id = ? WHERE leader_id = ?", *config.ZombieID, l.ID); err != nil {
+	tx, err := db.Beginx()
+
+	if err != nil {
 		return err
 	}
 
-	_, err := db.NamedExec(`
+	// Rollback does nothing once the transaction is committed
+	defer tx.Rollback()
+
+	var ids []int64
+
+	if err := tx.Select(&ids, `
+		SELECT id
+		FROM video_content
+		WHERE leader_id = ?`,
+		l.ID); err != nil {
+		return err
+	}
+
+	for _, cid := range ids {
+		if err := trash(tx, cid); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.NamedExec(`
 		DELETE FROM user 
 		WHERE id = :id`,
-		&l)
+		&l); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// trash deletes the content then attaches it to zombie in the transaction
+func trash(tx *sqlx.Tx, cid int64) error {
+	c := Content{ID: cid}
+
+	if err := c.transition(tx, ContentDeleted); err != nil {
+		return err
+	}
 
+	_, err := tx.Exec(`
+		UPDATE video_content
+		SET previous_leader_id = leader_id, leader_id = ?
+		WHERE id = ? AND leader_id != ?`,
+		*config.ZombieID, cid, *config.ZombieID)
 	return err
 }
 
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Marker is a chapter of a content, set by its leader at an offset in seconds
+type Marker struct {
+	ID        int64     `db:"id" json:"id"`
+	ContentID int64     `db:"video_content_id" json:"videoContentId"`
+	Offset    int       `db:"time_offset" json:"offset"`
+	Title     string    `db:"title" json:"title"`
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+}
+
+// ListMarkers returns the markers of the content ordered by offset
+func ListMarkers(cid int64) ([]Marker, error) {
+	l := []Marker{}
+	err := db.Select(&l, `
+		SELECT id, video_content_id, time_offset, title, created_at
+		FROM marker
+		WHERE video_content_id = ?
+		ORDER BY time_offset, id`,
+		cid)
+	return l, err
+}
+
+// New records the marker
+func (m *Marker) New() error {
+	r, err := db.Exec(`
+		INSERT INTO marker (video_content_id, time_offset, title, created_at)
+		VALUES (?, ?, ?, NOW())`,
+		m.ContentID, m.Offset, m.Title)
+
+	if err != nil {
+		return err
+	}
+
+	m.ID, err = r.LastInsertId()
+	m.CreatedAt = time.Now()
+	return err
+}
+
+// Update changes the offset and title of the marker of the content.
+// sql.ErrNoRows is returned when the content has no such marker.
+func (m Marker) Update() error {
+	if err := db.Get(&Marker{}, `
+		SELECT id
+		FROM marker
+		WHERE id = ? AND video_content_id = ?`,
+		m.ID, m.ContentID); err != nil {
+		return err
+	}
+
+	_, err := db.Exec(`
+		UPDATE marker
+		SET time_offset = ?, title = ?
+		WHERE id = ?`,
+		m.Offset, m.Title, m.ID)
+	return err
+}
+
+// Delete removes the marker of the content.
+// sql.ErrNoRows is returned when the content has no such marker.
+func (m Marker) Delete() error {
+	r, err := db.Exec(`
+		DELETE FROM marker
+		WHERE id = ? AND video_content_id = ?`,
+		m.ID, m.ContentID)
+
+	if err != nil {
+		return err
+	}
+
+	if n, _ := r.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,324 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.com/arnaud-web/neli-webservices/config"
+)
+
+// Playlist is an ordered list of contents of a leader shared as a unit.
+// Its contents are read when it is watched so a content ready later
+// is part of the existing shares.
+type Playlist struct {
+	ID          int64     `db:"id" json:"id"`
+	LeaderID    int64     `db:"leader_id" json:"leaderId"`
+	Name        string    `db:"name" json:"name"`
+	Description string    `db:"description" json:"description"`
+	ContentIDs  []int64   `db:"-" json:"contentIds"`
+	Contents    []Content `db:"-" json:"contents,omitempty"`
+}
+
+// SharedPlaylist is a playlist shared with a member by a share not expired yet,
+// the share expiring last is kept
+type SharedPlaylist struct {
+	Playlist
+	ExpirationDate JSONTime `db:"expiration_date" json:"expirationDate"`
+}
+
+// PlaylistShare is the share of a playlist with a member,
+// mailed once with the url of its token
+type PlaylistShare struct {
+	ID             int64    `db:"id" json:"id,omitempty"`
+	PlaylistID     int64    `db:"playlist_id" json:"playlistId,omitempty"`
+	UserID         int64    `db:"user_id" json:"memberId"`
+	UserName       string   `db:"name" json:"name,omitempty"`
+	Token          string   `db:"token" json:"-"`
+	Message        string   `db:"message" json:"message"`
+	ExpirationDate JSONTime `db:"expiration_date" json:"expirationDate"`
+}
+
+// sharedPlaylistQuery selects the playlists shared with a member,
+// conditions on playlist and share are appended before grouping
+const sharedPlaylistQuery = `
+	SELECT
+		playlist.id, playlist.leader_id, playlist.name, playlist.description,
+		MAX(playlist_share.expiration_date) AS expiration_date
+	FROM playlist_share
+	JOIN playlist ON playlist.id = playlist_share.playlist_id
+	WHERE playlist_share.expiration_date > NOW() AND playlist_share.user_id = ?`
+
+// playlistSharedQuery selects the ready contents shared with a member
+// by a playlist, conditions on content are appended
+const playlistSharedQuery = `
+	SELECT video_content.id, video_content.path, playlist_share.expiration_date
+	FROM playlist_share
+	JOIN playlist_content ON playlist_content.playlist_id = playlist_share.playlist_id
+	JOIN video_content ON video_content.id = playlist_content.video_content_id
+	WHERE video_content.status = ? AND playlist_share.expiration_date > NOW() AND playlist_share.user_id = ?`
+
+// New creates the playlist of the leader then records its contents in one transaction
+func (p *Playlist) New() error {
+	tx, err := db.Beginx()
+
+	if err != nil {
+		return err
+	}
+
+	// Rollback does nothing once the transaction is committed
+	defer tx.Rollback()
+
+	r, err := tx.Exec(`
+		INSERT INTO playlist (leader_id, name, description)
+		VALUES (?, ?, ?)`,
+		p.LeaderID, p.Name, p.Description)
+
+	if err != nil {
+		return err
+	}
+
+	if p.ID, err = r.LastInsertId(); err != nil {
+		return err
+	}
+
+	if err := p.assign(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Update saves the playlist of the leader then replaces its contents in one transaction
+func (p *Playlist) Update() error {
+	tx, err := db.Beginx()
+
+	if err != nil {
+		return err
+	}
+
+	// Rollback does nothing once the transaction is committed
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		UPDATE playlist
+		SET name = ?, description = ?
+		WHERE id = ? AND leader_id = ?`,
+		p.Name, p.Description, p.ID, p.LeaderID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		DELETE FROM playlist_content
+		WHERE playlist_id = ?`,
+		p.ID); err != nil {
+		return err
+	}
+
+	if err := p.assign(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// assign records the contents of the playlist in their order within the transaction
+func (p Playlist) assign(tx *sqlx.Tx) error {
+	for i, cid := range p.ContentIDs {
+		if _, err := tx.Exec(`
+			INSERT INTO playlist_content (playlist_id, video_content_id, position)
+			VALUES (?, ?, ?)`,
+			p.ID, cid, i); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes the playlist with its contents and shares in one transaction,
+// the contents themselves are kept
+func (p Playlist) Delete() error {
+	tx, err := db.Beginx()
+
+	if err != nil {
+		return err
+	}
+
+	// Rollback does nothing once the transaction is committed
+	defer tx.Rollback()
+
+	for _, t := range []string{"playlist_share", "playlist_content"} {
+		if _, err := tx.Exec(`
+			DELETE FROM `+t+`
+			WHERE playlist_id = ?`,
+			p.ID); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`
+		DELETE FROM playlist
+		WHERE id = ?`,
+		p.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Find loads the playlist of the leader and its content ids by id
+func (p *Playlist) Find() error {
+	if err := db.Get(p, `
+		SELECT id, leader_id, name, description
+		FROM playlist
+		WHERE id = ? AND leader_id = ?`,
+		p.ID, p.LeaderID); err != nil {
+		return err
+	}
+
+	p.ContentIDs = []int64{}
+
+	return db.Select(&p.ContentIDs, `
+		SELECT video_content_id
+		FROM playlist_content
+		WHERE playlist_id = ?
+		ORDER BY position`,
+		p.ID)
+}
+
+// FindContents loads the contents of the playlist in their order,
+// the ready ones only when ready is set, all but deleted ones otherwise
+func (p *Playlist) FindContents(ready bool) error {
+	var cond string
+	var args []interface{}
+
+	if ready {
+		cond, args = inStatus([]string{ContentReady})
+	} else {
+		cond, args = inStatus(nil)
+	}
+
+	p.Contents = []Content{}
+
+	if err := db.Select(&p.Contents, `
+		SELECT
+			video_content.id,
+			IFNULL(name, '') AS name,
+			IFNULL(description, '') AS description,
+			IFNULL(duration, 0) AS duration,
+			IFNULL(path, '') AS path,
+			IFNULL(poster, '') AS poster,
+			status,
+			status = 'ready' AS ready
+		FROM playlist_content
+		JOIN video_content ON video_content.id = playlist_content.video_content_id
+		WHERE playlist_content.playlist_id = ?`+cond+`
+		ORDER BY playlist_content.position`,
+		append([]interface{}{p.ID}, args...)...); err != nil {
+		return err
+	}
+
+	thumbnails(p.Contents)
+	return nil
+}
+
+// Playlists returns the playlists of the leader with their content ids, newest first
+func (l Leader) Playlists() ([]Playlist, error) {
+	p := []Playlist{}
+
+	if err := db.Select(&p, `
+		SELECT id, leader_id, name, description
+		FROM playlist
+		WHERE leader_id = ?
+		ORDER BY created_at DESC, id DESC`,
+		l.ID); err != nil {
+		return nil, err
+	}
+
+	a := []struct {
+		PlaylistID int64 `db:"playlist_id"`
+		ContentID  int64 `db:"video_content_id"`
+	}{}
+
+	if err := db.Select(&a, `
+		SELECT playlist_id, video_content_id
+		FROM playlist_content
+		JOIN playlist ON playlist.id = playlist_content.playlist_id
+		WHERE playlist.leader_id = ?
+		ORDER BY position`,
+		l.ID); err != nil {
+		return nil, err
+	}
+
+	for i := range p {
+		p[i].ContentIDs = []int64{}
+
+		for _, e := range a {
+			if e.PlaylistID == p[i].ID {
+				p[i].ContentIDs = append(p[i].ContentIDs, e.ContentID)
+			}
+		}
+	}
+
+	return p, nil
+}
+
+// SharedPlaylists returns the playlists shared with the member, expiring last first
+func SharedPlaylists(uid int64) ([]SharedPlaylist, error) {
+	l := []SharedPlaylist{}
+	err := db.Select(&l, sharedPlaylistQuery+`
+		GROUP BY playlist.id
+		ORDER BY expiration_date DESC`,
+		uid)
+	return l, err
+}
+
+// Find loads the playlist shared with the member by id
+func (p *SharedPlaylist) Find(uid int64) error {
+	return db.Get(p, sharedPlaylistQuery+` AND playlist.id = ?
+		GROUP BY playlist.id`,
+		uid, p.ID)
+}
+
+// FindByToken loads the playlist shared with the member by the token of the url sent by mail
+func (p *SharedPlaylist) FindByToken(uid int64, token string) error {
+	return db.Get(p, sharedPlaylistQuery+` AND playlist_share.token = ?
+		GROUP BY playlist.id`,
+		uid, token)
+}
+
+// New records the share of the playlist
+func (s *PlaylistShare) New() error {
+	r, err := db.Exec(`
+		INSERT INTO playlist_share (playlist_id, user_id, token, message, expiration_date)
+		VALUES (?, ?, ?, ?, ?)`,
+		s.PlaylistID, s.UserID, s.Token, s.Message, time.Time(s.ExpirationDate))
+
+	if err != nil {
+		return err
+	}
+
+	s.ID, err = r.LastInsertId()
+	return err
+}
+
+// URL returns the url of the share mailed to the member
+func (s PlaylistShare) URL() string {
+	return fmt.Sprintf("%s/%d/playlist/%s", *config.URL, s.UserID, s.Token)
+}
+
+// ListPlaylistShares returns the shares of the playlist with the member names
+func ListPlaylistShares(pid int64) ([]PlaylistShare, error) {
+	l := []PlaylistShare{}
+	err := db.Select(&l, `
+		SELECT
+			playlist_share.id, user_id, message, expiration_date,
+			CONCAT_WS(' ', firstname, lastname) AS name
+		FROM playlist_share
+		JOIN user ON user.id = playlist_share.user_id
+		WHERE playlist_id = ?
+		ORDER BY playlist_share.created_at DESC, playlist_share.id DESC`,
+		pid)
+	return l, err
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gitlab.com/arnaud-web/neli-webservices/config"
+	"gitlab.com/arnaud-web/neli-webservices/storage"
+)
+
+// PosterDir is the directory of the posters in the storage
+const PosterDir = "public"
+
+// placeholderPoster is the poster of the contents without one
+const placeholderPoster = "placeholder.jpg"
+
+// PlaceholderKey is the storage key of the poster of the contents without one
+const PlaceholderKey = PosterDir + "/" + placeholderPoster
+
+// PosterKey returns the storage key of the poster uploaded by the leader of the content
+func PosterKey(cid int64) string {
+	return fmt.Sprintf("%s/%d.jpg", PosterDir, cid)
+}
+
+// HubPosterKey returns the storage key of the poster produced by the hub
+// next to the video of the content
+func HubPosterKey(cid int64) string {
+	return fmt.Sprintf("%d.jpg", cid)
+}
+
+// ThumbnailURL returns the url of the poster stored under key valid until expires,
+// signed by the storage. The key is the one recorded for the content,
+// the stub poster is used in stub mode and the placeholder when there is none.
+func ThumbnailURL(key string, expires time.Time) string {
+	if key == "" && *config.Stub == 1 {
+		key = PosterDir + "/" + config.StubPath + ".jpg"
+	} else if key == "" {
+		key = PlaceholderKey
+	}
+
+	// An url can't be signed once expired, there is no poster then
+	u, _ := storage.Default.SignedURL(key, expires)
+	return u
+}
+
+// thumbnails sets the thumbnail url of the contents,
+// valid for asset_url_life seconds
+func thumbnails(l []Content) {
+	e := time.Now().Add(time.Duration(*config.AssetURLLife) * time.Second).Truncate(time.Second)
+
+	for i := range l {
+		l[i].ThumbnailURL = ThumbnailURL(l[i].Poster, e)
+	}
+}
+
+// SetPoster records the key of the poster uploaded by the leader of the content
+func (c Content) SetPoster(key string) error {
+	_, err := db.Exec(`
+		UPDATE video_content
+		SET poster = ?
+		WHERE id = ?`,
+		key, c.ID)
+	return err
+}
+
+// SetHubPoster records the poster produced by the hub
+// unless the leader uploaded one
+func (c Content) SetHubPoster() error {
+	_, err := db.Exec(`
+		UPDATE video_content
+		SET poster = ?
+		WHERE id = ? AND poster IS NULL`,
+		HubPosterKey(c.ID), c.ID)
+	return err
+}
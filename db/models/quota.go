@@ -0,0 +1,131 @@
+package models
+
+import "database/sql"
+
+// Quota limits the total recorded minutes and the number of videos of each leader,
+// 0 meaning unlimited. The defaults are part of the settings, an admin or a leader
+// may have an override whose missing limits are inherited.
+type Quota struct {
+	UserID  int64 `db:"user_id" json:"userId,omitempty"`
+	Minutes *int  `db:"minutes" json:"minutes"`
+	Videos  *int  `db:"videos" json:"videos"`
+}
+
+// Usage is the consumption of a leader against the quota applying to them:
+// their own override, else the one of their admin, else the defaults
+type Usage struct {
+	Videos     int `db:"videos" json:"videos"`
+	Seconds    int `db:"seconds" json:"seconds"`
+	Minutes    int `db:"-" json:"minutes"`
+	MaxVideos  int `db:"max_videos" json:"maxVideos"`
+	MaxMinutes int `db:"max_minutes" json:"maxMinutes"`
+}
+
+// DefaultQuota loads the quota of the leaders without override
+func DefaultQuota() (Quota, error) {
+	q := Quota{}
+	err := db.Get(&q, `
+		SELECT quota_minutes AS minutes, quota_videos AS videos
+		FROM settings
+		LIMIT 1`)
+	return q, err
+}
+
+// SetDefault saves the quota as the default one
+func (q Quota) SetDefault() error {
+	_, err := db.Exec(`
+		UPDATE settings
+		SET quota_minutes = ?, quota_videos = ?`,
+		q.Minutes, q.Videos)
+	return err
+}
+
+// FindQuota loads the override of the admin or the leader,
+// a user without override gets one inheriting all its limits
+func FindQuota(uid int64) (Quota, error) {
+	q := Quota{}
+	err := db.Get(&q, `
+		SELECT user_id, minutes, videos
+		FROM quota
+		WHERE user_id = ?`,
+		uid)
+
+	if err == sql.ErrNoRows {
+		return Quota{UserID: uid}, nil
+	}
+
+	return q, err
+}
+
+// Set saves the quota as the override of the admin or the leader
+func (q Quota) Set() error {
+	_, err := db.Exec(`
+		INSERT INTO quota (user_id, minutes, videos)
+		VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE minutes = VALUES(minutes), videos = VALUES(videos)`,
+		q.UserID, q.Minutes, q.Videos)
+	return err
+}
+
+// SetAdmin records the admin managing the leader, whose override applies to them
+func (l Leader) SetAdmin(aid int64) error {
+	_, err := db.Exec(`
+		UPDATE user
+		SET admin_id = ?
+		WHERE id = ?`,
+		aid, l.ID)
+	return err
+}
+
+// Usage returns the videos and the recorded duration of the leader,
+// deleted contents excluded, with the quota applying to them.
+// The running capture counts for its max duration until it ends.
+func (l Leader) Usage() (Usage, error) {
+	return l.UsageExcept(0)
+}
+
+// UsageExcept returns the usage of the leader without the duration
+// of the content, recorded or being captured, so that it isn't counted twice
+// when the content becomes ready
+func (l Leader) UsageExcept(cid int64) (Usage, error) {
+	u := Usage{}
+
+	if err := db.Get(&u, `
+		SELECT
+			(SELECT COUNT(*) FROM video_content WHERE leader_id = user.id AND status != ?) AS videos,
+			(SELECT IFNULL(SUM(duration), 0) FROM video_content WHERE leader_id = user.id AND status != ? AND id != ?) + (
+				SELECT IFNULL(SUM(max_duration), 0) FROM capture_session
+				WHERE leader_id = user.id AND video_content_id != ?
+					AND stopped_at IS NULL AND started_at + INTERVAL max_duration SECOND > NOW()
+			) AS seconds,
+			COALESCE(own.videos, admin.videos, (SELECT quota_videos FROM settings LIMIT 1), 0) AS max_videos,
+			COALESCE(own.minutes, admin.minutes, (SELECT quota_minutes FROM settings LIMIT 1), 0) AS max_minutes
+		FROM user
+		LEFT JOIN quota own ON own.user_id = user.id
+		LEFT JOIN quota admin ON admin.user_id = user.admin_id
+		WHERE user.id = ? AND user.role = ?`,
+		ContentDeleted, ContentDeleted, cid, cid, l.ID, LeaderRole); err != nil {
+		return u, err
+	}
+
+	u.Minutes = u.Seconds / 60
+	return u, nil
+}
+
+// CanCreate tells whether the leader may create another video
+func (u Usage) CanCreate() bool {
+	return u.MaxVideos == 0 || u.Videos < u.MaxVideos
+}
+
+// SecondsLeft returns the duration the leader may still record, -1 when unlimited
+func (u Usage) SecondsLeft() int64 {
+	if u.MaxMinutes == 0 {
+		return -1
+	}
+
+	if left := int64(u.MaxMinutes)*60 - int64(u.Seconds); left > 0 {
+		return left
+	}
+
+	return 0
+}
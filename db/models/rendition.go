@@ -0,0 +1,137 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"path"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Types of a rendition
+const (
+	RenditionMP4 = "mp4"
+	RenditionHLS = "hls"
+)
+
+// SourceRendition is the name of the single mp4 of a content
+// registered without renditions
+const SourceRendition = "source"
+
+// Rendition is a playable version of the video of a content,
+// like a 360p mp4 or an HLS master playlist.
+// Its file is relative to the path of the content.
+type Rendition struct {
+	Name      string `db:"name" json:"name"`
+	Type      string `db:"type" json:"type"`
+	File      string `db:"file" json:"-"`
+	Width     int    `db:"width" json:"width,omitempty"`
+	Height    int    `db:"height" json:"height,omitempty"`
+	Bandwidth int    `db:"bandwidth" json:"bandwidth,omitempty"`
+}
+
+// Source returns the rendition of the single mp4 stored under path
+func Source(path string) Rendition {
+	return Rendition{Name: SourceRendition, Type: RenditionMP4, File: path + ".mp4"}
+}
+
+// Key returns the storage key of the rendition of the content stored under path
+func (r Rendition) Key(path string) string {
+	return path + "/" + r.File
+}
+
+// Valid checks the rendition has a name, a known type
+// and a file which stays under the path of the content
+func (r Rendition) Valid() bool {
+	if r.Name == "" || (r.Type != RenditionMP4 && r.Type != RenditionHLS) {
+		return false
+	}
+
+	f := r.File
+	return f != "" && path.Clean(f) == f && !path.IsAbs(f) && f != ".." && !strings.HasPrefix(f, "../")
+}
+
+// Renditions is the list of renditions of a content,
+// read from the json array built by renditionsColumn
+type Renditions []Rendition
+
+// renditionsColumn is the column of the renditions of a content, ordered as registered
+const renditionsColumn = `
+	(SELECT JSON_ARRAYAGG(JSON_OBJECT(
+		'name', name, 'type', type, 'width', width, 'height', height, 'bandwidth', bandwidth))
+	FROM rendition WHERE video_content_id = video_content.id) AS renditions`
+
+// Scan implements sql.Scanner
+func (l *Renditions) Scan(src interface{}) error {
+	if src == nil {
+		*l = nil
+		return nil
+	}
+
+	return scanJSON(src, l)
+}
+
+// scanJSON reads the json column src into v
+func scanJSON(src interface{}, v interface{}) error {
+	switch b := src.(type) {
+	case []byte:
+		return json.Unmarshal(b, v)
+	case string:
+		return json.Unmarshal([]byte(b), v)
+	}
+
+	return errors.New("json: unsupported column type")
+}
+
+// sources sets the source rendition of the ready contents registered without renditions
+func sources(l []Content) {
+	for i := range l {
+		if l[i].Ready && len(l[i].Renditions) == 0 {
+			l[i].Renditions = Renditions{Source(l[i].Path)}
+		}
+	}
+}
+
+// FindRenditions returns the renditions of the content stored under path,
+// the source rendition when none was registered
+func FindRenditions(cid int64, path string) ([]Rendition, error) {
+	l := []Rendition{}
+
+	if err := db.Select(&l, `
+		SELECT name, type, file, width, height, bandwidth
+		FROM rendition
+		WHERE video_content_id = ?
+		ORDER BY id`,
+		cid); err != nil {
+		return nil, err
+	}
+
+	if len(l) == 0 {
+		l = append(l, Source(path))
+	}
+
+	return l, nil
+}
+
+// replaceRenditions registers the renditions of the content in the transaction,
+// in place of the previous ones
+func replaceRenditions(tx *sqlx.Tx, cid int64, l []Rendition) error {
+	if _, err := tx.Exec(`
+		DELETE FROM rendition
+		WHERE video_content_id = ?`,
+		cid); err != nil {
+		return err
+	}
+
+	for _, r := range l {
+		if _, err := tx.Exec(`
+			INSERT INTO rendition (video_content_id, name, type, file, width, height, bandwidth)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			cid, r.Name, r.Type, r.File, r.Width, r.Height, r.Bandwidth); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/jmoiron/sqlx"
 	"gitlab.com/arnaud-web/neli-webservices/config"
 )
 
@@ -58,11 +59,16 @@ func (s *Share) generateURL(c *Content) error {
 		return err
 	}
 
-	s.URL = fmt.Sprintf("%s/%d/video/%s", *config.URL, s.UserID, e)
+	s.URL = ShareURL(s.UserID, e)
 
 	return nil
 }
 
+// ShareURL returns the url sent by mail to the member for the share token
+func ShareURL(uid int64, token string) string {
+	return fmt.Sprintf("%s/%d/video/%s", *config.URL, uid, token)
+}
+
 // EncodeBase64 transform a content to a string in base64
 func (s *Share) encodeBase64(c *Content) (string, error) {
 	r := struct {
@@ -115,15 +121,15 @@ func (s *Share) List(cid int64) ([]Share, error) {
 	return l, err
 }
 
-// UpdateURL update only url field for share
-func (s *Share) UpdateURL(c *Content) error {
+// updateURL update only url field for share with the transaction
+func (s *Share) updateURL(tx *sqlx.Tx, c *Content) error {
 	err := s.generateURL(c)
 
 	if err != nil {
 		return err
 	}
 
-	_, err = db.Exec(`
+	_, err = tx.Exec(`
 		UPDATE share
 		SET url = ?
 		WHERE id = ? `,
@@ -131,3 +137,31 @@ func (s *Share) UpdateURL(c *Content) error {
 
 	return err
 }
+
+// Shared is a ready content shared with a member by a share not expired yet
+type Shared struct {
+	ContentID      int64     `db:"id"`
+	Path           string    `db:"path"`
+	ExpirationDate time.Time `db:"expiration_date"`
+}
+
+// sharedQuery selects the shared content, conditions on share are appended
+const sharedQuery = `
+	SELECT video_content.id, video_content.path, share.expiration_date
+	FROM share
+	JOIN video_content ON video_content.id = share.content_id
+	WHERE video_content.status = ? AND share.expiration_date > NOW() AND share.user_id = ?`
+
+// FindByContent loads the content shared with the member alone or by a playlist,
+// with the share expiring last
+func (s *Shared) FindByContent(uid, cid int64) error {
+	return db.Get(s, sharedQuery+` AND share.content_id = ?
+		UNION ALL`+playlistSharedQuery+` AND video_content.id = ?
+		ORDER BY expiration_date DESC LIMIT 1`,
+		ContentReady, uid, cid, ContentReady, uid, cid)
+}
+
+// FindByURL loads the content shared with the member by the share url sent by mail
+func (s *Shared) FindByURL(uid int64, url string) error {
+	return db.Get(s, sharedQuery+` AND share.url = ? LIMIT 1`, ContentReady, uid, url)
+}
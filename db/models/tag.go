@@ -0,0 +1,154 @@
+package models
+
+import "strings"
+
+// Tag is a free-form label of a leader, counted on the contents
+// not deleted it is set on. Leaders is only counted for the admin stats.
+type Tag struct {
+	Name     string `db:"name" json:"name"`
+	Contents int    `db:"contents" json:"contents"`
+	Leaders  int    `db:"leaders" json:"leaders,omitempty"`
+}
+
+// Tags is the list of tag names of a content,
+// read from the json array built by tagsColumn
+type Tags []string
+
+// tagsColumn is the column of the tag names of a content
+const tagsColumn = `
+	(SELECT JSON_ARRAYAGG(tag.name)
+	FROM video_content_tag JOIN tag ON tag.id = video_content_tag.tag_id
+	WHERE video_content_tag.video_content_id = video_content.id) AS tags`
+
+// Scan implements sql.Scanner
+func (l *Tags) Scan(src interface{}) error {
+	if src == nil {
+		*l = nil
+		return nil
+	}
+
+	return scanJSON(src, l)
+}
+
+// withTags returns the condition keeping the contents having all the tags
+func withTags(l []string) (string, []interface{}) {
+	if len(l) == 0 {
+		return "", nil
+	}
+
+	args := make([]interface{}, len(l), len(l)+1)
+
+	for i, t := range l {
+		args[i] = t
+	}
+
+	return `
+		AND id IN (
+			SELECT video_content_tag.video_content_id
+			FROM video_content_tag JOIN tag ON tag.id = video_content_tag.tag_id
+			WHERE tag.name IN (?` + strings.Repeat(", ?", len(l)-1) + `)
+			GROUP BY video_content_tag.video_content_id
+			HAVING COUNT(*) = ?
+		)`, append(args, len(l))
+}
+
+// SetTags replaces the tags of the content of the leader in one transaction.
+// The tags missing from the leader ones are created.
+func (l Leader) SetTags(cid int64, tags []string) error {
+	tx, err := db.Beginx()
+
+	if err != nil {
+		return err
+	}
+
+	// Rollback does nothing once the transaction is committed
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		DELETE FROM video_content_tag
+		WHERE video_content_id = ?`,
+		cid); err != nil {
+		return err
+	}
+
+	for _, t := range tags {
+		if _, err := tx.Exec(`
+			INSERT IGNORE INTO tag (leader_id, name)
+			VALUES (?, ?)`,
+			l.ID, t); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`
+			INSERT INTO video_content_tag (video_content_id, tag_id)
+			SELECT ?, id FROM tag
+			WHERE leader_id = ? AND name = ?`,
+			cid, l.ID, t); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Tags returns the tags of the leader starting with prefix,
+// the most used first, to autocomplete them
+func (l Leader) Tags(prefix string, limit int) ([]Tag, error) {
+	t := []Tag{}
+	err := db.Select(&t, `
+		SELECT tag.name, COUNT(*) AS contents
+		FROM tag
+		JOIN video_content_tag ON video_content_tag.tag_id = tag.id
+		JOIN video_content ON video_content.id = video_content_tag.video_content_id
+		WHERE tag.leader_id = ? AND video_content.status != ? AND tag.name LIKE ?
+		GROUP BY tag.name
+		ORDER BY contents DESC, tag.name
+		LIMIT ?`,
+		l.ID, ContentDeleted, likePrefix(prefix), limit)
+	return t, err
+}
+
+// Tagged returns the contents of the leader not deleted having the tag
+func (l Leader) Tagged(tag string) ([]Content, error) {
+	c := []Content{}
+	err := db.Select(&c, `
+		SELECT
+			video_content.id,
+			IFNULL(video_content.name, '') AS name,
+			IFNULL(description, '') AS description,
+			IFNULL(path, '') AS path,
+			video_content.leader_id,
+			status
+		FROM video_content
+		JOIN video_content_tag ON video_content_tag.video_content_id = video_content.id
+		JOIN tag ON tag.id = video_content_tag.tag_id
+		WHERE video_content.leader_id = ? AND status != ? AND tag.name = ?
+		ORDER BY video_content.created_at DESC`,
+		l.ID, ContentDeleted, tag)
+	return c, err
+}
+
+// TagStats returns the tags of all the leaders merged by name,
+// with their number of contents not deleted and of leaders using them
+func (a Admin) TagStats() ([]Tag, error) {
+	t := []Tag{}
+	err := db.Select(&t, `
+		SELECT
+			tag.name,
+			COUNT(DISTINCT video_content.id) AS contents,
+			COUNT(DISTINCT tag.leader_id) AS leaders
+		FROM tag
+		JOIN video_content_tag ON video_content_tag.tag_id = tag.id
+		JOIN video_content ON video_content.id = video_content_tag.video_content_id
+		WHERE video_content.status != ?
+		GROUP BY tag.name
+		ORDER BY contents DESC, tag.name`,
+		ContentDeleted)
+	return t, err
+}
+
+// likePrefix returns the LIKE pattern matching the strings starting with p
+func likePrefix(p string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(p) + "%"
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"gitlab.com/arnaud-web/neli-webservices/config"
+)
+
+// Upload is a resumable upload of the video file of a content.
+// The uploaded size is the size of the partial file.
+// An unfinished upload expires upload_life seconds after its creation.
+type Upload struct {
+	ID        int64     `db:"id" json:"id"`
+	ContentID int64     `db:"video_content_id" json:"videoContentId"`
+	Path      string    `db:"path" json:"-"`
+	Length    int64     `db:"length" json:"length"`
+	CreatedAt time.Time `db:"created_at" json:"-"`
+}
+
+// New records the upload
+func (u *Upload) New() error {
+	r, err := db.Exec(`
+		INSERT INTO upload (video_content_id, path, length, created_at, expires_at)
+		VALUES (?, ?, ?, NOW(), NOW() + INTERVAL ? SECOND)`,
+		u.ContentID, u.Path, u.Length, *config.UploadLife)
+
+	if err != nil {
+		return err
+	}
+
+	u.ID, err = r.LastInsertId()
+	return err
+}
+
+// Find loads the upload of the content unless it expired
+func (u *Upload) Find() error {
+	return db.Get(u, `
+		SELECT id, video_content_id, path, length, created_at
+		FROM upload
+		WHERE id = ? AND video_content_id = ? AND expires_at > NOW()`,
+		u.ID, u.ContentID)
+}
+
+// Open loads the latest upload of the content which didn't expire
+func (u *Upload) Open() error {
+	return db.Get(u, `
+		SELECT id, video_content_id, path, length, created_at
+		FROM upload
+		WHERE video_content_id = ? AND expires_at > NOW()
+		ORDER BY id DESC
+		LIMIT 1`,
+		u.ContentID)
+}
+
+// ExpiredUploads returns the unfinished uploads which expired
+func ExpiredUploads() ([]Upload, error) {
+	l := []Upload{}
+	err := db.Select(&l, `
+		SELECT id, video_content_id, path, length, created_at
+		FROM upload
+		WHERE expires_at <= NOW()`)
+	return l, err
+}
+
+// Delete removes the upload once it is complete or rejected
+func (u Upload) Delete() error {
+	_, err := db.Exec(`DELETE FROM upload WHERE id = ?`, u.ID)
+	return err
+}
@@ -165,9 +165,9 @@ func saveContent(l []models.Content) {
 	for _, c := range l {
 		c.Path = "123456"
 		if _, err := db.Exec(
-			`INSERT INTO video_content (id, name, description, path, duration, leader_id, created_at) 
-			VALUES (?, ?, ?, ?, ?, ?, NOW())`,
-			c.ID, c.Name, c.Description, c.Path, c.Duration, c.LeaderID); err != nil {
+			`INSERT INTO video_content (id, name, description, path, duration, status, leader_id, created_at) 
+			VALUES (?, ?, ?, ?, ?, ?, ?, NOW())`,
+			c.ID, c.Name, c.Description, c.Path, c.Duration, models.ContentReady, c.LeaderID); err != nil {
 			log.Fatal(err)
 		}
 	}
@@ -0,0 +1,213 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"gitlab.com/arnaud-web/neli-webservices/config"
+)
+
+// Level is the severity of a log entry
+type Level int
+
+const (
+	// DebugLevel is used for verbose informations useful during development
+	DebugLevel Level = iota
+	// InfoLevel is used for normal behaviour
+	InfoLevel
+	// WarnLevel is used for unexpected behaviour which doesn't fail a request
+	WarnLevel
+	// ErrorLevel is used for failures
+	ErrorLevel
+)
+
+var names = map[Level]string{
+	DebugLevel: "debug",
+	InfoLevel:  "info",
+	WarnLevel:  "warn",
+	ErrorLevel: "error",
+}
+
+func (l Level) String() string {
+	return names[l]
+}
+
+// ParseLevel returns the level matching the name.
+// Unknown names are considered as info level.
+func ParseLevel(s string) Level {
+	for l, n := range names {
+		if strings.EqualFold(n, s) {
+			return l
+		}
+	}
+
+	return InfoLevel
+}
+
+type contextKey struct{}
+
+// Logger writes entries as json objects, one per line.
+// Each entry contains time, level, caller and message plus the logger fields.
+type Logger struct {
+	out    io.Writer
+	mu     *sync.Mutex
+	level  Level
+	fields map[string]interface{}
+}
+
+var std = New(os.Stdout, ParseLevel(*config.LogLevel))
+
+// New creates a logger writing entries from level to out
+func New(out io.Writer, level Level) *Logger {
+	return &Logger{
+		out:    out,
+		mu:     new(sync.Mutex),
+		level:  level,
+		fields: map[string]interface{}{},
+	}
+}
+
+// Default returns the logger used when no logger is found in context
+func Default() *Logger {
+	return std
+}
+
+// With returns a copy of the logger with an additional field
+func (l *Logger) With(k string, v interface{}) *Logger {
+	c := *l
+	c.fields = make(map[string]interface{}, len(l.fields)+1)
+
+	for fk, fv := range l.fields {
+		c.fields[fk] = fv
+	}
+
+	c.fields[k] = v
+
+	return &c
+}
+
+// Debug logs operands formatted as fmt.Sprint does
+func (l *Logger) Debug(v ...interface{}) {
+	l.write(DebugLevel, fmt.Sprint(v...))
+}
+
+// Debugf logs operands formatted as fmt.Sprintf does
+func (l *Logger) Debugf(format string, v ...interface{}) {
+	l.write(DebugLevel, fmt.Sprintf(format, v...))
+}
+
+// Info logs operands formatted as fmt.Sprint does
+func (l *Logger) Info(v ...interface{}) {
+	l.write(InfoLevel, fmt.Sprint(v...))
+}
+
+// Infof logs operands formatted as fmt.Sprintf does
+func (l *Logger) Infof(format string, v ...interface{}) {
+	l.write(InfoLevel, fmt.Sprintf(format, v...))
+}
+
+// Warn logs operands formatted as fmt.Sprint does
+func (l *Logger) Warn(v ...interface{}) {
+	l.write(WarnLevel, fmt.Sprint(v...))
+}
+
+// Warnf logs operands formatted as fmt.Sprintf does
+func (l *Logger) Warnf(format string, v ...interface{}) {
+	l.write(WarnLevel, fmt.Sprintf(format, v...))
+}
+
+// Error logs operands formatted as fmt.Sprint does
+func (l *Logger) Error(v ...interface{}) {
+	l.write(ErrorLevel, fmt.Sprint(v...))
+}
+
+// Errorf logs operands formatted as fmt.Sprintf does
+func (l *Logger) Errorf(format string, v ...interface{}) {
+	l.write(ErrorLevel, fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) write(lvl Level, msg string) {
+	if lvl < l.level {
+		return
+	}
+
+	e := make(map[string]interface{}, len(l.fields)+4)
+
+	for k, v := range l.fields {
+		e[k] = v
+	}
+
+	e["time"] = time.Now().Format(time.RFC3339Nano)
+	e["level"] = lvl.String()
+	e["msg"] = msg
+
+	// Skip write and the level method to find the caller
+	if _, file, line, ok := runtime.Caller(2); ok {
+		e["caller"] = fmt.Sprintf("%s:%d", filepath.Base(file), line)
+	}
+
+	b, err := json.Marshal(e)
+
+	if err != nil {
+		b = []byte(fmt.Sprintf(`{"level":"error","msg":%q}`, err.Error()))
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.out.Write(append(b, '\n'))
+}
+
+// NewContext returns a copy of ctx holding the logger
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger held by ctx or the default logger
+func FromContext(ctx context.Context) *Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+			return l
+		}
+	}
+
+	return std
+}
+
+// Middleware puts a logger on the request context.
+// The logger is tagged with the request id set by chi RequestID middleware
+// which is also sent back in X-Request-Id header.
+// Each request is logged when the response is written.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := middleware.GetReqID(r.Context())
+		l := std.With("request_id", id)
+
+		if id != "" {
+			w.Header().Set("X-Request-Id", id)
+		}
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		t := time.Now()
+
+		next.ServeHTTP(ww, r.WithContext(NewContext(r.Context(), l)))
+
+		l.With("method", r.Method).
+			With("path", r.URL.Path).
+			With("status", ww.Status()).
+			With("bytes", ww.BytesWritten()).
+			With("duration_ms", time.Since(t).Nanoseconds()/int64(time.Millisecond)).
+			With("remote", r.RemoteAddr).
+			Info("request")
+	})
+}
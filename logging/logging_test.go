@@ -0,0 +1,79 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/middleware"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
+	"gitlab.com/arnaud-web/neli-webservices/test/assert"
+)
+
+func TestLogger(t *testing.T) {
+	var b bytes.Buffer
+	l := logging.New(&b, logging.InfoLevel).With("request_id", "abc")
+
+	l.Errorf("failure %d", 42)
+
+	e := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(b.Bytes(), &e))
+
+	assert.Equals(t, e["level"].(string), "error")
+	assert.Equals(t, e["msg"].(string), "failure 42")
+	assert.Equals(t, e["request_id"].(string), "abc")
+	assert.Contains(t, e["caller"].(string), "logging_test.go")
+}
+
+func TestLogger_Level(t *testing.T) {
+	var b bytes.Buffer
+	l := logging.New(&b, logging.WarnLevel)
+
+	l.Info("ignored")
+	l.Debug("ignored")
+
+	assert.Equals(t, b.String(), "")
+}
+
+func TestParseLevel(t *testing.T) {
+	assert.Equals(t, logging.ParseLevel("DEBUG").String(), "debug")
+	assert.Equals(t, logging.ParseLevel("warn").String(), "warn")
+	assert.Equals(t, logging.ParseLevel("unknown").String(), "info")
+}
+
+func TestFromContext(t *testing.T) {
+	l := logging.New(&bytes.Buffer{}, logging.InfoLevel)
+
+	if logging.FromContext(logging.NewContext(context.Background(), l)) != l {
+		t.Fatal("Expected logger from context")
+	}
+
+	if logging.FromContext(context.Background()) != logging.Default() {
+		t.Fatal("Expected default logger")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var id string
+
+	h := middleware.RequestID(logging.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id = middleware.GetReqID(r.Context())
+
+		if logging.FromContext(r.Context()) == logging.Default() {
+			t.Fatal("Expected request logger in context")
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	rr := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+
+	h.ServeHTTP(rr, r)
+
+	assert.NotEmpty(t, id)
+	assert.Equals(t, rr.Header().Get("X-Request-Id"), id)
+}
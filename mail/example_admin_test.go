@@ -1,6 +1,7 @@
 package mail
 
 import (
+	"context"
 	"fmt"
 	"net/smtp"
 
@@ -10,7 +11,7 @@ import (
 func ExampleAdmin() {
 	sendmail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error { return nil }
 
-	Admin(fake.SimplePassword(), fake.EmailAddress())
+	Admin(context.Background(), fake.SimplePassword(), fake.EmailAddress())
 
 	fmt.Println(adminSubject)
 	// Output: Subject: Your backoffice account
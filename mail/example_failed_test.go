@@ -0,0 +1,20 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+
+	"github.com/icrowley/fake"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+)
+
+func ExampleFailed() {
+	sendmail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error { return nil }
+
+	c := &models.Content{Name: fake.Title(), FailureReason: fake.Sentence()}
+	Failed(context.Background(), c, fake.EmailAddress())
+
+	fmt.Println(failedSubject)
+	// Output: Subject: Your video could not be processed
+}
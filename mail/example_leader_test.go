@@ -1,6 +1,7 @@
 package mail
 
 import (
+	"context"
 	"fmt"
 	"net/smtp"
 
@@ -10,7 +11,7 @@ import (
 func ExampleLeader() {
 	sendmail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error { return nil }
 
-	Leader(fake.SimplePassword(), fake.EmailAddress())
+	Leader(context.Background(), fake.SimplePassword(), fake.EmailAddress())
 
 	fmt.Println(leaderSubject)
 	// Output: Subject: Your N7 account
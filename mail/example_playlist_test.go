@@ -0,0 +1,27 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/icrowley/fake"
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+)
+
+func ExamplePlaylist() {
+	var msg []byte
+	sendmail = func(addr string, a smtp.Auth, from string, to []string, m []byte) error { msg = m; return nil }
+
+	u := &models.User{Email: fake.EmailAddress()}
+	l := &models.User{Firstname: "Ada", Lastname: "Lovelace"}
+	s := &models.PlaylistShare{UserID: 42, Token: "token", ExpirationDate: models.JSONTime(time.Now())}
+	p := &models.Playlist{Name: fake.Title(), Contents: []models.Content{{ID: 1}, {ID: 2}}}
+
+	Playlist(context.Background(), u, s, p, l)
+
+	fmt.Println(strings.Contains(string(msg), "Subject: Ada Lovelace want to share a playlist with you!"))
+	// Output: true
+}
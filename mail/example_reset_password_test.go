@@ -1,6 +1,7 @@
 package mail
 
 import (
+	"context"
 	"fmt"
 	"net/smtp"
 
@@ -10,7 +11,7 @@ import (
 func ExampleResetPassword() {
 	sendmail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error { return nil }
 
-	ResetPassword(fake.Sentence(), fake.EmailAddress())
+	ResetPassword(context.Background(), fake.Sentence(), fake.EmailAddress())
 
 	fmt.Println(resetSubject)
 	// Output: Subject: Reset your password
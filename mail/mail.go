@@ -1,67 +1,90 @@
 package mail
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
+	"html"
 	"io/ioutil"
-	"log"
 	"net/mail"
 	"net/smtp"
-	"os"
 	"strings"
 	"time"
 
 	"gitlab.com/arnaud-web/neli-webservices/config"
 	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
+	"gitlab.com/arnaud-web/neli-webservices/metrics"
 )
 
 var sendmail = smtp.SendMail
 
 const (
-	resetSubject  = "Subject: Reset your password"
-	adminSubject  = "Subject: Your backoffice account"
-	leaderSubject = "Subject: Your N7 account"
-	shareSubject  = "FIRSTNAME LASTNAME want to share a video with you!"
+	resetSubject    = "Subject: Reset your password"
+	adminSubject    = "Subject: Your backoffice account"
+	leaderSubject   = "Subject: Your N7 account"
+	failedSubject   = "Subject: Your video could not be processed"
+	shareSubject    = "FIRSTNAME LASTNAME want to share a video with you!"
+	playlistSubject = "FIRSTNAME LASTNAME want to share a playlist with you!"
 )
 
-var logger = log.New(os.Stdout, "", log.LstdFlags|log.Lshortfile)
+// maxPosterLife is the longest validity of the url of a poster in a mail,
+// presigned S3 urls can't last more than a week
+const maxPosterLife = 7 * 24 * time.Hour
+
+// posterURL returns the url of the poster stored under key valid until the share expires,
+// urls served by the service are made absolute for mail clients
+func posterURL(key string, expires time.Time) string {
+	if max := time.Now().Add(maxPosterLife); expires.After(max) {
+		expires = max
+	}
+
+	u := models.ThumbnailURL(key, expires)
+
+	if strings.HasPrefix(u, "/") {
+		u = strings.TrimSuffix(*config.URL, "/") + u
+	}
+
+	return u
+}
 
 // ResetPassword sends an email to an user with a token to reset his password.
-func ResetPassword(token, recipient string) {
+// The logger of ctx is used so failures keep the request id.
+func ResetPassword(ctx context.Context, token, recipient string) {
 	b, err := ioutil.ReadFile("./__resources__/mails/reset.html") // just pass the file name
 
 	if err != nil {
-		logger.Print(err)
+		logging.FromContext(ctx).Error(err)
 	}
 
 	c := strings.Replace(string(b), "TOKEN", string(token), 1)
 	c = strings.Replace(c, "URL_CONTENT", *config.URL, 2)
 	c = strings.Replace(c, "URL", *config.URL, 1)
 
-	send(c, resetSubject, recipient)
+	send(ctx, "reset", c, resetSubject, recipient)
 }
 
 // Admin sends an email to new admin with his login and password
-func Admin(pw, email string) {
+func Admin(ctx context.Context, pw, email string) {
 	b, err := ioutil.ReadFile("./__resources__/mails/admin.html") // just pass the file name
 
 	if err != nil {
-		logger.Print(err)
+		logging.FromContext(ctx).Error(err)
 	}
 
 	c := strings.Replace(string(b), "LOGIN", email, 1)
 	c = strings.Replace(c, "URL_CONTENT", *config.URL, 1)
 	c = strings.Replace(c, "PASSWORD", pw, 1)
 
-	send(c, adminSubject, email)
+	send(ctx, "admin", c, adminSubject, email)
 }
 
 // Leader sends an email to new leader with an url to set his password
-func Leader(token, email string) {
+func Leader(ctx context.Context, token, email string) {
 	b, err := ioutil.ReadFile("./__resources__/mails/leader.html") // just pass the file name
 
 	if err != nil {
-		logger.Print(err)
+		logging.FromContext(ctx).Error(err)
 	}
 
 	c := strings.Replace(string(b), "LOGIN", email, 1)
@@ -69,15 +92,15 @@ func Leader(token, email string) {
 	c = strings.Replace(c, "TOKEN", token, 1)
 	c = strings.Replace(c, "URL", *config.URL, 1)
 
-	send(c, leaderSubject, email)
+	send(ctx, "leader", c, leaderSubject, email)
 }
 
 // Share sends an email to user with url generated
-func Share(u *models.User, s *models.Share, c *models.Content, l *models.User) {
+func Share(ctx context.Context, u *models.User, s *models.Share, c *models.Content, l *models.User) {
 	b, err := ioutil.ReadFile("./__resources__/mails/share.html") // just pass the file name
 
 	if err != nil {
-		logger.Print(err)
+		logging.FromContext(ctx).Error(err)
 	}
 
 	cnt := strings.Replace(string(b), "URL_CONTENT", *config.URL, 1)
@@ -88,11 +111,7 @@ func Share(u *models.User, s *models.Share, c *models.Content, l *models.User) {
 	cnt = strings.Replace(cnt, "LASTNAME", l.Lastname, 2)
 	cnt = strings.Replace(cnt, "NAME", c.Name, 1)
 
-	if *config.Stub == 1 {
-		cnt = strings.Replace(cnt, "IMAGE", fmt.Sprintf("%s/public/%s.jpg", *config.URLContent, config.StubPath), 1)
-	} else {
-		cnt = strings.Replace(cnt, "IMAGE", fmt.Sprintf("%s/%d.jpg", *config.URLContent, s.ContentID), 1)
-	}
+	cnt = strings.Replace(cnt, "IMAGE", posterURL(c.Poster, time.Time(s.ExpirationDate)), 1)
 
 	date := time.Time(s.ExpirationDate).Format("01 02, 2006 at 03:04 pm")
 	cnt = strings.Replace(cnt, "DATE", date, 1)
@@ -112,10 +131,62 @@ func Share(u *models.User, s *models.Share, c *models.Content, l *models.User) {
 	subject := strings.Replace(shareSubject, "FIRSTNAME", l.Firstname, 2)
 	subject = strings.Replace(subject, "LASTNAME", l.Lastname, 2)
 
-	send(cnt, subject, u.Email)
+	send(ctx, "share", cnt, subject, u.Email)
+}
+
+// Playlist sends an email to user with the url of the playlist share.
+// The poster of the first content of the playlist is shown.
+func Playlist(ctx context.Context, u *models.User, s *models.PlaylistShare, p *models.Playlist, l *models.User) {
+	b, err := ioutil.ReadFile("./__resources__/mails/playlist.html") // just pass the file name
+
+	if err != nil {
+		logging.FromContext(ctx).Error(err)
+	}
+
+	key := ""
+
+	if len(p.Contents) > 0 {
+		key = p.Contents[0].Poster
+	}
+
+	img := posterURL(key, time.Time(s.ExpirationDate))
+
+	cnt := strings.Replace(string(b), "URL_CONTENT", *config.URL, 1)
+	cnt = strings.Replace(cnt, "URL", s.URL(), 1)
+	cnt = strings.Replace(cnt, "MESSAGE", html.EscapeString(s.Message), 1)
+	cnt = strings.Replace(cnt, "DESCRIPTION", html.EscapeString(p.Description), 1)
+	cnt = strings.Replace(cnt, "FIRSTNAME", l.Firstname, 2)
+	cnt = strings.Replace(cnt, "LASTNAME", l.Lastname, 2)
+	cnt = strings.Replace(cnt, "NAME", html.EscapeString(p.Name), 1)
+	cnt = strings.Replace(cnt, "IMAGE", img, 1)
+	cnt = strings.Replace(cnt, "COUNT", fmt.Sprintf("%d videos", len(p.Contents)), 1)
+
+	date := time.Time(s.ExpirationDate).Format("01 02, 2006 at 03:04 pm")
+	cnt = strings.Replace(cnt, "DATE", date, 1)
+
+	subject := strings.Replace(playlistSubject, "FIRSTNAME", l.Firstname, 1)
+	subject = strings.Replace(subject, "LASTNAME", l.Lastname, 1)
+
+	send(ctx, "playlist", cnt, subject, u.Email)
+}
+
+// Failed sends an email to the leader whose content could not be processed with the hub reason
+func Failed(ctx context.Context, c *models.Content, email string) {
+	b, err := ioutil.ReadFile("./__resources__/mails/failed.html") // just pass the file name
+
+	if err != nil {
+		logging.FromContext(ctx).Error(err)
+	}
+
+	cnt := strings.Replace(string(b), "URL_CONTENT", *config.URL, 1)
+	cnt = strings.Replace(cnt, "NAME", html.EscapeString(c.Name), 1)
+	cnt = strings.Replace(cnt, "REASON", html.EscapeString(c.FailureReason), 1)
+
+	send(ctx, "failed", cnt, failedSubject, email)
 }
 
-func send(content, subject, email string) {
+// send sends the mail then counts it in metrics with its kind
+func send(ctx context.Context, kind, content, subject, email string) {
 	from := mail.Address{Name: "n7", Address: *config.SMTPFrom}
 	to := mail.Address{Name: "", Address: email}
 
@@ -150,7 +221,12 @@ func send(content, subject, email string) {
 		[]byte(message),
 	)
 
+	metrics.Mail.WithLabelValues(kind, metrics.Outcome(err)).Inc()
+
 	if err != nil {
-		logger.Println(err)
+		logging.FromContext(ctx).Errorf("cannot send mail to %s: %v", email, err)
+		return
 	}
+
+	logging.FromContext(ctx).Debugf("mail %q sent to %s", subject, email)
 }
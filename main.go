@@ -1,37 +1,53 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/jwtauth"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/rs/cors"
+	"gitlab.com/arnaud-web/neli-webservices/api"
+	"gitlab.com/arnaud-web/neli-webservices/api/asset"
+	"gitlab.com/arnaud-web/neli-webservices/api/audit"
 	"gitlab.com/arnaud-web/neli-webservices/api/auth/bearer"
 	"gitlab.com/arnaud-web/neli-webservices/api/auth/credentials"
+	"gitlab.com/arnaud-web/neli-webservices/api/capture"
 	"gitlab.com/arnaud-web/neli-webservices/api/check"
 	"gitlab.com/arnaud-web/neli-webservices/api/content"
+	"gitlab.com/arnaud-web/neli-webservices/api/device"
+	"gitlab.com/arnaud-web/neli-webservices/api/health"
+	"gitlab.com/arnaud-web/neli-webservices/api/playlist"
+	"gitlab.com/arnaud-web/neli-webservices/api/quota"
 	"gitlab.com/arnaud-web/neli-webservices/api/user"
 	"gitlab.com/arnaud-web/neli-webservices/api/user/admin"
 	"gitlab.com/arnaud-web/neli-webservices/api/user/leader"
 	"gitlab.com/arnaud-web/neli-webservices/api/user/member"
+	"gitlab.com/arnaud-web/neli-webservices/background"
 	"gitlab.com/arnaud-web/neli-webservices/config"
-	"gitlab.com/arnaud-web/neli-webservices/api/capture"
-	)
-
-var logger = log.New(os.Stdout, "", log.LstdFlags|log.Lshortfile)
+	"gitlab.com/arnaud-web/neli-webservices/db/models"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
+	"gitlab.com/arnaud-web/neli-webservices/metrics"
+	"gitlab.com/arnaud-web/neli-webservices/storage"
+)
 
 func main() {
+	metrics.RegisterDB(models.Stats)
+
 	tokenAuth := jwtauth.New(bearer.TokenAlgorithm, []byte(*config.TokenSecret), nil)
 	refreshAuth := jwtauth.New(bearer.TokenAlgorithm, []byte(*config.RefreshSecret), nil)
 
 	cors := cors.New(cors.Options{
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "HEAD", "PATCH"},
 		AllowedHeaders:   []string{"*"},
+		ExposedHeaders:   []string{"Location", "Tus-Resumable", "Upload-Offset", "Upload-Length"},
 		AllowedOrigins:   []string{"*"},
 		AllowCredentials: true,
 	})
@@ -40,6 +56,8 @@ func main() {
 
 	a.Use(cors.Handler)
 
+	a.Use(api.Timeout)
+
 	a.Route("/", func(a chi.Router) {
 		a.Post("/login", credentials.Login)
 		a.Post("/set-password", credentials.Set)
@@ -56,109 +74,273 @@ func main() {
 
 	r.Use(cors.Handler)
 
-	r.Use(middleware.Logger)
+	r.Use(api.RealIP)
+
+	r.Use(middleware.RequestID)
+
+	r.Use(logging.Middleware)
+
+	r.Use(metrics.Middleware)
 
 	r.Mount("/auth", a)
 
-	fs := http.StripPrefix("/assets", http.FileServer(http.Dir(*config.AssetsPath)))
-	r.Get("/assets*", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path  == "/assets/" {
-			http.NotFound(w, r)
-			return
-		}
+	r.Method("GET", "/metrics", metrics.Handler())
+
+	r.Get("/healthz", health.Live)
+	r.Get("/readyz", health.Ready)
 
-		fs.ServeHTTP(w, r)
-	}))
+	r.Get(asset.Prefix+"/*", asset.Serve)
+	r.Head(asset.Prefix+"/*", asset.Serve)
+
+	r.With(api.Timeout).Get("/share/{memberId:[0-9]+}/video/{token}", content.SharedURL)
+	r.With(api.Timeout).Get("/share/{memberId:[0-9]+}/playlist/{token}", playlist.SharedURL)
 
 	r.Route("/", func(r chi.Router) {
 		r.Use(jwtauth.Verifier(tokenAuth))
 		r.Use(check.Authenticator)
 
-		r.Post("/change-password", user.Password)
+		// Long-lived requests, only bounded by the server header and idle timeouts
+		r.With(check.Leader).Patch("/video-content/{videoContentId:[0-9]+}/upload/{uploadId:[0-9]+}", content.PatchUpload)
+		r.With(check.Leader).Get("/capture/status/stream", capture.Stream)
 
-		r.Route("/users", func(r chi.Router) {
-			r.Get("/me", user.Get)
+		r.Group(func(r chi.Router) {
+			r.Use(api.Timeout)
 
-			r.Route("/leader", func(r chi.Router) {
-				r.Use(check.Admin)
-				r.Get("/", leader.List)
-				r.Post("/", leader.Create)
-			})
+			r.Post("/change-password", user.Password)
 
-			r.Route("/member", func(r chi.Router) {
-				r.Use(check.Leader)
-				r.Get("/", member.List)
-				r.Post("/", member.Create)
-			})
+			r.Route("/users", func(r chi.Router) {
+				r.Get("/me", user.Get)
 
-			r.Route("/administrator", func(r chi.Router) {
-				r.Use(check.SuperAdmin)
-				r.Get("/", admin.List)
-				r.Post("/", admin.Create)
-			})
+				r.Route("/leader", func(r chi.Router) {
+					r.Use(check.Admin)
+					r.Get("/", leader.List)
+					r.Post("/", leader.Create)
+				})
+
+				r.Route("/member", func(r chi.Router) {
+					r.Use(check.Leader)
+					r.Get("/", member.List)
+					r.Post("/", member.Create)
+				})
+
+				r.Route("/administrator", func(r chi.Router) {
+					r.Use(check.SuperAdmin)
+					r.Get("/", admin.List)
+					r.Post("/", admin.Create)
+				})
 
-			r.Route("/{userId:[0-9]+}", func(r chi.Router) {
-				r.Put("/", user.Edit)
-				r.Delete("/", user.Delete)
+				r.Route("/{userId:[0-9]+}", func(r chi.Router) {
+					r.Put("/", user.Edit)
+					r.Delete("/", user.Delete)
+				})
 			})
-		})
 
-		r.Route("/video-content", func(r chi.Router) {
+			r.Route("/video-content", func(r chi.Router) {
 
-			r.Get("/{videoContentId:[0-9]+}/share", content.Shares)
+				r.Get("/{videoContentId:[0-9]+}/share", content.Shares)
+				r.Get("/{videoContentId:[0-9]+}/url", content.URL)
+				r.Get("/{videoContentId:[0-9]+}/markers", content.Markers)
+				r.Get("/{videoContentId:[0-9]+}/comments", content.Comments)
+				r.Post("/{videoContentId:[0-9]+}/comments", content.AddComment)
+				r.Delete("/{videoContentId:[0-9]+}/comments/{commentId:[0-9]+}", content.DeleteComment)
 
-			r.Route("/max-duration", func(r chi.Router) {
-				r.Use(check.SuperAdmin)
-				r.Put("/", content.MaxDuration)
-				r.Get("/", content.GetMaxDuration)
-			})
+				r.Route("/max-duration", func(r chi.Router) {
+					r.Use(check.SuperAdmin)
+					r.Put("/", content.MaxDuration)
+					r.Get("/", content.GetMaxDuration)
+				})
 
-			r.Route("/all", func(r chi.Router) {
-				r.Use(check.Admin)
-				r.Get("/", content.All)
-			})
+				r.Route("/all", func(r chi.Router) {
+					r.Use(check.Admin)
+					r.Get("/", content.All)
+					r.Get("/tags", content.TagStats)
+				})
 
-			r.Route("/{videoContentId:[0-9]+}/ready", func(r chi.Router) {
-				r.Use(check.Hub)
-				r.Post("/", content.Ready)
-			})
+				r.Route("/trash", func(r chi.Router) {
+					r.Use(check.Admin)
+					r.Get("/", content.Trash)
+				})
 
-			r.Route("/", func(r chi.Router) {
-				r.Use(check.Leader)
-				r.Get("/", content.Get)
+				r.Route("/{videoContentId:[0-9]+}/restore", func(r chi.Router) {
+					r.Use(check.Admin)
+					r.Post("/", content.Restore)
+				})
+
+				r.Route("/{videoContentId:[0-9]+}/ready", func(r chi.Router) {
+					r.Use(check.Hub)
+					r.Post("/", content.Ready)
+				})
+
+				r.Route("/{videoContentId:[0-9]+}/failed", func(r chi.Router) {
+					r.Use(check.Hub)
+					r.Post("/", content.Failed)
+				})
 
-				r.Post("/{videoContentId:[0-9]+}/share/{userId:[0-9]+}", content.Share)
-				r.Post("/{videoContentId:[0-9]+}/share", content.MultipleShares)
+				r.Route("/reprocess", func(r chi.Router) {
+					r.Use(check.Hub)
+					r.Get("/", content.Reprocessing)
+				})
 
-				r.Post("/id", content.New)
+				r.Route("/", func(r chi.Router) {
+					r.Use(check.Leader)
+					r.Get("/", content.Get)
 
-				r.Put("/{videoContentId:[0-9]+}", content.Edit)
+					r.Post("/{videoContentId:[0-9]+}/share/{userId:[0-9]+}", content.Share)
+					r.Post("/{videoContentId:[0-9]+}/share", content.MultipleShares)
 
-				r.Delete("/{videoContentId:[0-9]+}", content.Delete)
+					r.Post("/id", content.New)
+
+					r.Put("/{videoContentId:[0-9]+}", content.Edit)
+					r.Post("/{videoContentId:[0-9]+}/reprocess", content.Reprocess)
+
+					r.Put("/{videoContentId:[0-9]+}/poster", content.Poster)
+
+					r.Get("/tags", content.Tags)
+					r.Post("/tags/share", content.ShareTag)
+					r.Put("/{videoContentId:[0-9]+}/tags", content.SetTags)
+
+					r.Post("/{videoContentId:[0-9]+}/markers", content.AddMarker)
+					r.Put("/{videoContentId:[0-9]+}/markers/{markerId:[0-9]+}", content.EditMarker)
+					r.Delete("/{videoContentId:[0-9]+}/markers/{markerId:[0-9]+}", content.DeleteMarker)
+
+					r.Post("/{videoContentId:[0-9]+}/upload", content.CreateUpload)
+					r.Head("/{videoContentId:[0-9]+}/upload/{uploadId:[0-9]+}", content.HeadUpload)
+
+					r.Delete("/{videoContentId:[0-9]+}", content.Delete)
+				})
+
+				r.Route("/cleaning", func(r chi.Router) {
+					r.Use(check.SuperAdmin)
+					r.Post("/", content.Cleaning)
+				})
 			})
 
-			r.Route("/cleaning", func(r chi.Router) {
+			r.Route("/playlists", func(r chi.Router) {
+				r.Get("/shared", playlist.Shared)
+				r.Get("/{playlistId:[0-9]+}", playlist.Get)
+
+				r.Route("/", func(r chi.Router) {
+					r.Use(check.Leader)
+					r.Get("/", playlist.List)
+					r.Post("/", playlist.Create)
+					r.Put("/{playlistId:[0-9]+}", playlist.Edit)
+					r.Delete("/{playlistId:[0-9]+}", playlist.Delete)
+					r.Get("/{playlistId:[0-9]+}/share", playlist.Shares)
+					r.Post("/{playlistId:[0-9]+}/share", playlist.Share)
+				})
+			})
+
+			r.Route("/max-duration", func(r chi.Router) {
 				r.Use(check.SuperAdmin)
-				r.Post("/", content.Cleaning)
+				r.Put("/", content.MaxDuration)
 			})
-		})
 
-		r.Route("/max-duration", func(r chi.Router) {
-			r.Use(check.SuperAdmin)
-			r.Put("/", content.MaxDuration)
-		})
+			r.Route("/quota", func(r chi.Router) {
+				r.Route("/default", func(r chi.Router) {
+					r.Use(check.SuperAdmin)
+					r.Get("/", quota.GetDefault)
+					r.Put("/", quota.SetDefault)
+				})
+
+				r.Route("/usage", func(r chi.Router) {
+					r.Use(check.Leader)
+					r.Get("/", quota.Usage)
+				})
+
+				r.Route("/{userId:[0-9]+}", func(r chi.Router) {
+					r.Get("/", quota.Get)
+					r.Put("/", quota.Set)
+					r.Get("/usage", quota.LeaderUsage)
+				})
+			})
 
-		r.Route("/capture", func(r chi.Router) {
-			r.Use(check.Leader)
-			r.Post("/play/{videoContentId:[0-9]+}", capture.Play)
-			r.Post("/stop", capture.Stop)
-			r.Get("/status", capture.Status)
+			r.Route("/audit", func(r chi.Router) {
+				r.Use(check.SuperAdmin)
+				r.Get("/", audit.List)
+			})
+
+			r.Route("/device", func(r chi.Router) {
+				r.Use(check.Admin)
+				r.Get("/", device.List)
+				r.Post("/", device.Create)
+				r.Get("/{deviceId:[0-9]+}", device.Get)
+				r.Put("/{deviceId:[0-9]+}", device.Edit)
+				r.Delete("/{deviceId:[0-9]+}", device.Delete)
+			})
+
+			r.Route("/capture", func(r chi.Router) {
+				r.Use(check.Leader)
+				r.Post("/play/{videoContentId:[0-9]+}", capture.Play)
+				r.Post("/stop", capture.Stop)
+				r.Get("/status", capture.Status)
+			})
 		})
 	})
-	log.Println(*config.AssetsPath)
+	logger := logging.Default()
+
+	if err := capture.CheckTransport(*config.ProxyTransport); err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+
+	if err := storage.CheckSecret(*config.AssetSecret); err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+
+	logger.Infof("storing assets with %s storage", *config.Storage)
+
+	if err := content.Placeholder(context.Background()); err != nil {
+		logger.Error(err)
+	}
+
+	if err := models.MarkTrashDeleted(); err != nil {
+		logger.Error(err)
+	}
+
+	// Request bodies and responses are bounded by api.Timeout,
+	// so that long-lived requests aren't cut by the server
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", *config.Port),
+		Handler:           r,
+		ReadHeaderTimeout: time.Duration(*config.ReadTimeout) * time.Second,
+		IdleTimeout:       time.Duration(*config.IdleTimeout) * time.Second,
+	}
+
+	go func() {
+		logger.Infof("running server on port %d", *config.Port)
+
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			logger.Error(err)
+			os.Exit(1)
+		}
+	}()
+
+	shutdown(srv)
+}
+
+// shutdown waits for SIGINT or SIGTERM then stops accepting connections.
+// In flight requests then background work like mails are drained
+// until shutdown timeout.
+func shutdown(srv *http.Server) {
+	logger := logging.Default()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+
+	logger.Infof("received %s, shutting down", <-sig)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(*config.ShutdownTimeout)*time.Second)
+	defer cancel()
 
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Errorf("cannot drain requests: %v", err)
+	}
 
-	logger.Println("Running server on port", *config.Port)
+	if err := background.Wait(ctx); err != nil {
+		logger.Errorf("cannot drain background work: %v", err)
+		return
+	}
 
-	fmt.Println(http.ListenAndServe(fmt.Sprintf(":%d", *config.Port), r))
+	logger.Info("server stopped")
 }
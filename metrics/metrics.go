@@ -0,0 +1,176 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gitlab.com/arnaud-web/neli-webservices/config"
+)
+
+const namespace = "neli"
+
+// Outcomes used as label values
+const (
+	Success = "success"
+	Failure = "failure"
+)
+
+var (
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of HTTP requests by chi route pattern.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// Capture counts the results of orders sent to the capture proxy.
+	// Result is the name of the PLAY*, STOP* or STATUS* code.
+	Capture = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "capture_results_total",
+		Help:      "Results of capture orders sent to the proxy.",
+	}, []string{"order", "result"})
+
+	// Mail counts mails sent by kind (reset, admin, leader, share) and outcome
+	Mail = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mail_sent_total",
+		Help:      "Mails sent by kind and outcome.",
+	}, []string{"kind", "outcome"})
+
+	// Share counts shares created by leaders
+	Share = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "share_created_total",
+		Help:      "Shares created by leaders.",
+	})
+
+	// Ready counts ready callbacks received from the hub by outcome
+	Ready = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "hub_ready_total",
+		Help:      "Ready callbacks received from the hub.",
+	}, []string{"outcome"})
+)
+
+func init() {
+	prometheus.MustRegister(httpDuration, Capture, Mail, Share, Ready)
+}
+
+// Outcome returns the outcome label matching err
+func Outcome(err error) string {
+	if err != nil {
+		return Failure
+	}
+
+	return Success
+}
+
+// RegisterDB exposes the connection pool statistics returned by stats
+func RegisterDB(stats func() sql.DBStats) {
+	prometheus.MustRegister(&dbCollector{stats: stats})
+}
+
+// Handler serves the metrics in prometheus text format to the scrapers
+// sending the metrics token as bearer token.
+// Metrics are not served when no token is configured.
+func Handler() http.Handler {
+	h := promhttp.Handler()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t := *config.MetricsToken
+
+		if t == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+t)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Middleware observes the duration of each request.
+// The route label is the chi route pattern so that url params
+// like content ids don't create a serie per value.
+// Requests not matching any route are labelled as "unknown".
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		t := time.Now()
+
+		next.ServeHTTP(ww, r)
+
+		route := "unknown"
+
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		status := ww.Status()
+
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		httpDuration.WithLabelValues(r.Method, route, strconv.Itoa(status)).Observe(time.Since(t).Seconds())
+	})
+}
+
+type dbCollector struct {
+	stats func() sql.DBStats
+}
+
+var (
+	dbOpen = prometheus.NewDesc(namespace+"_db_open_connections",
+		"Established connections both in use and idle.", nil, nil)
+	dbInUse = prometheus.NewDesc(namespace+"_db_in_use_connections",
+		"Connections currently in use.", nil, nil)
+	dbIdle = prometheus.NewDesc(namespace+"_db_idle_connections",
+		"Idle connections.", nil, nil)
+	dbWaitCount = prometheus.NewDesc(namespace+"_db_wait_count_total",
+		"Connections waited for.", nil, nil)
+	dbWaitDuration = prometheus.NewDesc(namespace+"_db_wait_duration_seconds_total",
+		"Time blocked waiting for a new connection.", nil, nil)
+	dbMaxIdleClosed = prometheus.NewDesc(namespace+"_db_max_idle_closed_total",
+		"Connections closed due to max idle limit.", nil, nil)
+	dbMaxLifetimeClosed = prometheus.NewDesc(namespace+"_db_max_lifetime_closed_total",
+		"Connections closed due to max lifetime limit.", nil, nil)
+)
+
+// Describe implements prometheus.Collector
+func (c *dbCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- dbOpen
+	ch <- dbInUse
+	ch <- dbIdle
+	ch <- dbWaitCount
+	ch <- dbWaitDuration
+	ch <- dbMaxIdleClosed
+	ch <- dbMaxLifetimeClosed
+}
+
+// Collect implements prometheus.Collector.
+// Stats are read on each scrape.
+func (c *dbCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stats()
+
+	ch <- prometheus.MustNewConstMetric(dbOpen, prometheus.GaugeValue, float64(s.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(dbInUse, prometheus.GaugeValue, float64(s.InUse))
+	ch <- prometheus.MustNewConstMetric(dbIdle, prometheus.GaugeValue, float64(s.Idle))
+	ch <- prometheus.MustNewConstMetric(dbWaitCount, prometheus.CounterValue, float64(s.WaitCount))
+	ch <- prometheus.MustNewConstMetric(dbWaitDuration, prometheus.CounterValue, s.WaitDuration.Seconds())
+	ch <- prometheus.MustNewConstMetric(dbMaxIdleClosed, prometheus.CounterValue, float64(s.MaxIdleClosed))
+	ch <- prometheus.MustNewConstMetric(dbMaxLifetimeClosed, prometheus.CounterValue, float64(s.MaxLifetimeClosed))
+}
@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/prometheus/client_golang/prometheus"
+	"gitlab.com/arnaud-web/neli-webservices/config"
+	"gitlab.com/arnaud-web/neli-webservices/test/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/video-content/{videoContentId:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	req, _ := http.NewRequest("GET", "/video-content/42", nil)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	mfs, err := prometheus.DefaultGatherer.Gather()
+	assert.NoError(t, err)
+
+	for _, mf := range mfs {
+		if mf.GetName() != "neli_http_request_duration_seconds" {
+			continue
+		}
+
+		for _, m := range mf.GetMetric() {
+			l := map[string]string{}
+
+			for _, lp := range m.GetLabel() {
+				l[lp.GetName()] = lp.GetValue()
+			}
+
+			if l["route"] == "/video-content/{videoContentId:[0-9]+}" && l["status"] == "204" {
+				return
+			}
+		}
+	}
+
+	t.Fatal("Expected an observation for the route pattern")
+}
+
+func TestOutcome(t *testing.T) {
+	assert.Equals(t, Outcome(nil), Success)
+	assert.Equals(t, Outcome(errors.New("smtp")), Failure)
+}
+
+func TestDBCollector(t *testing.T) {
+	c := &dbCollector{stats: func() sql.DBStats {
+		return sql.DBStats{OpenConnections: 3, InUse: 2, Idle: 1}
+	}}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+
+	mfs, err := reg.Gather()
+	assert.NoError(t, err)
+
+	if len(mfs) != 7 {
+		t.Fatalf("Expected 7 metrics, got %d", len(mfs))
+	}
+}
+
+func TestHandler(t *testing.T) {
+	defer func(s string) { *config.MetricsToken = s }(*config.MetricsToken)
+	*config.MetricsToken = "s3cr3t"
+
+	Share.Inc()
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer s3cr3t")
+
+	Handler().ServeHTTP(rr, req)
+
+	assert.Response(t, rr, http.StatusOK, "")
+	assert.Contains(t, rr.Body.String(), "neli_share_created_total")
+}
+
+func TestHandler_Token(t *testing.T) {
+	defer func(s string) { *config.MetricsToken = s }(*config.MetricsToken)
+
+	for _, c := range []struct {
+		token, auth string
+		status      int
+	}{
+		{"", "Bearer ", http.StatusNotFound},
+		{"s3cr3t", "", http.StatusUnauthorized},
+		{"s3cr3t", "Bearer wrong", http.StatusUnauthorized},
+		{"s3cr3t", "Bearer s3cr3t", http.StatusOK},
+	} {
+		*config.MetricsToken = c.token
+
+		req, _ := http.NewRequest("GET", "/metrics", nil)
+		req.Header.Set("Authorization", c.auth)
+		rr := httptest.NewRecorder()
+
+		Handler().ServeHTTP(rr, req)
+
+		if rr.Code != c.status {
+			t.Errorf("Expected %d with token %q and authorization %q, got %d", c.status, c.token, c.auth, rr.Code)
+		}
+	}
+}
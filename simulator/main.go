@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"gitlab.com/arnaud-web/neli-webservices/api/capture/simulator"
+	"gitlab.com/arnaud-web/neli-webservices/config"
+	"gitlab.com/arnaud-web/neli-webservices/logging"
+)
+
+// The simulator replaces the bluetooth proxy for local development.
+// Point the webservices proxy setting to simulator_addr.
+// The fault mode can be changed at runtime:
+//
+//	curl -X PUT 'localhost:2001/fault?mode=nobt'
+//	curl -X PUT 'localhost:2001/fault?mode='
+func main() {
+	logger := logging.Default()
+	s := simulator.New()
+
+	if err := setFault(s, *config.SimulatorFault); err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+
+	if *config.SimulatorControl != "" {
+		go func() {
+			logger.Infof("simulator control on %s", *config.SimulatorControl)
+			logger.Error(http.ListenAndServe(*config.SimulatorControl, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				control(s, w, r)
+			})))
+		}()
+	}
+
+	logger.Infof("simulator listening on udp %s", *config.SimulatorAddr)
+	logger.Error(s.ListenAndServe(context.Background(), *config.SimulatorAddr))
+	os.Exit(1)
+}
+
+// control reads or changes the fault mode on /fault
+func control(s *simulator.Simulator, w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/fault" {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+	case "PUT":
+		if err := setFault(s, r.URL.Query().Get("mode")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	fmt.Fprintf(w, "fault=%q\n", s.Fault())
+}
+
+func setFault(s *simulator.Simulator, f string) error {
+	for _, v := range simulator.Faults {
+		if v == f {
+			s.SetFault(f)
+			logging.Default().Infof("fault mode %q", f)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown fault mode %q", f)
+}
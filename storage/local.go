@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// LocalPrefix is the url prefix of the assets served from the local storage
+const LocalPrefix = "/assets"
+
+// Local stores the assets in a directory.
+// Its urls are signed by the service which serves them.
+type Local struct {
+	Dir string
+}
+
+// file returns the file of the key, which can't leave the directory
+func (l Local) file(key string) string {
+	return filepath.Join(l.Dir, filepath.FromSlash(path.Clean("/"+key)))
+}
+
+// Put writes the asset aside then renames it so it is never read partially
+func (l Local) Put(ctx context.Context, key string, r io.Reader) error {
+	name := l.file(key)
+
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(name), ".put")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(f.Name())
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), name)
+}
+
+// Get opens the file of the asset, directories are never opened
+func (l Local) Get(ctx context.Context, key string) (Object, Info, error) {
+	f, err := os.Open(l.file(key))
+
+	if os.IsNotExist(err) {
+		return nil, Info{}, ErrNotExist
+	}
+
+	if err != nil {
+		return nil, Info{}, err
+	}
+
+	i, err := f.Stat()
+
+	if err != nil {
+		f.Close()
+		return nil, Info{}, err
+	}
+
+	if i.IsDir() {
+		f.Close()
+		return nil, Info{}, ErrNotExist
+	}
+
+	return f, Info{Size: i.Size(), ModTime: i.ModTime()}, nil
+}
+
+// Delete removes the file of the asset and its directory once empty,
+// like a video directory
+func (l Local) Delete(ctx context.Context, key string) error {
+	name := l.file(key)
+	err := os.Remove(name)
+
+	if os.IsNotExist(err) {
+		return ErrNotExist
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if d := filepath.Dir(name); d != filepath.Clean(l.Dir) {
+		// The directory is kept when other assets are inside
+		os.Remove(d)
+	}
+
+	return nil
+}
+
+// Stat returns the size and modification time of the file of the asset
+func (l Local) Stat(ctx context.Context, key string) (Info, error) {
+	i, err := os.Stat(l.file(key))
+
+	if os.IsNotExist(err) {
+		return Info{}, ErrNotExist
+	}
+
+	if err != nil {
+		return Info{}, err
+	}
+
+	if i.IsDir() {
+		return Info{}, ErrNotExist
+	}
+
+	return Info{Size: i.Size(), ModTime: i.ModTime()}, nil
+}
+
+// SignedURL returns the url of the asset served under LocalPrefix
+// with its signature
+func (l Local) SignedURL(key string, expires time.Time) (string, error) {
+	return ServedURL(key, expires), nil
+}
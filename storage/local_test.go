@@ -0,0 +1,86 @@
+package storage_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/arnaud-web/neli-webservices/storage"
+	"gitlab.com/arnaud-web/neli-webservices/test/assert"
+)
+
+func TestLocal(t *testing.T) {
+	d, err := ioutil.TempDir("", "assets")
+	assert.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	l := storage.Local{Dir: d}
+	ctx := context.Background()
+
+	assert.NoError(t, l.Put(ctx, "abcdef/abcdef.mp4", strings.NewReader("0123456789")))
+
+	i, err := l.Stat(ctx, "abcdef/abcdef.mp4")
+	assert.NoError(t, err)
+	assert.Equals(t, strconv.FormatInt(i.Size, 10), "10")
+
+	o, _, err := l.Get(ctx, "abcdef/abcdef.mp4")
+	assert.NoError(t, err)
+	p, err := ioutil.ReadAll(o)
+	o.Close()
+	assert.NoError(t, err)
+	assert.Equals(t, string(p), "0123456789")
+
+	if _, _, err := l.Get(ctx, "abcdef"); err != storage.ErrNotExist {
+		t.Fatalf("Expected storage.ErrNotExist for a directory, got %v", err)
+	}
+
+	assert.NoError(t, l.Delete(ctx, "abcdef/abcdef.mp4"))
+
+	if _, err := os.Stat(filepath.Join(d, "abcdef")); !os.IsNotExist(err) {
+		t.Fatalf("Expected the video directory to be removed, got %v", err)
+	}
+
+	if err := l.Delete(ctx, "abcdef/abcdef.mp4"); err != storage.ErrNotExist {
+		t.Fatalf("Expected storage.ErrNotExist, got %v", err)
+	}
+}
+
+func TestLocal_Escape(t *testing.T) {
+	d, err := ioutil.TempDir("", "assets")
+	assert.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	l := storage.Local{Dir: filepath.Join(d, "assets")}
+
+	assert.NoError(t, l.Put(context.Background(), "../outside.jpg", strings.NewReader("jpeg")))
+
+	if _, err := os.Stat(filepath.Join(d, "assets", "outside.jpg")); err != nil {
+		t.Fatalf("Expected the asset to stay in the directory, got %v", err)
+	}
+}
+
+func TestLocal_SignedURL(t *testing.T) {
+	e := time.Now().Add(time.Minute)
+	s, err := storage.Local{}.SignedURL("abcdef/abcdef.mp4", e)
+	assert.NoError(t, err)
+
+	u, err := url.Parse(s)
+	assert.NoError(t, err)
+	assert.Equals(t, u.Path, storage.LocalPrefix+"/abcdef/abcdef.mp4")
+
+	x, _ := strconv.ParseInt(u.Query().Get("expires"), 10, 64)
+
+	if !storage.Verify("/abcdef/abcdef.mp4", x, u.Query().Get("signature")) {
+		t.Fatal("Expected a valid signature")
+	}
+
+	if storage.Verify("/abcdef/other.mp4", x, u.Query().Get("signature")) {
+		t.Fatal("Expected an invalid signature for another key")
+	}
+}
@@ -0,0 +1,433 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signature version 4 constants of the S3 api
+const (
+	algorithm       = "AWS4-HMAC-SHA256"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	// emptyPayload is the SHA256 of an empty body
+	emptyPayload = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	amzDate      = "20060102T150405Z"
+)
+
+// putRate is the slowest upload rate to the server in bytes per second,
+// the deadline of a Put grows with the size of its object at this rate
+const putRate = 1 << 20
+
+// S3 stores the assets in a bucket of a S3 compatible server, like MinIO.
+// Buckets are addressed in the path so any endpoint works.
+// Its urls are presigned for the server, which serves them.
+// Timeout bounds the requests but the reads of the objects, which last
+// as long as their stream, 0 meaning unbounded.
+type S3 struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Client    *http.Client
+	Timeout   time.Duration
+}
+
+// deadline returns the context of a request bounded by Timeout
+// plus the time to send size bytes at putRate
+func (s *S3) deadline(ctx context.Context, size int64) (context.Context, context.CancelFunc) {
+	if s.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, s.Timeout+time.Duration(size)*time.Second/putRate)
+}
+
+// url returns the url of the object of the key
+func (s *S3) url(key string) *url.URL {
+	u, _ := url.Parse(strings.TrimSuffix(s.Endpoint, "/"))
+	u.Path += "/" + s.Bucket + "/" + strings.TrimPrefix(key, "/")
+	return u
+}
+
+// do signs then sends the request on the object of the key,
+// size is the length of the body if any
+func (s *S3) do(ctx context.Context, method, key string, body io.Reader, size int64, h http.Header) (*http.Response, error) {
+	r, err := http.NewRequest(method, s.url(key).String(), body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r.ContentLength = size
+
+	for k, v := range h {
+		r.Header[k] = v
+	}
+
+	payload := emptyPayload
+
+	if body != nil {
+		payload = unsignedPayload
+	}
+
+	s.sign(r, payload, time.Now())
+
+	return s.Client.Do(r.WithContext(ctx))
+}
+
+// check returns an error for an unsuccessful response, which is closed
+func check(res *http.Response) error {
+	if res.StatusCode < 300 {
+		return nil
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return ErrNotExist
+	}
+
+	b, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+	return fmt.Errorf("s3 %s: %s", res.Status, b)
+}
+
+// Put uploads the object.
+// S3 needs its length so r is read in memory unless it can be sought, like a file.
+func (s *S3) Put(ctx context.Context, key string, r io.Reader) error {
+	rs, ok := r.(io.ReadSeeker)
+
+	if !ok {
+		b, err := ioutil.ReadAll(r)
+
+		if err != nil {
+			return err
+		}
+
+		rs = bytes.NewReader(b)
+	}
+
+	start, err := rs.Seek(0, io.SeekCurrent)
+
+	if err != nil {
+		return err
+	}
+
+	end, err := rs.Seek(0, io.SeekEnd)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := rs.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+
+	var body io.Reader
+
+	// An empty body would be sent chunked
+	if end > start {
+		body = ioutil.NopCloser(rs)
+	}
+
+	ctx, cancel := s.deadline(ctx, end-start)
+	defer cancel()
+
+	res, err := s.do(ctx, http.MethodPut, key, body, end-start, nil)
+
+	if err != nil {
+		return err
+	}
+
+	if err := check(res); err != nil {
+		return err
+	}
+
+	return res.Body.Close()
+}
+
+// Get returns the object, each seek starts a new range request
+func (s *S3) Get(ctx context.Context, key string) (Object, Info, error) {
+	i, err := s.Stat(ctx, key)
+
+	if err != nil {
+		return nil, Info{}, err
+	}
+
+	return &s3Object{s: s, ctx: ctx, key: key, size: i.Size}, i, nil
+}
+
+// Delete removes the object.
+// S3 doesn't tell missing objects apart so it is checked first.
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if _, err := s.Stat(ctx, key); err != nil {
+		return err
+	}
+
+	ctx, cancel := s.deadline(ctx, 0)
+	defer cancel()
+
+	res, err := s.do(ctx, http.MethodDelete, key, nil, 0, nil)
+
+	if err != nil {
+		return err
+	}
+
+	if err := check(res); err != nil {
+		return err
+	}
+
+	return res.Body.Close()
+}
+
+// Stat returns the size and modification time of the object
+func (s *S3) Stat(ctx context.Context, key string) (Info, error) {
+	ctx, cancel := s.deadline(ctx, 0)
+	defer cancel()
+
+	res, err := s.do(ctx, http.MethodHead, key, nil, 0, nil)
+
+	if err != nil {
+		return Info{}, err
+	}
+
+	if err := check(res); err != nil {
+		return Info{}, err
+	}
+
+	res.Body.Close()
+
+	t, _ := http.ParseTime(res.Header.Get("Last-Modified"))
+
+	return Info{Size: res.ContentLength, ModTime: t}, nil
+}
+
+// SignedURL returns the presigned url of the object
+func (s *S3) SignedURL(key string, expires time.Time) (string, error) {
+	t := time.Now()
+	d := expires.Sub(t)
+
+	if d <= 0 {
+		return "", errors.New("signed url already expired")
+	}
+
+	return s.presign(s.url(key), t, d), nil
+}
+
+// presign returns the url with the query signature for a GET request
+func (s *S3) presign(u *url.URL, t time.Time, d time.Duration) string {
+	t = t.UTC()
+	scope := s.scope(t)
+
+	q := u.Query()
+	q.Set("X-Amz-Algorithm", algorithm)
+	q.Set("X-Amz-Credential", s.AccessKey+"/"+scope)
+	q.Set("X-Amz-Date", t.Format(amzDate))
+	q.Set("X-Amz-Expires", strconv.Itoa(int(d/time.Second)))
+	q.Set("X-Amz-SignedHeaders", "host")
+
+	canonical := strings.Join([]string{
+		http.MethodGet,
+		escape(u.Path, false),
+		query(q),
+		"host:" + u.Host + "\n",
+		"host",
+		unsignedPayload,
+	}, "\n")
+
+	q.Set("X-Amz-Signature", s.signature(t, scope, canonical))
+
+	v := *u
+	v.RawQuery = query(q)
+	return v.String()
+}
+
+// sign adds the authorization header of the request
+func (s *S3) sign(r *http.Request, payload string, t time.Time) {
+	t = t.UTC()
+	scope := s.scope(t)
+
+	r.Header.Set("X-Amz-Date", t.Format(amzDate))
+	r.Header.Set("X-Amz-Content-Sha256", payload)
+
+	h := map[string]string{"host": r.URL.Host}
+
+	for k, v := range r.Header {
+		if k = strings.ToLower(k); strings.HasPrefix(k, "x-amz-") || k == "range" || k == "content-type" {
+			h[k] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+
+	names := make([]string, 0, len(h))
+
+	for k := range h {
+		names = append(names, k)
+	}
+
+	sort.Strings(names)
+
+	headers := ""
+
+	for _, k := range names {
+		headers += k + ":" + h[k] + "\n"
+	}
+
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		r.Method,
+		escape(r.URL.Path, false),
+		query(r.URL.Query()),
+		headers,
+		signed,
+		payload,
+	}, "\n")
+
+	r.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, s.AccessKey, scope, signed, s.signature(t, scope, canonical)))
+}
+
+// scope returns the credential scope of the day
+func (s *S3) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + s.Region + "/s3/aws4_request"
+}
+
+// signature signs the canonical request with the key derived for the scope
+func (s *S3) signature(t time.Time, scope, canonical string) string {
+	h := sha256.Sum256([]byte(canonical))
+	str := strings.Join([]string{algorithm, t.Format(amzDate), scope, hex.EncodeToString(h[:])}, "\n")
+
+	k := mac([]byte("AWS4"+s.SecretKey), t.Format("20060102"))
+	k = mac(k, s.Region)
+	k = mac(k, "s3")
+	k = mac(k, "aws4_request")
+
+	return hex.EncodeToString(mac(k, str))
+}
+
+func mac(k []byte, s string) []byte {
+	m := hmac.New(sha256.New, k)
+	m.Write([]byte(s))
+	return m.Sum(nil)
+}
+
+// query returns the canonical query string, sorted and escaped
+func query(q url.Values) string {
+	keys := make([]string, 0, len(q))
+
+	for k := range q {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	l := []string{}
+
+	for _, k := range keys {
+		for _, v := range q[k] {
+			l = append(l, escape(k, true)+"="+escape(v, true))
+		}
+	}
+
+	return strings.Join(l, "&")
+}
+
+// escape encodes all but unreserved characters as required by the signature,
+// slashes are kept in paths
+func escape(s string, slash bool) string {
+	b := strings.Builder{}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !slash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+// s3Object reads an object from an offset with a range request
+type s3Object struct {
+	s    *S3
+	ctx  context.Context
+	key  string
+	size int64
+	off  int64
+	body io.ReadCloser
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.off >= o.size {
+		return 0, io.EOF
+	}
+
+	if o.body == nil {
+		h := http.Header{"Range": {fmt.Sprintf("bytes=%d-", o.off)}}
+		res, err := o.s.do(o.ctx, http.MethodGet, o.key, nil, 0, h)
+
+		if err != nil {
+			return 0, err
+		}
+
+		if err := check(res); err != nil {
+			return 0, err
+		}
+
+		o.body = res.Body
+	}
+
+	n, err := o.body.Read(p)
+	o.off += int64(n)
+	return n, err
+}
+
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += o.off
+	case io.SeekEnd:
+		offset += o.size
+	}
+
+	if offset < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	if offset != o.off {
+		o.Close()
+		o.off = offset
+	}
+
+	return o.off, nil
+}
+
+func (o *s3Object) Close() error {
+	if o.body == nil {
+		return nil
+	}
+
+	err := o.body.Close()
+	o.body = nil
+	return err
+}
@@ -0,0 +1,140 @@
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.com/arnaud-web/neli-webservices/storage"
+	"gitlab.com/arnaud-web/neli-webservices/test/assert"
+)
+
+// bucket is an in memory S3 server standing in for MinIO
+type bucket struct {
+	sync.Mutex
+	objects map[string][]byte
+	// latency delays each response
+	latency time.Duration
+}
+
+func (b *bucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	time.Sleep(b.latency)
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	o, ok := b.objects[r.URL.Path]
+
+	switch r.Method {
+	case http.MethodPut:
+		b.objects[r.URL.Path], _ = ioutil.ReadAll(r.Body)
+	case http.MethodDelete:
+		delete(b.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodGet, http.MethodHead:
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		http.ServeContent(w, r, "", time.Now(), bytes.NewReader(o))
+	}
+}
+
+// s3 returns a storage on a fake server, closed by the returned function
+func s3(b *bucket) (*storage.S3, func()) {
+	srv := httptest.NewServer(b)
+
+	return &storage.S3{
+		Endpoint:  srv.URL,
+		Region:    "us-east-1",
+		Bucket:    "neli",
+		AccessKey: "access",
+		SecretKey: "secret",
+		Client:    srv.Client(),
+	}, srv.Close
+}
+
+func TestS3(t *testing.T) {
+	b := &bucket{objects: map[string][]byte{}}
+	s, done := s3(b)
+	defer done()
+
+	ctx := context.Background()
+
+	assert.NoError(t, s.Put(ctx, "abcdef/abcdef.mp4", strings.NewReader("0123456789")))
+	assert.Equals(t, string(b.objects["/neli/abcdef/abcdef.mp4"]), "0123456789")
+
+	i, err := s.Stat(ctx, "abcdef/abcdef.mp4")
+	assert.NoError(t, err)
+	assert.Equals(t, strconv.FormatInt(i.Size, 10), "10")
+
+	o, _, err := s.Get(ctx, "abcdef/abcdef.mp4")
+	assert.NoError(t, err)
+	defer o.Close()
+
+	_, err = o.Seek(4, io.SeekStart)
+	assert.NoError(t, err)
+
+	p, err := ioutil.ReadAll(o)
+	assert.NoError(t, err)
+	assert.Equals(t, string(p), "456789")
+
+	assert.NoError(t, s.Delete(ctx, "abcdef/abcdef.mp4"))
+
+	if _, err := s.Stat(ctx, "abcdef/abcdef.mp4"); err != storage.ErrNotExist {
+		t.Fatalf("Expected ErrNotExist, got %v", err)
+	}
+
+	if err := s.Delete(ctx, "abcdef/abcdef.mp4"); err != storage.ErrNotExist {
+		t.Fatalf("Expected ErrNotExist, got %v", err)
+	}
+}
+
+func TestS3_Empty(t *testing.T) {
+	b := &bucket{objects: map[string][]byte{}}
+	s, done := s3(b)
+	defer done()
+
+	assert.NoError(t, s.Put(context.Background(), "public/empty.jpg", bytes.NewReader(nil)))
+
+	if _, ok := b.objects["/neli/public/empty.jpg"]; !ok {
+		t.Fatal("Expected the empty object to be stored")
+	}
+}
+
+func TestS3_SignedURLExpired(t *testing.T) {
+	s := &storage.S3{Endpoint: "http://localhost:9000", Bucket: "neli"}
+
+	if _, err := s.SignedURL("abcdef/abcdef.mp4", time.Now().Add(-time.Second)); err == nil {
+		t.Fatal("Expected an error for an expired url")
+	}
+}
+
+func TestS3_Timeout(t *testing.T) {
+	b := &bucket{objects: map[string][]byte{}, latency: 100 * time.Millisecond}
+	s, done := s3(b)
+	defer done()
+
+	s.Timeout = 50 * time.Millisecond
+
+	if _, err := s.Stat(context.Background(), "abcdef/abcdef.mp4"); err == nil || err == storage.ErrNotExist {
+		t.Fatalf("Expected a timeout, got %v", err)
+	}
+
+	// The deadline of an upload grows with its size
+	assert.NoError(t, s.Put(context.Background(), "abcdef/abcdef.mp4", bytes.NewReader(make([]byte, 1<<20))))
+}
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"gitlab.com/arnaud-web/neli-webservices/config"
+)
+
+// Storage keeps the assets: videos and posters.
+// Keys are slash separated paths like path/path.mp4 or public/42.jpg.
+type Storage interface {
+	// Put stores the content read from r under key, replacing any previous one
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Get opens the asset for reading, seeking is supported for range requests
+	Get(ctx context.Context, key string) (Object, Info, error)
+	// Delete removes the asset, ErrNotExist is returned when it is missing
+	Delete(ctx context.Context, key string) error
+	// Stat returns the size and modification time of the asset
+	Stat(ctx context.Context, key string) (Info, error)
+	// SignedURL returns an url giving access to the asset until expires
+	SignedURL(key string, expires time.Time) (string, error)
+}
+
+// Object is an opened asset
+type Object interface {
+	io.ReadSeeker
+	io.Closer
+}
+
+// Info describes an asset
+type Info struct {
+	Size    int64
+	ModTime time.Time
+}
+
+// ErrNotExist is returned when an asset doesn't exist
+var ErrNotExist = errors.New("asset does not exist")
+
+// Backends of the storage setting
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+// Default is the configured storage, replaceable for testing
+var Default Storage
+
+// init creates the configured storage
+func init() {
+	s, err := New(*config.Storage)
+
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	Default = s
+}
+
+// New returns the storage of the backend, configured from settings
+func New(backend string) (Storage, error) {
+	switch backend {
+	case BackendLocal:
+		return Local{Dir: *config.AssetsPath}, nil
+	case BackendS3:
+		t := time.Duration(*config.S3Timeout) * time.Second
+
+		// The client isn't bounded as a whole, reading an object lasts
+		// as long as its stream and a Put is bounded by its size
+		return &S3{
+			Endpoint:  *config.S3Endpoint,
+			Region:    *config.S3Region,
+			Bucket:    *config.S3Bucket,
+			AccessKey: *config.S3AccessKey,
+			SecretKey: *config.S3SecretKey,
+			Client: &http.Client{Transport: &http.Transport{
+				Proxy:                 http.ProxyFromEnvironment,
+				TLSHandshakeTimeout:   t,
+				ResponseHeaderTimeout: t,
+				IdleConnTimeout:       90 * time.Second,
+			}},
+			Timeout: t,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown storage %q", backend)
+}
+
+// exampleSecret is the asset secret once shipped in docker.ini.example,
+// anybody can sign urls with it
+const exampleSecret = "7Gk!q2@Lw#zR"
+
+// CheckSecret returns an error when the asset secret is empty or the example one
+func CheckSecret(secret string) error {
+	if secret == "" || secret == exampleSecret {
+		return errors.New("asset_secret has to be set to a private value")
+	}
+
+	return nil
+}
+
+// signature returns the HMAC of the key and the expiry of a local asset url
+func signature(key string, expires int64) string {
+	m := hmac.New(sha256.New, []byte(*config.AssetSecret))
+	fmt.Fprintf(m, "%s\n%d", key, expires)
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// ServedURL returns the signed url of the asset served by the service
+// under LocalPrefix, whatever the backend
+func ServedURL(key string, expires time.Time) string {
+	key = path.Clean("/" + key)
+	u := url.URL{Path: LocalPrefix + key}
+	q := url.Values{}
+	q.Set("expires", fmt.Sprintf("%d", expires.Unix()))
+	q.Set("signature", signature(key, expires.Unix()))
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// Verify checks the signature of a local asset url and its expiry
+func Verify(key string, expires int64, sig string) bool {
+	if time.Now().Unix() > expires {
+		return false
+	}
+
+	return hmac.Equal([]byte(sig), []byte(signature(key, expires)))
+}
@@ -1,6 +1,8 @@
 package expect
 
 import (
+	"database/sql/driver"
+	"encoding/json"
 	"errors"
 	"math/rand"
 	"time"
@@ -17,6 +19,9 @@ func AdminInsert(u models.User, mock sqlmock.Sqlmock) {
 
 func LeaderInsert(u models.User, mock sqlmock.Sqlmock) {
 	userInsert(u, mock)
+	mock.ExpectExec("UPDATE user SET admin_id (.+)").
+		WithArgs(sqlmock.AnyArg(), u.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
 }
 
 func MemberInsert(u models.User, mock sqlmock.Sqlmock) {
@@ -133,7 +138,14 @@ func Settings(mock sqlmock.Sqlmock) *sqlmock.ExpectedQuery {
 
 func Content(lid int64, mock sqlmock.Sqlmock) int64 {
 	id := int64(rand.Uint64())
-	rows := sqlmock.NewRows([]string{"id"}).AddRow(id)
+	rows := sqlmock.NewRows([]string{"id", "status"}).AddRow(id, models.ContentCreated)
+	mock.ExpectQuery("SELECT (.+) FROM video_content (.+)").WithArgs(id, lid).WillReturnRows(rows)
+	return id
+}
+
+func ContentRecorded(lid int64, mock sqlmock.Sqlmock) int64 {
+	id := int64(rand.Uint64())
+	rows := sqlmock.NewRows([]string{"id", "path", "status"}).AddRow(id, "abcdef", models.ContentReady)
 	mock.ExpectQuery("SELECT (.+) FROM video_content (.+)").WithArgs(id, lid).WillReturnRows(rows)
 	return id
 }
@@ -141,7 +153,18 @@ func Content(lid int64, mock sqlmock.Sqlmock) int64 {
 func ContentLeader(lid int64, c models.Content, mock sqlmock.Sqlmock) {
 	rows := sqlmock.NewRows([]string{"id", "name", "description", "created_at", "duration", "leader_id", "creation_date", "sharing_status"}).
 		AddRow(c.ID, c.Name, c.Description, c.CreatedAt, c.Duration, c.LeaderID, time.Now().Format("20060102"), 1)
-	mock.ExpectQuery("SELECT (.+) FROM video_content (.+)").WithArgs(lid).WillReturnRows(rows)
+	mock.ExpectQuery("SELECT (.+) FROM video_content (.+)").WithArgs(sqlmock.AnyArg(), lid, models.ContentDeleted).WillReturnRows(rows)
+}
+
+func ContentLeaderStatus(lid int64, c models.Content, mock sqlmock.Sqlmock, statuses ...string) {
+	args := []driver.Value{sqlmock.AnyArg(), lid}
+
+	for _, st := range statuses {
+		args = append(args, st)
+	}
+
+	rows := sqlmock.NewRows([]string{"id", "name", "status"}).AddRow(c.ID, c.Name, c.Status)
+	mock.ExpectQuery("SELECT (.+) FROM video_content (.+)").WithArgs(args...).WillReturnRows(rows)
 }
 
 func SelectContent(lid int64, c models.Content, mock sqlmock.Sqlmock) {
@@ -150,6 +173,18 @@ func SelectContent(lid int64, c models.Content, mock sqlmock.Sqlmock) {
 	mock.ExpectQuery("SELECT (.+) FROM video_content").WillReturnRows(rows)
 }
 
+func Trash(c models.Content, mock sqlmock.Sqlmock) {
+	rows := sqlmock.NewRows([]string{"id", "name", "description", "creation_date", "duration", "previous_leader_id", "previous_leader_name"}).
+		AddRow(c.ID, c.Name, c.Description, c.CreationDate, c.Duration, c.PreviousID, c.PreviousName)
+	mock.ExpectQuery("SELECT (.+) FROM video_content LEFT JOIN user (.+)").WillReturnRows(rows)
+}
+
+func ContentRestore(cid, a int64, mock sqlmock.Sqlmock) {
+	mock.ExpectExec("UPDATE video_content JOIN user (.+)").
+		WithArgs(models.LeaderRole, models.ContentReady, models.ContentCreated, cid, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, a))
+}
+
 func UpdateShareByleaderIdAndContentId(lid, cid int64, mock sqlmock.Sqlmock) *sqlmock.ExpectedExec {
 	return UpdateShare(mock).WithArgs(lid, cid)
 }
@@ -185,12 +220,365 @@ func ContentInsert(uid int64, mock sqlmock.Sqlmock) int64 {
 	return id
 }
 
+func ContentRecording(cid int64, mock sqlmock.Sqlmock) {
+	mock.ExpectExec("UPDATE video_content SET status (.+)").
+		WithArgs(models.ContentRecording, cid, models.ContentCreated, models.ContentRecording, models.ContentFailed).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func ContentTransitionDenied(cid int64, status string, mock sqlmock.Sqlmock) {
+	mock.ExpectExec("UPDATE video_content SET (.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+	rows := sqlmock.NewRows([]string{"status"}).AddRow(status)
+	mock.ExpectQuery("SELECT status FROM video_content (.+)").WithArgs(cid).WillReturnRows(rows)
+}
+
+func ContentProcessing(lid int64, mock sqlmock.Sqlmock) {
+	mock.ExpectExec("UPDATE video_content SET status (.+) capture_session (.+)").
+		WithArgs(models.ContentProcessing, lid, models.ContentRecording).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func ContentFail(cid int64, reason string, mock sqlmock.Sqlmock) {
+	mock.ExpectExec("UPDATE video_content SET status (.+)").
+		WithArgs(models.ContentFailed, reason, cid, models.ContentRecording, models.ContentProcessing).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func ContentReprocess(cid int64, mock sqlmock.Sqlmock) {
+	mock.ExpectExec("UPDATE video_content SET status (.+)").
+		WithArgs(models.ContentProcessing, cid, models.ContentFailed, models.ContentRecording, models.ContentFailed).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func ContentReprocessDenied(cid int64, mock sqlmock.Sqlmock) {
+	mock.ExpectExec("UPDATE video_content SET status (.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func Reprocessing(mock sqlmock.Sqlmock, ids ...int64) {
+	rows := sqlmock.NewRows([]string{"id", "reprocess_at"})
+
+	for _, id := range ids {
+		rows.AddRow(id, time.Now())
+	}
+
+	mock.ExpectQuery("SELECT id, reprocess_at FROM video_content (.+)").
+		WithArgs(models.ContentProcessing).
+		WillReturnRows(rows)
+}
+
+func ContentReadyLock(cid int64, path, status string, mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	rows := sqlmock.NewRows([]string{"name", "description", "leader_id", "path", "status"}).
+		AddRow("name", "description", int64(rand.Uint32()), path, status)
+	mock.ExpectQuery("SELECT (.+) FROM video_content (.+) FOR UPDATE").WithArgs(cid).WillReturnRows(rows)
+}
+
+func ContentReadyUpdate(cid int64, path string, duration int, mock sqlmock.Sqlmock) {
+	mock.ExpectExec("UPDATE video_content SET (.+)").
+		WithArgs(path, duration, models.ContentReady, cid).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
 func ContentUpdate(mock sqlmock.Sqlmock) int64 {
 	max := int64(rand.Uint64())
 	mock.ExpectExec("UPDATE video_content SET (.+)").WillReturnResult(sqlmock.NewResult(1, 1))
 	return max
 }
 
+func ContentLoad(cid, lid int64, status string, mock sqlmock.Sqlmock) {
+	rows := sqlmock.NewRows([]string{"id", "name", "description", "path", "duration", "leader_id", "status", "failure_reason"}).
+		AddRow(cid, "", "", "", 0, lid, status, "")
+	mock.ExpectQuery("SELECT (.+) FROM video_content WHERE id = (.+)").WithArgs(cid).WillReturnRows(rows)
+}
+
+func ContentLoadNotFound(cid int64, mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("SELECT (.+) FROM video_content WHERE id = (.+)").WithArgs(cid).WillReturnRows(sqlmock.NewRows([]string{}))
+}
+
+func ContentPoster(cid int64, key string, mock sqlmock.Sqlmock) {
+	mock.ExpectExec("UPDATE video_content SET poster (.+)").WithArgs(key, cid).WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func ContentHubPoster(cid int64, mock sqlmock.Sqlmock) {
+	mock.ExpectExec("UPDATE video_content SET poster (.+) poster IS NULL").
+		WithArgs(models.HubPosterKey(cid), cid).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func ContentTrash(cid int64, mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	contentTrash(cid, mock)
+	mock.ExpectCommit()
+}
+
+func contentTrash(cid int64, mock sqlmock.Sqlmock) {
+	mock.ExpectExec("UPDATE video_content SET status (.+)").
+		WithArgs(models.ContentDeleted, cid, models.ContentCreated, models.ContentRecording, models.ContentProcessing, models.ContentReady, models.ContentFailed).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE video_content SET previous_leader_id (.+)").
+		WithArgs(sqlmock.AnyArg(), cid, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func LeaderDelete(u models.User, mock sqlmock.Sqlmock, cids ...int64) {
+	mock.ExpectBegin()
+
+	rows := sqlmock.NewRows([]string{"id"})
+
+	for _, cid := range cids {
+		rows.AddRow(cid)
+	}
+
+	mock.ExpectQuery("SELECT id FROM video_content (.+)").WithArgs(u.ID).WillReturnRows(rows)
+
+	for _, cid := range cids {
+		contentTrash(cid, mock)
+	}
+
+	mock.ExpectExec("DELETE FROM user WHERE (.+)").WithArgs(u.ID).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+}
+
+func UploadInsert(cid int64, mock sqlmock.Sqlmock) int64 {
+	id := int64(rand.Uint32())
+	mock.ExpectExec("INSERT INTO upload (.+)").
+		WithArgs(cid, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(id, 1))
+	return id
+}
+
+func Upload(u models.Upload, mock sqlmock.Sqlmock) {
+	rows := sqlmock.NewRows([]string{"id", "video_content_id", "path", "length", "created_at"}).
+		AddRow(u.ID, u.ContentID, u.Path, u.Length, time.Now())
+	mock.ExpectQuery("SELECT (.+) FROM upload (.+)").WithArgs(u.ID, u.ContentID).WillReturnRows(rows)
+}
+
+func UploadOpen(u models.Upload, mock sqlmock.Sqlmock) {
+	rows := sqlmock.NewRows([]string{"id", "video_content_id", "path", "length", "created_at"}).
+		AddRow(u.ID, u.ContentID, u.Path, u.Length, time.Now())
+	mock.ExpectQuery("SELECT (.+) FROM upload (.+)").WithArgs(u.ContentID).WillReturnRows(rows)
+}
+
+func UploadNone(cid int64, mock sqlmock.Sqlmock) {
+	rows := sqlmock.NewRows([]string{"id"})
+	mock.ExpectQuery("SELECT (.+) FROM upload (.+)").WithArgs(cid).WillReturnRows(rows)
+}
+
+func ExpiredUploads(mock sqlmock.Sqlmock, l ...models.Upload) {
+	rows := sqlmock.NewRows([]string{"id", "video_content_id", "path", "length", "created_at"})
+
+	for _, u := range l {
+		rows.AddRow(u.ID, u.ContentID, u.Path, u.Length, time.Now())
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM upload WHERE expires_at (.+)").WillReturnRows(rows)
+}
+
+func UploadDelete(uid int64, mock sqlmock.Sqlmock) {
+	mock.ExpectExec("DELETE FROM upload (.+)").WithArgs(uid).WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func Cleanings(mock sqlmock.Sqlmock, ids ...int64) {
+	rows := sqlmock.NewRows([]string{"id", "date"})
+
+	for _, id := range ids {
+		rows.AddRow(id, time.Now())
+	}
+
+	mock.ExpectQuery("SELECT id, date FROM cleaning (.+)").WillReturnRows(rows)
+}
+
+func CleaningContents(mock sqlmock.Sqlmock, l ...models.Content) {
+	rows := sqlmock.NewRows([]string{"id", "path"})
+
+	for _, c := range l {
+		rows.AddRow(c.ID, c.Path)
+	}
+
+	mock.ExpectQuery("SELECT id, (.+) FROM video_content (.+)").WillReturnRows(rows)
+}
+
+func ContentDelete(cid int64, mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM upload (.+)").WithArgs(cid).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM rendition (.+)").WithArgs(cid).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM marker (.+)").WithArgs(cid).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM comment (.+)").WithArgs(cid).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM video_content_tag (.+)").WithArgs(cid).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM playlist_content (.+)").WithArgs(cid).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM capture_session (.+)").WithArgs(cid).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM share (.+)").WithArgs(cid).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM video_content (.+)").WithArgs(cid).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func CleaningDone(id int64, mock sqlmock.Sqlmock) {
+	mock.ExpectExec("UPDATE cleaning SET done (.+)").WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func Renditions(mock sqlmock.Sqlmock, l ...models.Rendition) {
+	rows := sqlmock.NewRows([]string{"name", "type", "file", "width", "height", "bandwidth"})
+
+	for _, r := range l {
+		rows.AddRow(r.Name, r.Type, r.File, r.Width, r.Height, r.Bandwidth)
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM rendition (.+)").WillReturnRows(rows)
+}
+
+func RenditionsReplace(cid int64, mock sqlmock.Sqlmock, l ...models.Rendition) {
+	mock.ExpectExec("DELETE FROM rendition (.+)").WithArgs(cid).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	for i, r := range l {
+		mock.ExpectExec("INSERT INTO rendition (.+)").
+			WithArgs(cid, r.Name, r.Type, r.File, r.Width, r.Height, r.Bandwidth).
+			WillReturnResult(sqlmock.NewResult(int64(i+1), 1))
+	}
+}
+
+func Markers(cid int64, mock sqlmock.Sqlmock, l ...models.Marker) {
+	rows := sqlmock.NewRows([]string{"id", "video_content_id", "time_offset", "title", "created_at"})
+
+	for _, m := range l {
+		rows.AddRow(m.ID, cid, m.Offset, m.Title, time.Now())
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM marker (.+)").WithArgs(cid).WillReturnRows(rows)
+}
+
+func MarkerInsert(m models.Marker, mock sqlmock.Sqlmock) int64 {
+	id := int64(rand.Uint32())
+	mock.ExpectExec("INSERT INTO marker (.+)").
+		WithArgs(m.ContentID, m.Offset, m.Title).
+		WillReturnResult(sqlmock.NewResult(id, 1))
+	return id
+}
+
+func MarkerUpdate(m models.Marker, mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("SELECT id FROM marker (.+)").
+		WithArgs(m.ID, m.ContentID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(m.ID))
+	mock.ExpectExec("UPDATE marker SET (.+)").
+		WithArgs(m.Offset, m.Title, m.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func MarkerNotFound(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("SELECT id FROM marker (.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+}
+
+func MarkerDelete(mid, cid int64, n int64, mock sqlmock.Sqlmock) {
+	mock.ExpectExec("DELETE FROM marker (.+)").WithArgs(mid, cid).WillReturnResult(sqlmock.NewResult(0, n))
+}
+
+func Comments(cid int64, mock sqlmock.Sqlmock, l ...models.Comment) {
+	rows := sqlmock.NewRows([]string{"id", "video_content_id", "user_id", "author", "time_offset", "body", "created_at"})
+
+	for _, c := range l {
+		rows.AddRow(c.ID, cid, c.UserID, c.Author, c.Offset, c.Body, time.Now())
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM comment JOIN user (.+)").WithArgs(cid).WillReturnRows(rows)
+}
+
+func CommentInsert(c models.Comment, mock sqlmock.Sqlmock) int64 {
+	id := int64(rand.Uint32())
+	mock.ExpectExec("INSERT INTO comment (.+)").
+		WithArgs(c.ContentID, c.UserID, c.Offset, c.Body).
+		WillReturnResult(sqlmock.NewResult(id, 1))
+	return id
+}
+
+func Comment(c models.Comment, mock sqlmock.Sqlmock) {
+	rows := sqlmock.NewRows([]string{"id", "video_content_id", "user_id", "time_offset", "body", "created_at"})
+
+	if c.UserID != 0 {
+		rows.AddRow(c.ID, c.ContentID, c.UserID, c.Offset, c.Body, time.Now())
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM comment WHERE (.+)").WithArgs(c.ID, c.ContentID).WillReturnRows(rows)
+}
+
+func CommentDelete(id int64, mock sqlmock.Sqlmock) {
+	mock.ExpectExec("DELETE FROM comment (.+)").WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func ContentLeaderTags(lid int64, c models.Content, mock sqlmock.Sqlmock, tags ...string) {
+	args := []driver.Value{sqlmock.AnyArg(), lid, models.ContentDeleted}
+
+	for _, t := range tags {
+		args = append(args, t)
+	}
+
+	b, _ := json.Marshal(c.Tags)
+	rows := sqlmock.NewRows([]string{"id", "name", "status", "tags"}).AddRow(c.ID, c.Name, c.Status, b)
+	mock.ExpectQuery("SELECT (.+) FROM video_content (.+)").WithArgs(append(args, len(tags))...).WillReturnRows(rows)
+}
+
+func TagsReplace(cid, lid int64, mock sqlmock.Sqlmock, tags ...string) {
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM video_content_tag (.+)").WithArgs(cid).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	for i, t := range tags {
+		mock.ExpectExec("INSERT IGNORE INTO tag (.+)").
+			WithArgs(lid, t).
+			WillReturnResult(sqlmock.NewResult(int64(i+1), 1))
+		mock.ExpectExec("INSERT INTO video_content_tag (.+)").
+			WithArgs(cid, lid, t).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	mock.ExpectCommit()
+}
+
+func Tags(lid int64, prefix string, mock sqlmock.Sqlmock, l ...models.Tag) {
+	rows := sqlmock.NewRows([]string{"name", "contents"})
+
+	for _, t := range l {
+		rows.AddRow(t.Name, t.Contents)
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM tag (.+)").
+		WithArgs(lid, models.ContentDeleted, prefix, sqlmock.AnyArg()).
+		WillReturnRows(rows)
+}
+
+func Tagged(lid int64, tag string, mock sqlmock.Sqlmock, l ...models.Content) {
+	rows := sqlmock.NewRows([]string{"id", "name", "path", "leader_id", "status"})
+
+	for _, c := range l {
+		rows.AddRow(c.ID, c.Name, c.Path, lid, c.Status)
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM video_content JOIN video_content_tag (.+)").
+		WithArgs(lid, models.ContentDeleted, tag).
+		WillReturnRows(rows)
+}
+
+func TagStats(mock sqlmock.Sqlmock, l ...models.Tag) {
+	rows := sqlmock.NewRows([]string{"name", "contents", "leaders"})
+
+	for _, t := range l {
+		rows.AddRow(t.Name, t.Contents, t.Leaders)
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM tag (.+)").WithArgs(models.ContentDeleted).WillReturnRows(rows)
+}
+
+func Shared(path string, exp time.Time, mock sqlmock.Sqlmock) *sqlmock.ExpectedQuery {
+	rows := sqlmock.NewRows([]string{"id", "path", "expiration_date"}).AddRow(42, path, exp)
+	return mock.ExpectQuery("SELECT (.+) FROM share JOIN video_content (.+)").WillReturnRows(rows)
+}
+
+func SharedContent(uid, cid int64, path string, exp time.Time, mock sqlmock.Sqlmock) *sqlmock.ExpectedQuery {
+	return Shared(path, exp, mock).WithArgs(models.ContentReady, uid, cid, models.ContentReady, uid, cid)
+}
+
+func SharedNotFound(mock sqlmock.Sqlmock) *sqlmock.ExpectedQuery {
+	rows := sqlmock.NewRows([]string{"id", "path", "expiration_date"})
+	return mock.ExpectQuery("SELECT (.+) FROM share JOIN video_content (.+)").WillReturnRows(rows)
+}
+
 func SettingsUpdate(mock sqlmock.Sqlmock) int64 {
 	max := int64(rand.Uint64())
 	mock.ExpectExec("UPDATE settings SET (.+)").
@@ -208,3 +596,310 @@ func IsInTribe(lid, uid int64, mock sqlmock.Sqlmock) *sqlmock.ExpectedQuery {
 	rows := sqlmock.NewRows([]string{"id"}).AddRow(1)
 	return mock.ExpectQuery("SELECT (.+) FROM tribe WHERE (.+)").WithArgs(lid, uid).WillReturnRows(rows)
 }
+
+func AuditInsert(action string, mock sqlmock.Sqlmock) {
+	mock.ExpectExec("INSERT INTO audit_log (.+)").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), action, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(int64(rand.Uint64()), 1))
+}
+
+func Audit(a models.Audit, mock sqlmock.Sqlmock) *sqlmock.ExpectedQuery {
+	rows := sqlmock.NewRows([]string{"id", "actor_id", "role", "action", "target_type", "target_id", "diff", "ip", "created_at"}).
+		AddRow(a.ID, a.ActorID, a.Role, a.Action, a.TargetType, a.TargetID, []byte(a.Diff), a.IP, a.CreatedAt)
+	return mock.ExpectQuery("SELECT (.+) FROM audit_log (.+)").WillReturnRows(rows)
+}
+
+func CaptureSessionInsert(cid, lid, did, max int64, mock sqlmock.Sqlmock) {
+	mock.ExpectExec("UPDATE capture_session (.+)").WithArgs(lid).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO capture_session (.+)").
+		WithArgs(cid, lid, did, max).
+		WillReturnResult(sqlmock.NewResult(int64(rand.Uint32()), 1))
+}
+
+func CaptureStop(lid, d int64, mock sqlmock.Sqlmock) {
+	mock.ExpectExec("UPDATE capture_session (.+)").WithArgs(d, lid).WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func CaptureSessionRunning(cs models.CaptureSession, mock sqlmock.Sqlmock) {
+	rows := sqlmock.NewRows([]string{"id", "video_content_id", "leader_id", "device_id", "max_duration", "started_at", "duration", "elapsed"}).
+		AddRow(cs.ID, cs.ContentID, cs.LeaderID, cs.DeviceID, cs.MaxDuration, cs.StartedAt, 0, int64(time.Since(cs.StartedAt)/time.Second))
+	mock.ExpectQuery("SELECT (.+) FROM capture_session (.+)").WithArgs(cs.LeaderID).WillReturnRows(rows)
+}
+
+func CaptureSessionNone(lid int64, mock sqlmock.Sqlmock) {
+	rows := sqlmock.NewRows([]string{"id"})
+	mock.ExpectQuery("SELECT (.+) FROM capture_session (.+)").WithArgs(lid).WillReturnRows(rows)
+}
+
+func devicesRows(l ...models.Device) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "name", "address", "transport", "last_seen_at", "online"})
+
+	for _, d := range l {
+		rows.AddRow(d.ID, d.Name, d.Address, d.Transport, d.LastSeenAt, d.Online)
+	}
+
+	return rows
+}
+
+func LeaderDevices(lid int64, mock sqlmock.Sqlmock, l ...models.Device) {
+	mock.ExpectQuery("SELECT (.+) FROM device (.+)").
+		WithArgs(sqlmock.AnyArg(), lid).
+		WillReturnRows(devicesRows(l...))
+}
+
+func Device(d models.Device, mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("SELECT (.+) FROM device (.+)").
+		WithArgs(sqlmock.AnyArg(), d.ID).
+		WillReturnRows(devicesRows(d))
+
+	rows := sqlmock.NewRows([]string{"leader_id"})
+
+	for _, lid := range d.Leaders {
+		rows.AddRow(lid)
+	}
+
+	mock.ExpectQuery("SELECT leader_id FROM device_leader (.+)").WithArgs(d.ID).WillReturnRows(rows)
+}
+
+func DeviceNotFound(did int64, mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("SELECT (.+) FROM device (.+)").
+		WithArgs(sqlmock.AnyArg(), did).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+}
+
+func Devices(mock sqlmock.Sqlmock, l ...models.Device) {
+	mock.ExpectQuery("SELECT (.+) FROM device ORDER BY name").
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(devicesRows(l...))
+
+	rows := sqlmock.NewRows([]string{"device_id", "leader_id"})
+
+	for _, d := range l {
+		for _, lid := range d.Leaders {
+			rows.AddRow(d.ID, lid)
+		}
+	}
+
+	mock.ExpectQuery("SELECT device_id, leader_id FROM device_leader (.+)").WillReturnRows(rows)
+}
+
+func Leaders(mock sqlmock.Sqlmock, ids ...int64) {
+	for _, id := range ids {
+		rows := sqlmock.NewRows([]string{"id"}).AddRow(id)
+		mock.ExpectQuery("SELECT id FROM user (.+)").WithArgs(id, models.LeaderRole).WillReturnRows(rows)
+	}
+}
+
+func DeviceInsert(d models.Device, mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO device (.+)").
+		WithArgs(d.Name, d.Address, d.Transport).
+		WillReturnResult(sqlmock.NewResult(d.ID, 1))
+
+	for _, lid := range d.Leaders {
+		mock.ExpectExec("INSERT INTO device_leader (.+)").WithArgs(d.ID, lid).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	mock.ExpectCommit()
+}
+
+func DeviceUpdate(d models.Device, mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE device (.+)").
+		WithArgs(d.Name, d.Address, d.Transport, d.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM device_leader (.+)").WithArgs(d.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	for _, lid := range d.Leaders {
+		mock.ExpectExec("INSERT INTO device_leader (.+)").WithArgs(d.ID, lid).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	mock.ExpectCommit()
+}
+
+func DeviceDelete(did int64, mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM device_leader (.+)").WithArgs(did).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM device (.+)").WithArgs(did).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func DeviceSeen(did int64, mock sqlmock.Sqlmock) {
+	mock.ExpectExec("UPDATE device SET last_seen_at (.+)").WithArgs(did).WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func PlaylistInsert(p models.Playlist, mock sqlmock.Sqlmock) int64 {
+	id := int64(rand.Uint32())
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO playlist (.+)").
+		WithArgs(p.LeaderID, p.Name, p.Description).
+		WillReturnResult(sqlmock.NewResult(id, 1))
+	playlistContents(id, p.ContentIDs, mock)
+	mock.ExpectCommit()
+	return id
+}
+
+func PlaylistUpdate(p models.Playlist, mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE playlist SET (.+)").
+		WithArgs(p.Name, p.Description, p.ID, p.LeaderID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM playlist_content (.+)").WithArgs(p.ID).WillReturnResult(sqlmock.NewResult(0, 0))
+	playlistContents(p.ID, p.ContentIDs, mock)
+	mock.ExpectCommit()
+}
+
+func playlistContents(pid int64, ids []int64, mock sqlmock.Sqlmock) {
+	for i, cid := range ids {
+		mock.ExpectExec("INSERT INTO playlist_content (.+)").
+			WithArgs(pid, cid, i).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+}
+
+func Playlist(p models.Playlist, mock sqlmock.Sqlmock) {
+	rows := sqlmock.NewRows([]string{"id", "leader_id", "name", "description"}).
+		AddRow(p.ID, p.LeaderID, p.Name, p.Description)
+	mock.ExpectQuery("SELECT (.+) FROM playlist WHERE (.+)").WithArgs(p.ID, p.LeaderID).WillReturnRows(rows)
+
+	ids := sqlmock.NewRows([]string{"video_content_id"})
+
+	for _, cid := range p.ContentIDs {
+		ids.AddRow(cid)
+	}
+
+	mock.ExpectQuery("SELECT video_content_id FROM playlist_content (.+)").WithArgs(p.ID).WillReturnRows(ids)
+}
+
+func PlaylistNotFound(mock sqlmock.Sqlmock) {
+	rows := sqlmock.NewRows([]string{"id", "leader_id", "name", "description"})
+	mock.ExpectQuery("SELECT (.+) FROM playlist WHERE (.+)").WillReturnRows(rows)
+}
+
+func Playlists(lid int64, mock sqlmock.Sqlmock, l ...models.Playlist) {
+	rows := sqlmock.NewRows([]string{"id", "leader_id", "name", "description"})
+	ids := sqlmock.NewRows([]string{"playlist_id", "video_content_id"})
+
+	for _, p := range l {
+		rows.AddRow(p.ID, lid, p.Name, p.Description)
+
+		for _, cid := range p.ContentIDs {
+			ids.AddRow(p.ID, cid)
+		}
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM playlist WHERE (.+)").WithArgs(lid).WillReturnRows(rows)
+	mock.ExpectQuery("SELECT (.+) FROM playlist_content JOIN playlist (.+)").WithArgs(lid).WillReturnRows(ids)
+}
+
+func PlaylistContents(pid int64, status string, mock sqlmock.Sqlmock, l ...models.Content) {
+	rows := sqlmock.NewRows([]string{"id", "name", "path", "status", "ready"})
+
+	for _, c := range l {
+		rows.AddRow(c.ID, c.Name, c.Path, c.Status, c.Status == models.ContentReady)
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM playlist_content JOIN video_content (.+)").
+		WithArgs(pid, status).
+		WillReturnRows(rows)
+}
+
+func PlaylistDelete(pid int64, mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM playlist_share (.+)").WithArgs(pid).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM playlist_content (.+)").WithArgs(pid).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM playlist (.+)").WithArgs(pid).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func SharedPlaylist(uid int64, p models.Playlist, exp time.Time, mock sqlmock.Sqlmock) *sqlmock.ExpectedQuery {
+	rows := sqlmock.NewRows([]string{"id", "leader_id", "name", "description", "expiration_date"}).
+		AddRow(p.ID, p.LeaderID, p.Name, p.Description, exp)
+	return mock.ExpectQuery("SELECT (.+) FROM playlist_share JOIN playlist (.+)").WillReturnRows(rows)
+}
+
+func SharedPlaylistNotFound(mock sqlmock.Sqlmock) *sqlmock.ExpectedQuery {
+	rows := sqlmock.NewRows([]string{"id", "leader_id", "name", "description", "expiration_date"})
+	return mock.ExpectQuery("SELECT (.+) FROM playlist_share JOIN playlist (.+)").WillReturnRows(rows)
+}
+
+func PlaylistShareInsert(pid, uid int64, mock sqlmock.Sqlmock) {
+	mock.ExpectExec("INSERT INTO playlist_share (.+)").
+		WithArgs(pid, uid, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(int64(rand.Uint32()), 1))
+}
+
+func PlaylistShares(pid int64, mock sqlmock.Sqlmock, l ...models.PlaylistShare) {
+	rows := sqlmock.NewRows([]string{"id", "user_id", "message", "expiration_date", "name"})
+
+	for _, s := range l {
+		rows.AddRow(s.ID, s.UserID, s.Message, time.Time(s.ExpirationDate), s.UserName)
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM playlist_share JOIN user (.+)").WithArgs(pid).WillReturnRows(rows)
+}
+
+func Usage(lid int64, u models.Usage, mock sqlmock.Sqlmock) *sqlmock.ExpectedQuery {
+	return UsageExcept(lid, 0, u, mock)
+}
+
+func UsageExcept(lid, cid int64, u models.Usage, mock sqlmock.Sqlmock) *sqlmock.ExpectedQuery {
+	rows := sqlmock.NewRows([]string{"videos", "seconds", "max_videos", "max_minutes"}).
+		AddRow(u.Videos, u.Seconds, u.MaxVideos, u.MaxMinutes)
+	return mock.ExpectQuery("SELECT (.+) FROM user LEFT JOIN quota (.+)").
+		WithArgs(models.ContentDeleted, models.ContentDeleted, cid, cid, lid, models.LeaderRole).
+		WillReturnRows(rows)
+}
+
+func UsageNotFound(lid int64, mock sqlmock.Sqlmock) *sqlmock.ExpectedQuery {
+	rows := sqlmock.NewRows([]string{"videos", "seconds", "max_videos", "max_minutes"})
+	return mock.ExpectQuery("SELECT (.+) FROM user LEFT JOIN quota (.+)").
+		WithArgs(models.ContentDeleted, models.ContentDeleted, 0, 0, lid, models.LeaderRole).
+		WillReturnRows(rows)
+}
+
+func QuotaDefault(minutes, videos int, mock sqlmock.Sqlmock) *sqlmock.ExpectedQuery {
+	rows := sqlmock.NewRows([]string{"minutes", "videos"}).AddRow(minutes, videos)
+	return mock.ExpectQuery("SELECT (.+) FROM settings LIMIT 1").WillReturnRows(rows)
+}
+
+func QuotaDefaultUpdate(minutes, videos int, mock sqlmock.Sqlmock) {
+	mock.ExpectExec("UPDATE settings SET quota_minutes (.+)").
+		WithArgs(minutes, videos).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func Quota(uid int64, q models.Quota, mock sqlmock.Sqlmock) *sqlmock.ExpectedQuery {
+	rows := sqlmock.NewRows([]string{"user_id", "minutes", "videos"}).AddRow(uid, limit(q.Minutes), limit(q.Videos))
+	return mock.ExpectQuery("SELECT (.+) FROM quota (.+)").WithArgs(uid).WillReturnRows(rows)
+}
+
+func limit(v *int) interface{} {
+	if v == nil {
+		return nil
+	}
+
+	return *v
+}
+
+func QuotaNotFound(uid int64, mock sqlmock.Sqlmock) *sqlmock.ExpectedQuery {
+	rows := sqlmock.NewRows([]string{"user_id", "minutes", "videos"})
+	return mock.ExpectQuery("SELECT (.+) FROM quota (.+)").WithArgs(uid).WillReturnRows(rows)
+}
+
+func QuotaSet(uid int64, minutes, videos interface{}, mock sqlmock.Sqlmock) {
+	mock.ExpectExec("INSERT INTO quota (.+)").
+		WithArgs(uid, minutes, videos).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func UserRole(uid int64, role string, mock sqlmock.Sqlmock) *sqlmock.ExpectedQuery {
+	rows := sqlmock.NewRows([]string{"id", "email", "password", "role", "firstname", "lastname"}).
+		AddRow(uid, "", "", role, "", "")
+	return mock.ExpectQuery("SELECT (.+) FROM user (.+)").WithArgs(uid).WillReturnRows(rows)
+}
+
+func UserNotFound(uid int64, mock sqlmock.Sqlmock) *sqlmock.ExpectedQuery {
+	rows := sqlmock.NewRows([]string{"id", "email", "password", "role", "firstname", "lastname"})
+	return mock.ExpectQuery("SELECT (.+) FROM user (.+)").WithArgs(uid).WillReturnRows(rows)
+}
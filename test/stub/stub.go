@@ -5,6 +5,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
@@ -69,6 +70,17 @@ func HttpWithContext(uid int64) (*httptest.ResponseRecorder, *http.Request) {
 	return httptest.NewRecorder(), r.WithContext(ctx)
 }
 
+func HttpWithRole(uid int64, role string) (*httptest.ResponseRecorder, *http.Request) {
+	r := httptest.NewRequest("GET", "/fake/url", nil)
+
+	c := jwt.MapClaims{"user": int64(uid), "role": role}
+	t := &jwt.Token{Claims: c}
+
+	ctx := jwtauth.NewContext(r.Context(), t, nil)
+
+	return httptest.NewRecorder(), r.WithContext(ctx)
+}
+
 func User(r string) models.User {
 	return models.User{
 		ID:        int64(rand.Uint64()),
@@ -143,6 +155,25 @@ func PlayRecord(dur int) map[string]interface{} {
 	}
 }
 
+func Device() models.Device {
+	return models.Device{
+		ID:        int64(rand.Uint32()),
+		Name:      fake.UserName(),
+		Address:   fmt.Sprintf("127.0.0.1:%d", 1024+rand.Intn(60000)),
+		Transport: "udp",
+		Leaders:   []int64{},
+	}
+}
+
+func DeviceParam(n, a, t string, leaders ...int64) map[string]interface{} {
+	return map[string]interface{}{
+		"name":      n,
+		"address":   a,
+		"transport": t,
+		"leaders":   leaders,
+	}
+}
+
 func DB() (sqlmock.Sqlmock, *sql.DB) {
 	mockDB, mock, _ := sqlmock.New()
 